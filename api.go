@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duke605/tv-bot/moviedb"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// maxAdminRequestBody is the most the admin API reads from a request body
+const maxAdminRequestBody = 1 << 20
+
+// AdminAPI is the HTTP API for managing the bot. It's described by the OpenAPI spec in openapi.yaml which is
+// served on /admin/openapi.yaml. Every other route needs the admin token as a bearer token
+type AdminAPI struct {
+	token     string
+	seriesSrv *SeriesService
+	subsSrv   *SubscriptionsService
+	jobsSrv   *JobsService
+	leaseSrv  *LeaseService
+}
+
+func NewAdminAPI(token string, ss *SeriesService, sus *SubscriptionsService, js *JobsService, ls *LeaseService) *AdminAPI {
+	return &AdminAPI{
+		token:     token,
+		seriesSrv: ss,
+		subsSrv:   sus,
+		jobsSrv:   js,
+		leaseSrv:  ls,
+	}
+}
+
+// Register adds the routes of the API to the mux. shutdown is called after responding to a shutdown request
+func (api *AdminAPI) Register(mux *http.ServeMux, shutdown func()) {
+	if api.token == "" {
+		slog.Warn("No admin token is configured so every request to the admin API will be rejected")
+	}
+
+	mux.HandleFunc("GET /admin/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	})
+	mux.HandleFunc("POST /admin/shutdown", api.authenticate(func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "Received shutdown command from admin API")
+		writeJSON(w, http.StatusAccepted, map[string]bool{"shutting_down": true})
+		shutdown()
+	}))
+	mux.HandleFunc("POST /admin/scan", api.authenticate(api.scan))
+	mux.HandleFunc("GET /admin/guilds/{guild_id}/users/{user_id}/subscriptions", api.authenticate(api.getSubscriptions))
+	mux.HandleFunc("POST /admin/guilds/{guild_id}/users/{user_id}/subscriptions", api.authenticate(api.subscribe))
+	mux.HandleFunc("DELETE /admin/guilds/{guild_id}/users/{user_id}/subscriptions/{series_id}", api.authenticate(api.unsubscribe))
+	mux.HandleFunc("POST /admin/series/{series_id}/refresh", api.authenticate(api.refreshSeries))
+	mux.HandleFunc("GET /admin/notifications", api.authenticate(api.getNotifications))
+	mux.HandleFunc("POST /admin/notifications/resend", api.authenticate(api.resendNotification))
+}
+
+// authenticate only calls next if the request has the admin token. Every request is rejected if there is no token
+func (api *AdminAPI) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || api.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(api.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid admin token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// scan looks for new episodes and responds with the run once it finishes. Scans only run on the instance holding
+// the scheduler lease so they're stopped if the lease is lost, the same as scheduled scans
+func (api *AdminAPI) scan(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := api.leaseSrv.Held()
+	if !ok {
+		writeError(w, http.StatusConflict, "this instance is not running the scheduled jobs")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Scan for new episodes started from admin API")
+	run, err := api.jobsSrv.FindNewEpisodes(ctx)
+	if errors.Is(err, ErrScanRunning) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error occurred while finding new episodes", "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (api *AdminAPI) getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	guildID, userID, ok := pathGuildAndUserIDs(w, r)
+	if !ok {
+		return
+	}
+
+	subs, err := api.subsSrv.GetUserSubscriptions(r.Context(), guildID, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get user subscriptions", "guild_id", guildID, "user_id", userID, "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subs)
+}
+
+func (api *AdminAPI) subscribe(w http.ResponseWriter, r *http.Request) {
+	guildID, userID, ok := pathGuildAndUserIDs(w, r)
+	if !ok {
+		return
+	}
+	body := struct {
+		SeriesID uint64 `json:"series_id"`
+	}{}
+	if !readJSON(w, r, &body) {
+		return
+	} else if body.SeriesID == 0 {
+		writeError(w, http.StatusBadRequest, "series_id is required")
+		return
+	}
+
+	series, _, err := api.seriesSrv.GetSeriesDetails(r.Context(), body.SeriesID)
+	if errors.Is(err, moviedb.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "series could not be found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get series information", "series_id", body.SeriesID, "error", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if status := strings.ToLower(series.Status); status == "canceled" || status == "ended" {
+		writeError(w, http.StatusConflict, "series has ended or been canceled")
+		return
+	}
+
+	isSubbed, err := api.subsSrv.UserIsSubscribed(r.Context(), guildID, body.SeriesID, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed checking if user is subscribed", "user_id", userID, "series_id", body.SeriesID, "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if isSubbed {
+		writeError(w, http.StatusConflict, "user is already subscribed to series")
+		return
+	}
+
+	if err = api.subsSrv.SubscribeUserToSeries(r.Context(), guildID, body.SeriesID, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to subscribe user to series", "user_id", userID, "series_id", body.SeriesID, "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "User subscribed to series from admin API", "guild_id", guildID, "user_id", userID, "series_id", body.SeriesID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *AdminAPI) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	guildID, userID, ok := pathGuildAndUserIDs(w, r)
+	if !ok {
+		return
+	}
+	seriesID, ok := pathID(w, r, "series_id")
+	if !ok {
+		return
+	}
+
+	isSubbed, err := api.subsSrv.UserIsSubscribed(r.Context(), guildID, seriesID, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed checking if user is subscribed", "user_id", userID, "series_id", seriesID, "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if !isSubbed {
+		writeError(w, http.StatusNotFound, "user is not subscribed to series")
+		return
+	}
+
+	if err = api.subsSrv.DeleteUserSubscription(r.Context(), guildID, seriesID, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to unsubscribe user from series", "user_id", userID, "series_id", seriesID, "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "User unsubscribed from series from admin API", "guild_id", guildID, "user_id", userID, "series_id", seriesID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *AdminAPI) refreshSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID, ok := pathID(w, r, "series_id")
+	if !ok {
+		return
+	}
+
+	series, err := api.seriesSrv.RefreshSeriesDetails(r.Context(), seriesID)
+	if errors.Is(err, moviedb.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "series could not be found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to refresh series", "series_id", seriesID, "error", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, series)
+}
+
+// getNotifications responds with the latest notifications. 20 are returned unless the limit query param asks for
+// a different number, up to 100
+func (api *AdminAPI) getNotifications(w http.ResponseWriter, r *http.Request) {
+	limit := uint64(20)
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.ParseUint(l, 10, 64); err != nil || limit == 0 || limit > 100 {
+			writeError(w, http.StatusBadRequest, "limit must be a number between 1 and 100")
+			return
+		}
+	}
+
+	notis, err := api.seriesSrv.GetRecentNotifications(r.Context(), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get recent notifications", "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notis)
+}
+
+// resendNotification queues an episode to be delivered again to a target it was already delivered to. Either a
+// guild or a user must be given as the target
+func (api *AdminAPI) resendNotification(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		SeriesID uint64 `json:"series_id"`
+		Season   int    `json:"season"`
+		Episode  int    `json:"episode"`
+		NotificationTarget
+	}{}
+	if !readJSON(w, r, &body) {
+		return
+	} else if body.SeriesID == 0 {
+		writeError(w, http.StatusBadRequest, "series_id is required")
+		return
+	} else if (body.GuildID == 0) == (body.UserID == 0) {
+		writeError(w, http.StatusBadRequest, "exactly one of guild_id and user_id is required")
+		return
+	}
+
+	err := api.seriesSrv.ResendNotification(r.Context(), body.Episode, body.Season, body.SeriesID, body.NotificationTarget)
+	if errors.Is(err, ErrNotNotified) || errors.Is(err, moviedb.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to resend notification", "series_id", body.SeriesID, "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "Notification resent from admin API",
+		"series_id", body.SeriesID,
+		"season_number", body.Season,
+		"episode_id", body.Episode,
+		"guild_id", body.GuildID,
+		"user_id", body.UserID,
+	)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// pathGuildAndUserIDs parses the guild and user IDs in the path, responding with a 400 if either is invalid
+func pathGuildAndUserIDs(w http.ResponseWriter, r *http.Request) (uint64, uint64, bool) {
+	guildID, ok := pathID(w, r, "guild_id")
+	if !ok {
+		return 0, 0, false
+	}
+	userID, ok := pathID(w, r, "user_id")
+
+	return guildID, userID, ok
+}
+
+// pathID parses the ID in the path under the name provided, responding with a 400 if it's invalid
+func pathID(w http.ResponseWriter, r *http.Request, name string) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue(name), 10, 64)
+	if err != nil || id == 0 {
+		writeError(w, http.StatusBadRequest, name+" must be a positive number")
+		return 0, false
+	}
+
+	return id, true
+}
+
+// readJSON decodes the body of the request into v, responding with a 400 if it can't be
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write admin API response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
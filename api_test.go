@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "secret"
+
+type adminAPIFixture struct {
+	*seriesServiceFixture
+	lease     *LeaseService
+	mux       *http.ServeMux
+	shutdowns int
+}
+
+// newAdminAPIFixture creates an admin API with the token provided backed by a series service fixture. The instance
+// doesn't hold the scheduler lease until it's renewed
+func newAdminAPIFixture(t *testing.T, token string) *adminAPIFixture {
+	t.Helper()
+
+	f := &adminAPIFixture{seriesServiceFixture: newSeriesServiceFixture(t), mux: http.NewServeMux()}
+	f.lease = NewLeaseService(NewLeasesRepo(f.db), "a", time.Minute, f.clock)
+	jobsSrv := NewJobsService(NewJobRunsRepo(f.db), f.srv, NewMetrics(), time.Minute*30, f.clock)
+	api := NewAdminAPI(token, f.srv, f.srv.subsSrv, jobsSrv, f.lease)
+	api.Register(f.mux, func() { f.shutdowns++ })
+
+	return f
+}
+
+// do sends a request to the API with the admin token
+func (f *adminAPIFixture) do(method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	f.mux.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestAdminAPIAuthentication(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		method        string
+		path          string
+		authorization string
+		expStatus     int
+		expShutdowns  int
+	}{
+		{
+			name:          "shuts down with the admin token",
+			token:         testAdminToken,
+			method:        http.MethodPost,
+			path:          "/admin/shutdown",
+			authorization: "Bearer " + testAdminToken,
+			expStatus:     http.StatusAccepted,
+			expShutdowns:  1,
+		},
+		{
+			name:      "rejects requests without a token",
+			token:     testAdminToken,
+			method:    http.MethodPost,
+			path:      "/admin/shutdown",
+			expStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "rejects requests with the wrong token",
+			token:         testAdminToken,
+			method:        http.MethodPost,
+			path:          "/admin/shutdown",
+			authorization: "Bearer wrong",
+			expStatus:     http.StatusUnauthorized,
+		},
+		{
+			name:          "rejects the token sent with another scheme",
+			token:         testAdminToken,
+			method:        http.MethodPost,
+			path:          "/admin/shutdown",
+			authorization: "Basic " + testAdminToken,
+			expStatus:     http.StatusUnauthorized,
+		},
+		{
+			name:          "rejects every request when no token is configured",
+			method:        http.MethodPost,
+			path:          "/admin/shutdown",
+			authorization: "Bearer ",
+			expStatus:     http.StatusUnauthorized,
+		},
+		{
+			name:      "serves the spec without a token",
+			token:     testAdminToken,
+			method:    http.MethodGet,
+			path:      "/admin/openapi.yaml",
+			expStatus: http.StatusOK,
+		},
+		{
+			name:          "does not shut down on GET",
+			token:         testAdminToken,
+			method:        http.MethodGet,
+			path:          "/admin/shutdown",
+			authorization: "Bearer " + testAdminToken,
+			expStatus:     http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			f := newAdminAPIFixture(t, test.token)
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			// Acting
+			f.mux.ServeHTTP(w, r)
+
+			// Asserting
+			assert.Equal(t, test.expStatus, w.Code)
+			assert.Equal(t, test.expShutdowns, f.shutdowns)
+		})
+	}
+}
+
+func TestAdminAPISubscriptions(t *testing.T) {
+	// Arranging
+	f := newAdminAPIFixture(t, testAdminToken)
+	path := "/admin/guilds/1/users/10/subscriptions"
+
+	// Acting
+	subscribed := f.do(http.MethodPost, path, `{"series_id":100}`)
+	subscribedAgain := f.do(http.MethodPost, path, `{"series_id":100}`)
+	missingSeries := f.do(http.MethodPost, path, `{"series_id":999}`)
+	badGuild := f.do(http.MethodGet, "/admin/guilds/abc/users/10/subscriptions", "")
+	listed := f.do(http.MethodGet, path, "")
+	unsubscribed := f.do(http.MethodDelete, path+"/100", "")
+	unsubscribedAgain := f.do(http.MethodDelete, path+"/100", "")
+
+	// Asserting
+	assert.Equal(t, http.StatusNoContent, subscribed.Code)
+	assert.Equal(t, http.StatusConflict, subscribedAgain.Code)
+	assert.Equal(t, http.StatusNotFound, missingSeries.Code)
+	assert.Equal(t, http.StatusBadRequest, badGuild.Code)
+	require.Equal(t, http.StatusOK, listed.Code)
+	subs := []map[string]any{}
+	require.NoError(t, json.Unmarshal(listed.Body.Bytes(), &subs))
+	require.Len(t, subs, 1)
+	assert.Equal(t, "1", subs[0]["guild_id"])
+	assert.Equal(t, "10", subs[0]["user_id"])
+	assert.Equal(t, 100.0, subs[0]["series_id"])
+	assert.Equal(t, http.StatusNoContent, unsubscribed.Code)
+	assert.Equal(t, http.StatusNotFound, unsubscribedAgain.Code)
+}
+
+func TestAdminAPIRefreshSeries(t *testing.T) {
+	// Arranging
+	f := newAdminAPIFixture(t, testAdminToken)
+
+	// Acting
+	refreshed := f.do(http.MethodPost, "/admin/series/100/refresh", "")
+	missing := f.do(http.MethodPost, "/admin/series/999/refresh", "")
+
+	// Asserting
+	assert.Equal(t, http.StatusOK, refreshed.Code)
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	_, cached, err := f.srv.GetSeriesDetails(context.Background(), 100)
+	require.NoError(t, err)
+	assert.NotNil(t, cached)
+}
+
+func TestAdminAPIScan(t *testing.T) {
+	// Arranging
+	f := newAdminAPIFixture(t, testAdminToken)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+
+	// Acting
+	notHeld := f.do(http.MethodPost, "/admin/scan", "")
+	require.NoError(t, f.lease.Renew(context.Background()))
+	scanned := f.do(http.MethodPost, "/admin/scan", "")
+
+	// Asserting
+	assert.Equal(t, http.StatusConflict, notHeld.Code)
+	require.Equal(t, http.StatusOK, scanned.Code)
+	run := &JobRun{}
+	require.NoError(t, json.Unmarshal(scanned.Body.Bytes(), run))
+	assert.Equal(t, JobRunFindNewEpisodes, run.Job)
+	assert.Equal(t, 1, run.EpisodesNotified)
+	assert.Len(t, f.outbox(t), 1)
+}
+
+func TestAdminAPIResendNotification(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newAdminAPIFixture(t, testAdminToken)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	_, err := f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+
+	// Acting
+	resent := f.do(http.MethodPost, "/admin/notifications/resend", `{"series_id":100,"season":1,"episode":2,"guild_id":"1"}`)
+	notNotified := f.do(http.MethodPost, "/admin/notifications/resend", `{"series_id":100,"season":1,"episode":2,"guild_id":"2"}`)
+	noTarget := f.do(http.MethodPost, "/admin/notifications/resend", `{"series_id":100,"season":1,"episode":2}`)
+	require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+	listed := f.do(http.MethodGet, "/admin/notifications?limit=5", "")
+	badLimit := f.do(http.MethodGet, "/admin/notifications?limit=0", "")
+
+	// Asserting
+	assert.Equal(t, http.StatusAccepted, resent.Code)
+	assert.Equal(t, http.StatusNotFound, notNotified.Code)
+	assert.Equal(t, http.StatusBadRequest, noTarget.Code)
+	sent := f.discord.Sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, sent[0].Embeds, sent[1].Embeds)
+	require.Equal(t, http.StatusOK, listed.Code)
+	notis := []map[string]any{}
+	require.NoError(t, json.Unmarshal(listed.Body.Bytes(), &notis))
+	require.Len(t, notis, 1)
+	assert.Equal(t, "1", notis[0]["guild_id"])
+	assert.Equal(t, "2", notis[0]["discord_message_id"])
+	assert.Equal(t, http.StatusBadRequest, badLimit.Code)
+}
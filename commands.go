@@ -3,20 +3,26 @@ package main
 import (
 	"context"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/bwmarrin/discordgo"
 	"github.com/duke605/tv-bot/utils"
 	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose/v3"
-	"github.com/robfig/cron"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -31,44 +37,125 @@ var rootCommand = &cobra.Command{
 
 		discordCommandService := srvCtn.Get(SrvCtnKeyDiscordCommandSrv).(*DiscordCommandService)
 		discord := srvCtn.Get(SrvCtnKeyDiscord).(*discordgo.Session)
-		seriesService := srvCtn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
+		jobsService := srvCtn.Get(SrvCtnKeyJobsSrv).(*JobsService)
+		remindersService := srvCtn.Get(SrvCtnKeyRemindersSrv).(*RemindersService)
+		digestService := srvCtn.Get(SrvCtnKeyDigestSrv).(*DigestService)
+		outboxService := srvCtn.Get(SrvCtnKeyOutboxSrv).(*OutboxService)
+		leaseService := srvCtn.Get(SrvCtnKeyLeaseSrv).(*LeaseService)
+		metrics := srvCtn.Get(SrvCtnKeyMetrics).(*Metrics)
+		healthService := srvCtn.Get(SrvCtnKeyHealthSrv).(*HealthService)
+		adminAPI := srvCtn.Get(SrvCtnKeyAdminAPI).(*AdminAPI)
+		guildsService := srvCtn.Get(SrvCtnKeyGuildsSrv).(*GuildsService)
 		viper := srvCtn.Get(SrvCtnKeyViper).(*viper.Viper)
+		clk := srvCtn.Get(SrvCtnKeyClock).(clock.Clock)
 
 		ctx, cancel := context.WithCancel(cmd.Context())
 		defer cancel()
 
+		if err := adoptLegacySubscriptions(ctx, viper, guildsService); err != nil {
+			return err
+		}
+
 		discordCommandService.RegisterHandlers(ctx)
+		healthService.RegisterHandlers(discord)
 		if err := discord.Open(); err != nil {
 			return err
 		}
 		defer discord.Close()
 
-		c := cron.New()
-		c.AddFunc("@every 10m", func() {
-			start := time.Now()
+		// Only the instance holding the lease runs the scheduled jobs so running a second instance, like during a
+		// rolling deploy, doesn't send everything twice. Every instance still handles interactions
+		leaseCtx, leaseCancel := context.WithCancel(ctx)
+		leaseDone := make(chan struct{})
+		go func() {
+			defer close(leaseDone)
+			leaseService.Run(leaseCtx, viper.GetDuration("lease.ttl")/3)
+		}()
+
+		c := utils.NewScheduler(clk)
+		c.Every(viper.GetDuration("scan.interval"), leaseService.Guard(func(ctx context.Context) {
+			start := clk.Now()
 			slog.InfoContext(ctx, "Finding new episodes for series on watchlist")
-			if err := seriesService.FindNewEpisodes(ctx); err != nil {
-				slog.ErrorContext(ctx, "Error occurred while finding new episodes", "error", err)
+			if run, err := jobsService.FindNewEpisodes(ctx); errors.Is(err, ErrScanRunning) {
+				slog.InfoContext(ctx, "Skipping scan since one started from the admin API is still running")
+				return
+			} else if err != nil {
+				slog.ErrorContext(ctx, "Error occurred while finding new episodes", "error", err, "series_failed", run.SeriesFailed)
 				return
 			}
-			slog.InfoContext(ctx, "Finished looking for new episodes", "duration", time.Since(start).String())
-		})
+			slog.InfoContext(ctx, "Finished looking for new episodes", "duration", clk.Since(start).String())
+		}))
+		c.Every(time.Minute*5, leaseService.Guard(func(ctx context.Context) {
+			start := clk.Now()
+			slog.InfoContext(ctx, "Sending reminders for upcoming episodes")
+			if err := remindersService.SendReminders(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error occurred while sending reminders", "error", err)
+				return
+			}
+			slog.InfoContext(ctx, "Finished sending reminders", "duration", clk.Since(start).String())
+		}))
+		c.Every(time.Minute, leaseService.Guard(func(ctx context.Context) {
+			if err := digestService.SendDueDigests(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error occurred while sending digests", "error", err)
+			}
+		}))
 
+		// Stop waits for running jobs so the lease is released first. That cancels the context the jobs run with
+		// so shutting down doesn't wait for a whole scan
 		c.Start()
-		defer c.Stop()
+		defer func() {
+			leaseCancel()
+			<-leaseDone
+			c.Stop()
+		}()
+
+		// Delivering new episodes separately from finding them so failed sends are retried without holding up the search
+		go outboxService.RunDispatcher(ctx, time.Minute, leaseService.Held)
 
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-		http.HandleFunc("GET /shutdown", func(w http.ResponseWriter, r *http.Request) {
-			slog.InfoContext(ctx, "Received shutdown command from HTTP server")
-			fmt.Fprintln(w, "Shutting down...")
+		adminAPI.Register(http.DefaultServeMux, func() {
 			signal.Stop(sigs)
-			sigs <- syscall.SIGTERM
+			select {
+			case sigs <- syscall.SIGTERM:
+			default:
+			}
+		})
+		http.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+			status, err := jobsService.GetStatus(r.Context(), 10)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to get job status", "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			// Responding with a 503 when unhealthy so monitors don't need to read the body
+			w.Header().Set("Content-Type", "application/json")
+			if !status.Healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(w).Encode(status)
+		})
+		http.Handle("GET /metrics", metrics.Handler())
+		http.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+			// The bot is alive as long as it can answer
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{"alive":true}`)
+		})
+		http.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+			readiness := healthService.Readiness(r.Context())
+
+			w.Header().Set("Content-Type", "application/json")
+			if !readiness.Ready {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(w).Encode(readiness)
 		})
 
 		server := http.Server{Addr: viper.GetString("addr")}
 		go server.ListenAndServe()
-		httpCtx, _ := context.WithTimeout(context.Background(), time.Second*5)
+		httpCtx, httpCancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer httpCancel()
 		defer server.Shutdown(httpCtx)
 
 		<-sigs
@@ -78,41 +165,103 @@ var rootCommand = &cobra.Command{
 	},
 }
 
-//go:embed migrations/*.sql
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
 var migrationFS embed.FS
 
+// migrationDirs are the directories of the migrations for each goose dialect. Postgres starts from a single
+// migration that creates the schema SQLite had at that version. Every migration after it is written for both
+// dialects with the same version
+var migrationDirs = map[string]string{
+	"sqlite3":  "migrations/sqlite",
+	"postgres": "migrations/postgres",
+}
+
+// setupGoose sets the goose dialect for the database's driver and returns the directory of its migrations
+func setupGoose(db *sqlx.DB) (string, error) {
+	dialect := "sqlite3"
+	if sqlx.BindType(db.DriverName()) == sqlx.DOLLAR {
+		dialect = "postgres"
+	}
+
+	goose.SetBaseFS(migrationFS)
+	return migrationDirs[dialect], goose.SetDialect(dialect)
+}
+
+// latestMigration returns the version of the newest migration for the database's driver
+func latestMigration(db *sqlx.DB) (int64, error) {
+	dir, err := setupGoose(db)
+	if err != nil {
+		return 0, err
+	}
+
+	migrations, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, err
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return 0, err
+	}
+
+	return last.Version, nil
+}
+
 var migrateCommand = &cobra.Command{
 	Use:   "migrate",
 	Short: "Applies all available migrations.",
 	Args:  cobra.NoArgs,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		return goose.SetDialect("sqlite3")
-	},
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		cmd.SilenceUsage = true
 		defer utils.ReturnPanic(&err)
 
 		database := srvCtn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+		dir, err := setupGoose(database)
+		if err != nil {
+			return err
+		}
 
-		goose.SetBaseFS(migrationFS)
-
-		return goose.Up(database.DB, "migrations")
+		return goose.Up(database.DB, dir)
 	},
 }
 
 var makeMigrationCommand = &cobra.Command{
 	Use:   "make:migration",
-	Short: "Create writes a new blank migration file.",
+	Short: "Create writes a new blank migration file for every dialect.",
 	Args:  cobra.ExactArgs(1),
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		return goose.SetDialect("sqlite3")
-	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
 
 		migrationName := args[0]
+		sqliteDir, postgresDir := migrationDirs["sqlite3"], migrationDirs["postgres"]
+		before, err := os.ReadDir(sqliteDir)
+		if err != nil {
+			return err
+		}
+		if err = goose.Create(nil, sqliteDir, migrationName, "sql"); err != nil {
+			return err
+		}
+		after, err := os.ReadDir(sqliteDir)
+		if err != nil {
+			return err
+		}
 
-		return goose.Create(nil, "migrations", migrationName, "sql")
+		// Copying the new migration instead of creating it again so the version is the same for both dialects
+		for _, entry := range after {
+			if slices.ContainsFunc(before, func(e os.DirEntry) bool { return e.Name() == entry.Name() }) {
+				continue
+			}
+
+			b, err := os.ReadFile(filepath.Join(sqliteDir, entry.Name()))
+			if err != nil {
+				return err
+			}
+			if err = os.WriteFile(filepath.Join(postgresDir, entry.Name()), b, 0644); err != nil {
+				return err
+			}
+			fmt.Println("Copied new migration to", filepath.Join(postgresDir, entry.Name()))
+		}
+
+		return nil
 	},
 }
 
@@ -120,18 +269,17 @@ var rollbackMigrationCommand = &cobra.Command{
 	Use:   "migrate:rollback",
 	Short: "Rolls back a single migration from the current version.",
 	Args:  cobra.NoArgs,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		return goose.SetDialect("sqlite3")
-	},
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		cmd.SilenceUsage = true
 		defer utils.ReturnPanic(&err)
 
 		database := srvCtn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+		dir, err := setupGoose(database)
+		if err != nil {
+			return err
+		}
 
-		goose.SetBaseFS(migrationFS)
-
-		return goose.Down(database.DB, "migrations")
+		return goose.Down(database.DB, dir)
 	},
 }
 
@@ -164,14 +312,17 @@ var findNewEpisodesCommand = &cobra.Command{
 		ctx, cancel := context.WithCancel(cmd.Context())
 		defer cancel()
 
-		seriesSrv := srvCtn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
+		jobsSrv := srvCtn.Get(SrvCtnKeyJobsSrv).(*JobsService)
 
-		start := time.Now()
-		if err := seriesSrv.FindNewEpisodes(ctx); err != nil {
+		run, err := jobsSrv.FindNewEpisodes(ctx)
+		fmt.Printf("Checked %d, skipped %d and failed %d series and found %d episode(s) with %d TMDB call(s)\n",
+			run.SeriesChecked, run.SeriesSkipped, run.SeriesFailed, run.EpisodesNotified, run.TMDBCalls,
+		)
+		if err != nil {
 			return err
 		}
 
-		fmt.Println("Finished looking for new episodes. Took", time.Since(start).String())
+		fmt.Println("Finished looking for new episodes. Took", run.Duration().String())
 		return nil
 	},
 }
@@ -186,7 +337,7 @@ var deleteEpisodeNotificationsCommand = &cobra.Command{
 		ctx, cancel := context.WithCancel(cmd.Context())
 		defer cancel()
 
-		notiRepo := srvCtn.Get(SrvCtnKeyNotificationsRepo).(*NotificationsRepo)
+		notiRepo := srvCtn.Get(SrvCtnKeyNotificationsRepo).(NotificationsRepo)
 
 		start := time.Now()
 		n, err := notiRepo.DeleteAllNotifications(ctx)
@@ -199,6 +350,205 @@ var deleteEpisodeNotificationsCommand = &cobra.Command{
 	},
 }
 
+var setSeriesSpecialsCommand = &cobra.Command{
+	Use:   "series:specials <series_id> <true|false>",
+	Short: "Sets whether subscribers are notified about specials (season 0) of a series",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		cmd.SilenceUsage = true
+		defer utils.ReturnPanic(&err)
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		seriesID, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		include, err := strconv.ParseBool(args[1])
+		if err != nil {
+			return err
+		}
+
+		seriesSrv := srvCtn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
+		seriesRepo := srvCtn.Get(SrvCtnKeySeriesRepo).(SeriesRepo)
+
+		// Making sure the series is cached so the setting has a row to live on
+		series, _, err := seriesSrv.GetSeriesDetails(ctx, seriesID)
+		if err != nil {
+			return err
+		}
+
+		if err := seriesRepo.SetIncludeSpecials(ctx, seriesID, include); err != nil {
+			return err
+		}
+
+		state := "excluded"
+		if include {
+			state = "included"
+		}
+
+		fmt.Printf("Specials for '%s' are now %s\n", series.Name, state)
+		return nil
+	},
+}
+
+var setSeriesCheckIntervalCommand = &cobra.Command{
+	Use:   "series:check_interval <series_id> <interval|auto>",
+	Short: "Sets how often a series is checked for new episodes, e.g. 1h, or auto to use the poll schedule",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		cmd.SilenceUsage = true
+		defer utils.ReturnPanic(&err)
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		seriesID, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		var interval time.Duration
+		if args[1] != "auto" {
+			if interval, err = time.ParseDuration(args[1]); err != nil {
+				return err
+			} else if interval <= 0 {
+				return fmt.Errorf("interval must be positive")
+			}
+		}
+
+		seriesSrv := srvCtn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
+		series, err := seriesSrv.SetCheckInterval(ctx, seriesID, interval)
+		if err != nil {
+			return err
+		}
+
+		if interval == 0 {
+			fmt.Printf("'%s' is now checked on the poll schedule\n", series.Name)
+		} else {
+			fmt.Printf("'%s' is now checked every %s\n", series.Name, interval)
+		}
+		return nil
+	},
+}
+
+// legacyGuildFromConfig returns the guild and notifications channel the bot was configured with before it
+// supported multiple guilds. Zero is returned for either that isn't set
+func legacyGuildFromConfig(viper *viper.Viper) (guildID, channelID uint64, err error) {
+	if v := viper.GetString("discord.server_id"); v != "" {
+		if guildID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("parsing discord.server_id: %w", err)
+		}
+	}
+	if v := viper.GetString("discord.notifications_channel_id"); v != "" {
+		if channelID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("parsing discord.notifications_channel_id: %w", err)
+		}
+	}
+
+	return guildID, channelID, nil
+}
+
+// adoptLegacySubscriptions moves subscriptions made before the bot supported multiple guilds into the guild set
+// by discord.server_id. Nobody is notified about legacy subscriptions until they're moved so an error is logged
+// when there's no guild to move them into
+func adoptLegacySubscriptions(ctx context.Context, viper *viper.Viper, guildsSrv *GuildsService) error {
+	guildID, channelID, err := legacyGuildFromConfig(viper)
+	if err != nil {
+		return err
+	}
+
+	if guildID == 0 {
+		n, err := guildsSrv.CountLegacySubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			slog.ErrorContext(ctx, "Subscriptions made before multi-guild support aren't in a guild so nobody is notified about them. Set discord.server_id or run guilds:claim_legacy to move them", "subscriptions", n)
+		}
+		return nil
+	}
+
+	n, err := guildsSrv.AdoptLegacySubscriptions(ctx, guildID, channelID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "Moved subscriptions made before multi-guild support into guild", "guild_id", guildID, "subscriptions", n)
+	}
+
+	return nil
+}
+
+var claimLegacySubscriptionsCommand = &cobra.Command{
+	Use:   "guilds:claim_legacy",
+	Short: "Moves subscriptions made before multi-guild support into the guild set by discord.server_id",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		cmd.SilenceUsage = true
+		defer utils.ReturnPanic(&err)
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		viper := srvCtn.Get(SrvCtnKeyViper).(*viper.Viper)
+		guildsSrv := srvCtn.Get(SrvCtnKeyGuildsSrv).(*GuildsService)
+
+		guildID, channelID, err := legacyGuildFromConfig(viper)
+		if err != nil {
+			return err
+		}
+		if guildID == 0 {
+			return errors.New("discord.server_id must be set to claim legacy subscriptions")
+		}
+
+		n, err := guildsSrv.AdoptLegacySubscriptions(ctx, guildID, channelID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Moved %d subscription(s) into guild %d\n", n, guildID)
+		return nil
+	},
+}
+
+var jobsHistoryCommand = &cobra.Command{
+	Use:   "jobs:history [limit]",
+	Short: "Lists the latest runs of the scan for new episodes",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		cmd.SilenceUsage = true
+		defer utils.ReturnPanic(&err)
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		limit := uint64(20)
+		if len(args) > 0 {
+			if limit, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+				return err
+			}
+		}
+
+		jobsSrv := srvCtn.Get(SrvCtnKeyJobsSrv).(*JobsService)
+		status, err := jobsSrv.GetStatus(ctx, limit)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STARTED\tDURATION\tCHECKED\tSKIPPED\tFAILED\tEPISODES\tTMDB CALLS\tERROR")
+		for _, run := range status.Runs {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+				run.StartedAt.Local().Format(time.DateTime), run.Duration(), run.SeriesChecked, run.SeriesSkipped,
+				run.SeriesFailed, run.EpisodesNotified, run.TMDBCalls, run.Error.V,
+			)
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Println("Healthy:", status.Healthy)
+		return nil
+	},
+}
+
 func init() {
 	rootCommand.AddCommand(
 		migrateCommand,
@@ -207,5 +557,9 @@ func init() {
 		registerDiscordCommandsCommand,
 		findNewEpisodesCommand,
 		deleteEpisodeNotificationsCommand,
+		setSeriesSpecialsCommand,
+		setSeriesCheckIntervalCommand,
+		claimLegacySubscriptionsCommand,
+		jobsHistoryCommand,
 	)
 }
@@ -6,11 +6,14 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/bwmarrin/discordgo"
 	"github.com/bwmarrin/snowflake"
 	"github.com/duke605/tv-bot/moviedb"
 	"github.com/duke605/tv-bot/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/sarulabs/di"
@@ -27,6 +30,7 @@ const (
 	SrvCtnKeyViper             string = "viper"
 	SrvCtnKeyMovieDBClient     string = "moviedbClient"
 	SrvCtnKeyDiscord           string = "discord"
+	SrvCtnKeyDiscordSender     string = "discordSender"
 	SrvCtnKeySubsRepo          string = "subsRepo"
 	SrvCtnKeySnowflakeGen      string = "snowflakeGenerator"
 	SrvCtnKeyNotificationsRepo string = "notificationsRepo"
@@ -34,6 +38,25 @@ const (
 	SrvCtnKeySubsSrv           string = "subsService"
 	SrvCtnKeyDiscordCommandSrv string = "discordCommandService"
 	SrvCtnKeySeriesRepo        string = "seriesRepo"
+	SrvCtnKeyPrefsRepo         string = "prefsRepo"
+	SrvCtnKeyPrefsSrv          string = "prefsService"
+	SrvCtnKeyGuildsRepo        string = "guildsRepo"
+	SrvCtnKeyGuildsSrv         string = "guildsService"
+	SrvCtnKeyRemindersRepo     string = "remindersRepo"
+	SrvCtnKeyRemindersSrv      string = "remindersService"
+	SrvCtnKeyDigestRepo        string = "digestRepo"
+	SrvCtnKeyDigestSrv         string = "digestService"
+	SrvCtnKeyOutboxRepo        string = "outboxRepo"
+	SrvCtnKeyOutboxSrv         string = "outboxService"
+	SrvCtnKeyWatermarksRepo    string = "watermarksRepo"
+	SrvCtnKeyClock             string = "clock"
+	SrvCtnKeyJobRunsRepo       string = "jobRunsRepo"
+	SrvCtnKeyJobsSrv           string = "jobsService"
+	SrvCtnKeyLeasesRepo        string = "leasesRepo"
+	SrvCtnKeyLeaseSrv          string = "leaseService"
+	SrvCtnKeyMetrics           string = "metrics"
+	SrvCtnKeyHealthSrv         string = "healthService"
+	SrvCtnKeyAdminAPI          string = "adminAPI"
 )
 
 func init() {
@@ -43,21 +66,39 @@ func init() {
 	}()
 
 	if err := builder.Add(di.Def{
+		Name: SrvCtnKeyClock,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return clock.New(), nil
+		},
+	}, di.Def{
 		Name: SrvCtnKeyDatabase,
 		Close: func(obj interface{}) error {
 			return (obj.(*sqlx.DB)).Close()
 		},
 		Build: func(ctn di.Container) (interface{}, error) {
 			viper := ctn.Get(SrvCtnKeyViper).(*viper.Viper)
-			connStr := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", viper.GetString("db.file"))
+			driver := viper.GetString("db.driver")
+			dsn := viper.GetString("db.dsn")
+			if driver == "sqlite3" && dsn == "" {
+				dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", viper.GetString("db.file"))
+			}
 
-			return sqlx.Connect("sqlite3", connStr)
+			return sqlx.Connect(driver, dsn)
 		},
 	}, di.Def{
 		Name: SrvCtnKeyViper,
 		Build: func(ctn di.Container) (interface{}, error) {
 			v := viper.New()
 			v.SetConfigFile(".env.yaml")
+			v.SetDefault("db.driver", "sqlite3")
+			v.SetDefault("scan.workers", 4)
+			v.SetDefault("scan.interval", defaultPollSchedule.Interval)
+			v.SetDefault("scan.air_window", defaultPollSchedule.AirWindow)
+			v.SetDefault("scan.upcoming_interval", defaultPollSchedule.UpcomingInterval)
+			v.SetDefault("scan.unannounced_interval", defaultPollSchedule.UnannouncedInterval)
+			v.SetDefault("scan.hiatus_after", defaultPollSchedule.HiatusAfter)
+			v.SetDefault("scan.hiatus_interval", defaultPollSchedule.HiatusInterval)
+			v.SetDefault("lease.ttl", time.Minute)
 			v.AutomaticEnv()
 			if err := v.ReadInConfig(); err != nil {
 				return nil, err
@@ -73,21 +114,29 @@ func init() {
 		Name: SrvCtnKeyMovieDBClient,
 		Build: func(ctn di.Container) (interface{}, error) {
 			viper := ctn.Get(SrvCtnKeyViper).(*viper.Viper)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
 			t := oauth2.StaticTokenSource(&oauth2.Token{
 				AccessToken: viper.GetString("moviedb.access_token"),
 				TokenType:   "bearer",
 			})
 			httpClient := oauth2.NewClient(context.Background(), t)
-			rl := ratelimit.New(2)
+
+			metrics := ctn.Get(SrvCtnKeyMetrics).(*Metrics)
 
 			return moviedb.NewClient(viper.GetString("moviedb.base_url"),
 				moviedb.ClientOptionWithHTTPClient(httpClient),
-				moviedb.ClientOptionGlobalRequestOption(func(r *http.Request) *http.Request {
-					slog.Debug("Hitting TheMovieDB endpoint", slog.String("url", r.URL.String()))
-					return r
+				moviedb.ClientOptionObserver(metrics),
+				moviedb.ClientOptionRateLimit(ratelimit.New(2, ratelimit.WithClock(clk))),
+				moviedb.ClientOptionRetryPolicy(moviedb.RetryPolicy{
+					MaxAttempts: 4,
+					BaseDelay:   time.Second,
+					MaxDelay:    time.Second * 30,
 				}),
 				moviedb.ClientOptionGlobalRequestOption(func(r *http.Request) *http.Request {
-					rl.Take()
+					slog.Debug("Hitting TheMovieDB endpoint",
+						slog.String("url", r.URL.String()),
+						slog.Int("attempt", moviedb.RequestAttempt(r)),
+					)
 					return r
 				}),
 			)
@@ -102,17 +151,24 @@ func init() {
 			}
 
 			d.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-				fmt.Println("Discord bot ready!")
+				slog.InfoContext(context.Background(), "Discord bot ready", "guilds", len(r.Guilds))
 			})
 
 			return d, nil
 		},
+	}, di.Def{
+		Name: SrvCtnKeyDiscordSender,
+		Build: func(ctn di.Container) (interface{}, error) {
+			metrics := ctn.Get(SrvCtnKeyMetrics).(*Metrics)
+
+			return metrics.WrapDiscordSender(ctn.Get(SrvCtnKeyDiscord).(*discordgo.Session)), nil
+		},
 	}, di.Def{
 		Name: "subsRepo",
 		Build: func(ctn di.Container) (interface{}, error) {
 			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
 
-			return NewSubscriptionsRepo(db), nil
+			return NewSQLSubscriptionsRepo(db), nil
 		},
 	}, di.Def{
 		Name: SrvCtnKeySnowflakeGen,
@@ -124,42 +180,239 @@ func init() {
 		Build: func(ctn di.Container) (interface{}, error) {
 			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
 
-			return NewNotificationsRepo(db), nil
+			return NewSQLNotificationsRepo(db), nil
 		},
 	}, di.Def{
 		Name: SrvCtnKeySeriesSrv,
 		Build: func(ctn di.Container) (interface{}, error) {
-			notificationsRepo := ctn.Get(SrvCtnKeyNotificationsRepo).(*NotificationsRepo)
+			notificationsRepo := ctn.Get(SrvCtnKeyNotificationsRepo).(NotificationsRepo)
 			subSrv := ctn.Get(SrvCtnKeySubsSrv).(*SubscriptionsService)
-			discord := ctn.Get(SrvCtnKeyDiscord).(*discordgo.Session)
+			prefsSrv := ctn.Get(SrvCtnKeyPrefsSrv).(*PreferencesService)
+			guildsSrv := ctn.Get(SrvCtnKeyGuildsSrv).(*GuildsService)
+			digestSrv := ctn.Get(SrvCtnKeyDigestSrv).(*DigestService)
+			outboxSrv := ctn.Get(SrvCtnKeyOutboxSrv).(*OutboxService)
+			discord := ctn.Get(SrvCtnKeyDiscordSender).(utils.DiscordSender)
 			moviedbClient := ctn.Get(SrvCtnKeyMovieDBClient).(moviedb.Client)
-			seriesRepo := ctn.Get(SrvCtnKeySeriesRepo).(*SeriesRepo)
+			seriesRepo := ctn.Get(SrvCtnKeySeriesRepo).(SeriesRepo)
+			watermarksRepo := ctn.Get(SrvCtnKeyWatermarksRepo).(*WatermarksRepo)
+			viper := ctn.Get(SrvCtnKeyViper).(*viper.Viper)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			schedule := PollSchedule{
+				Interval:            viper.GetDuration("scan.interval"),
+				AirWindow:           viper.GetDuration("scan.air_window"),
+				UpcomingInterval:    viper.GetDuration("scan.upcoming_interval"),
+				UnannouncedInterval: viper.GetDuration("scan.unannounced_interval"),
+				HiatusAfter:         viper.GetDuration("scan.hiatus_after"),
+				HiatusInterval:      viper.GetDuration("scan.hiatus_interval"),
+			}
 
-			return NewSeriesService(notificationsRepo, subSrv, discord, moviedbClient, seriesRepo), nil
+			return NewSeriesService(
+				notificationsRepo, subSrv, prefsSrv, guildsSrv, digestSrv, outboxSrv, discord, moviedbClient, seriesRepo, watermarksRepo,
+				viper.GetInt("scan.workers"), schedule, clk,
+			), nil
 		},
 	}, di.Def{
 		Name: SrvCtnKeySubsSrv,
 		Build: func(ctn di.Container) (interface{}, error) {
-			subsRepo := ctn.Get(SrvCtnKeySubsRepo).(*SubscriptionsRepo)
+			subsRepo := ctn.Get(SrvCtnKeySubsRepo).(SubscriptionsRepo)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
 
-			return NewSubscriptionsService(subsRepo), nil
+			return NewSubscriptionsService(subsRepo, clk), nil
 		},
 	}, di.Def{
 		Name: SrvCtnKeyDiscordCommandSrv,
 		Build: func(ctn di.Container) (interface{}, error) {
 			ctn.Get(SrvCtnKeyViper)
-			discord := ctn.Get(SrvCtnKeyDiscord).(*discordgo.Session)
+			discord := ctn.Get(SrvCtnKeyDiscordSender).(utils.DiscordSender)
 			seriesSrv := ctn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
 			subsService := ctn.Get(SrvCtnKeySubsSrv).(*SubscriptionsService)
+			prefsService := ctn.Get(SrvCtnKeyPrefsSrv).(*PreferencesService)
+			guildsService := ctn.Get(SrvCtnKeyGuildsSrv).(*GuildsService)
+			jobsService := ctn.Get(SrvCtnKeyJobsSrv).(*JobsService)
+			metrics := ctn.Get(SrvCtnKeyMetrics).(*Metrics)
 
-			return NewDiscordCommandService(discord, seriesSrv, subsService), nil
+			return NewDiscordCommandService(discord, seriesSrv, subsService, prefsService, guildsService, jobsService, metrics), nil
 		},
 	}, di.Def{
 		Name: SrvCtnKeySeriesRepo,
 		Build: func(ctn di.Container) (interface{}, error) {
 			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
 
-			return NewSeriesRepo(db), nil
+			return NewSQLSeriesRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyPrefsRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewPreferencesRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyPrefsSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			prefsRepo := ctn.Get(SrvCtnKeyPrefsRepo).(*PreferencesRepo)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			return NewPreferencesService(prefsRepo, clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyGuildsRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewGuildsRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyGuildsSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			guildsRepo := ctn.Get(SrvCtnKeyGuildsRepo).(*GuildsRepo)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			return NewGuildsService(guildsRepo, clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyRemindersRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewRemindersRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyRemindersSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			remindersRepo := ctn.Get(SrvCtnKeyRemindersRepo).(*RemindersRepo)
+			seriesRepo := ctn.Get(SrvCtnKeySeriesRepo).(SeriesRepo)
+			subsSrv := ctn.Get(SrvCtnKeySubsSrv).(*SubscriptionsService)
+			prefsSrv := ctn.Get(SrvCtnKeyPrefsSrv).(*PreferencesService)
+			discord := ctn.Get(SrvCtnKeyDiscordSender).(utils.DiscordSender)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			return NewRemindersService(remindersRepo, seriesRepo, subsSrv, prefsSrv, discord, clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyDigestRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewDigestRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyDigestSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			digestRepo := ctn.Get(SrvCtnKeyDigestRepo).(*DigestRepo)
+			seriesRepo := ctn.Get(SrvCtnKeySeriesRepo).(SeriesRepo)
+			prefsSrv := ctn.Get(SrvCtnKeyPrefsSrv).(*PreferencesService)
+			discord := ctn.Get(SrvCtnKeyDiscordSender).(utils.DiscordSender)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			return NewDigestService(digestRepo, seriesRepo, prefsSrv, discord, clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyOutboxRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewOutboxRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyOutboxSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			outboxRepo := ctn.Get(SrvCtnKeyOutboxRepo).(*OutboxRepo)
+			guildsSrv := ctn.Get(SrvCtnKeyGuildsSrv).(*GuildsService)
+			discord := ctn.Get(SrvCtnKeyDiscordSender).(utils.DiscordSender)
+			metrics := ctn.Get(SrvCtnKeyMetrics).(*Metrics)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			return NewOutboxService(outboxRepo, guildsSrv, discord, metrics, clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyWatermarksRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewWatermarksRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyJobRunsRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewJobRunsRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyJobsSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			jobRunsRepo := ctn.Get(SrvCtnKeyJobRunsRepo).(*JobRunsRepo)
+			seriesSrv := ctn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
+			metrics := ctn.Get(SrvCtnKeyMetrics).(*Metrics)
+			viper := ctn.Get(SrvCtnKeyViper).(*viper.Viper)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			// Allowing a couple of scans to be missed before the bot is considered unhealthy if no window is configured
+			scanWindow := viper.GetDuration("health.scan_window")
+			if scanWindow <= 0 {
+				scanWindow = viper.GetDuration("scan.interval") * 3
+			}
+
+			return NewJobsService(jobRunsRepo, seriesSrv, metrics, scanWindow, clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyLeasesRepo,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+
+			return NewLeasesRepo(db), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyLeaseSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			leasesRepo := ctn.Get(SrvCtnKeyLeasesRepo).(*LeasesRepo)
+			viper := ctn.Get(SrvCtnKeyViper).(*viper.Viper)
+			clk := ctn.Get(SrvCtnKeyClock).(clock.Clock)
+
+			// Making up an ID that is unique to this process if one isn't configured
+			instanceID := viper.GetString("instance_id")
+			if instanceID == "" {
+				hostname, _ := os.Hostname()
+				instanceID = fmt.Sprintf("%s-%s", hostname, ctn.Get(SrvCtnKeySnowflakeGen).(*snowflake.Node).Generate())
+			}
+
+			return NewLeaseService(leasesRepo, instanceID, viper.GetDuration("lease.ttl"), clk), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyHealthSrv,
+		Build: func(ctn di.Container) (interface{}, error) {
+			db := ctn.Get(SrvCtnKeyDatabase).(*sqlx.DB)
+			jobsSrv := ctn.Get(SrvCtnKeyJobsSrv).(*JobsService)
+
+			latest, err := latestMigration(db)
+			if err != nil {
+				return nil, err
+			}
+
+			return NewHealthService(db, jobsSrv, latest), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyAdminAPI,
+		Build: func(ctn di.Container) (interface{}, error) {
+			seriesSrv := ctn.Get(SrvCtnKeySeriesSrv).(*SeriesService)
+			subsSrv := ctn.Get(SrvCtnKeySubsSrv).(*SubscriptionsService)
+			jobsSrv := ctn.Get(SrvCtnKeyJobsSrv).(*JobsService)
+			leaseSrv := ctn.Get(SrvCtnKeyLeaseSrv).(*LeaseService)
+			viper := ctn.Get(SrvCtnKeyViper).(*viper.Viper)
+
+			return NewAdminAPI(viper.GetString("admin.token"), seriesSrv, subsSrv, jobsSrv, leaseSrv), nil
+		},
+	}, di.Def{
+		Name: SrvCtnKeyMetrics,
+		Build: func(ctn di.Container) (interface{}, error) {
+			subsRepo := ctn.Get(SrvCtnKeySubsRepo).(SubscriptionsRepo)
+
+			metrics := NewMetrics()
+			metrics.RegisterSubscriptions(subsRepo)
+
+			return metrics, nil
 		},
 	}); err != nil {
 		panic(err)
@@ -167,7 +420,8 @@ func init() {
 }
 
 func init() {
-	f := utils.NewDateFile(afero.NewOsFs(), "log.jsonl", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	clk := srvCtn.Get(SrvCtnKeyClock).(clock.Clock)
+	f := utils.NewDateFile(afero.NewOsFs(), clk, "log.jsonl", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	l := slog.NewJSONHandler(f, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/duke605/tv-bot/moviedb"
+	"github.com/duke605/tv-bot/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	_ moviedb.Observer    = (*Metrics)(nil)
+	_ utils.DiscordSender = (*metricsDiscordSender)(nil)
+	_ utils.DiscordSender = (*commandResultRecorder)(nil)
+)
+
+// The results slash commands are counted under
+const (
+	CommandResultSuccess = "success"
+	CommandResultWarning = "warning"
+	CommandResultError   = "error"
+	CommandResultUnknown = "unknown"
+)
+
+// Metrics are the Prometheus metrics served on /metrics. Every Metrics has its own registry so they don't clash
+// when more than one is created, like in the tests
+type Metrics struct {
+	registry *prometheus.Registry
+
+	tmdbRequests       *prometheus.CounterVec
+	tmdbLatency        *prometheus.HistogramVec
+	tmdbRateLimitWaits prometheus.Histogram
+	discordSends       *prometheus.CounterVec
+	discordFailures    *prometheus.CounterVec
+	scanDuration       prometheus.Histogram
+	scanSeries         *prometheus.CounterVec
+	notificationsSent  *prometheus.CounterVec
+	commands           *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		tmdbRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tvbot_tmdb_requests_total",
+			Help: "Attempts at sending a request to TMDB by route and status code. The status is 0 for network errors",
+		}, []string{"route", "status"}),
+		tmdbLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tvbot_tmdb_request_duration_seconds",
+			Help:    "How long TMDB took to respond by route",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+		tmdbRateLimitWaits: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tvbot_tmdb_rate_limit_wait_seconds",
+			Help:    "How long requests to TMDB waited on the rate limiter before being sent",
+			Buckets: []float64{0.001, 0.01, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}),
+		discordSends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tvbot_discord_requests_total",
+			Help: "Requests sent to Discord by method",
+		}, []string{"method"}),
+		discordFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tvbot_discord_request_failures_total",
+			Help: "Requests sent to Discord that failed by method",
+		}, []string{"method"}),
+		scanDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tvbot_scan_duration_seconds",
+			Help:    "How long scans for new episodes took",
+			Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600},
+		}),
+		scanSeries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tvbot_scan_series_total",
+			Help: "Series looked at by scans for new episodes by whether they were fetched, skipped or failed",
+		}, []string{"result"}),
+		notificationsSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tvbot_notifications_sent_total",
+			Help: "Episodes delivered from the outbox by whether they were posted in a channel or sent as a direct message",
+		}, []string{"target"}),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tvbot_commands_total",
+			Help: "Slash commands invoked by name and result",
+		}, []string{"command", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.tmdbRequests,
+		m.tmdbLatency,
+		m.tmdbRateLimitWaits,
+		m.discordSends,
+		m.discordFailures,
+		m.scanDuration,
+		m.scanSeries,
+		m.notificationsSent,
+		m.commands,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterSubscriptions reports how many subscriptions every series has. The subscriptions are counted every time
+// the metrics are scraped
+func (m *Metrics) RegisterSubscriptions(repo SubscriptionsRepo) {
+	m.registry.MustRegister(&subscriptionsCollector{
+		repo: repo,
+		desc: prometheus.NewDesc("tvbot_subscriptions", "Subscriptions to the series across every guild", []string{"series_id"}, nil),
+	})
+}
+
+func (m *Metrics) ObserveRequest(route string, status int, latency time.Duration) {
+	m.tmdbRequests.WithLabelValues(route, strconv.Itoa(status)).Inc()
+	m.tmdbLatency.WithLabelValues(route).Observe(latency.Seconds())
+}
+
+func (m *Metrics) ObserveRateLimitWait(wait time.Duration) {
+	m.tmdbRateLimitWaits.Observe(wait.Seconds())
+}
+
+// ObserveScan records how long the scan took and what happened to the series it looked at
+func (m *Metrics) ObserveScan(run *JobRun) {
+	m.scanDuration.Observe(run.Duration().Seconds())
+	m.scanSeries.WithLabelValues("fetched").Add(float64(run.SeriesChecked))
+	m.scanSeries.WithLabelValues("skipped").Add(float64(run.SeriesSkipped))
+	m.scanSeries.WithLabelValues("failed").Add(float64(run.SeriesFailed))
+}
+
+// ObserveNotificationsSent records that n episodes were delivered to the target
+func (m *Metrics) ObserveNotificationsSent(target NotificationTarget, n int) {
+	kind := "channel"
+	if target.UserID != 0 {
+		kind = "dm"
+	}
+
+	m.notificationsSent.WithLabelValues(kind).Add(float64(n))
+}
+
+func (m *Metrics) ObserveCommand(name, result string) {
+	m.commands.WithLabelValues(name, result).Inc()
+}
+
+// WrapDiscordSender returns a sender that counts the requests sent through it and the ones that failed
+func (m *Metrics) WrapDiscordSender(s utils.DiscordSender) utils.DiscordSender {
+	return &metricsDiscordSender{s, m}
+}
+
+type subscriptionsCollector struct {
+	repo SubscriptionsRepo
+	desc *prometheus.Desc
+}
+
+func (c *subscriptionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *subscriptionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	counts, err := c.repo.CountPerSeries(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count.V), strconv.FormatUint(count.T, 10))
+	}
+}
+
+type metricsDiscordSender struct {
+	utils.DiscordSender
+	metrics *Metrics
+}
+
+func (s *metricsDiscordSender) observe(method string, err error) {
+	s.metrics.discordSends.WithLabelValues(method).Inc()
+	if err != nil {
+		s.metrics.discordFailures.WithLabelValues(method).Inc()
+	}
+}
+
+func (s *metricsDiscordSender) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	ret, err := s.DiscordSender.ApplicationCommandBulkOverwrite(appID, guildID, commands, options...)
+	s.observe("ApplicationCommandBulkOverwrite", err)
+	return ret, err
+}
+
+func (s *metricsDiscordSender) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m, err := s.DiscordSender.ChannelMessage(channelID, messageID, options...)
+	s.observe("ChannelMessage", err)
+	return m, err
+}
+
+func (s *metricsDiscordSender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m, err := s.DiscordSender.ChannelMessageSendComplex(channelID, data, options...)
+	s.observe("ChannelMessageSendComplex", err)
+	return m, err
+}
+
+func (s *metricsDiscordSender) ChannelMessageEditComplex(me *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m, err := s.DiscordSender.ChannelMessageEditComplex(me, options...)
+	s.observe("ChannelMessageEditComplex", err)
+	return m, err
+}
+
+func (s *metricsDiscordSender) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	err := s.DiscordSender.InteractionRespond(interaction, resp, options...)
+	s.observe("InteractionRespond", err)
+	return err
+}
+
+func (s *metricsDiscordSender) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m, err := s.DiscordSender.InteractionResponseEdit(interaction, newresp, options...)
+	s.observe("InteractionResponseEdit", err)
+	return m, err
+}
+
+func (s *metricsDiscordSender) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	c, err := s.DiscordSender.UserChannelCreate(recipientID, options...)
+	s.observe("UserChannelCreate", err)
+	return c, err
+}
+
+// commandResultRecorder works out how a slash command went from the colour of the embeds it responds with. A
+// command is only considered to have succeeded if it never responded with a warning or an error
+type commandResultRecorder struct {
+	utils.DiscordSender
+
+	mu     sync.Mutex
+	result string
+}
+
+func newCommandResultRecorder(s utils.DiscordSender) *commandResultRecorder {
+	return &commandResultRecorder{DiscordSender: s, result: CommandResultSuccess}
+}
+
+func (r *commandResultRecorder) Result() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.result
+}
+
+func (r *commandResultRecorder) record(embeds []*discordgo.MessageEmbed, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, embed := range embeds {
+		if embed.Color == utils.DiscordColorError {
+			r.result = CommandResultError
+		} else if embed.Color == utils.DiscordColorWarning && r.result != CommandResultError {
+			r.result = CommandResultWarning
+		}
+	}
+	if err != nil {
+		r.result = CommandResultError
+	}
+}
+
+func (r *commandResultRecorder) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	err := r.DiscordSender.InteractionRespond(interaction, resp, options...)
+	if resp.Data != nil {
+		r.record(resp.Data.Embeds, err)
+	} else {
+		r.record(nil, err)
+	}
+
+	return err
+}
+
+func (r *commandResultRecorder) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m, err := r.DiscordSender.InteractionResponseEdit(interaction, newresp, options...)
+	if newresp.Embeds != nil {
+		r.record(*newresp.Embeds, err)
+	} else {
+		r.record(nil, err)
+	}
+
+	return m, err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/duke605/tv-bot/utils"
+	"github.com/duke605/tv-bot/utils/discordtest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandResultRecorder(t *testing.T) {
+	embed := func(color int) *discordgo.MessageEmbed {
+		return &discordgo.MessageEmbed{Color: color}
+	}
+	tests := []struct {
+		name    string
+		respond func(s utils.DiscordSender, i *discordgo.Interaction)
+		fail    bool
+		exp     string
+	}{
+		{
+			name: "succeeds when the command responds with a success",
+			respond: func(s utils.DiscordSender, i *discordgo.Interaction) {
+				s.InteractionRespond(i, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				})
+				s.InteractionResponseEdit(i, &discordgo.WebhookEdit{
+					Embeds: &[]*discordgo.MessageEmbed{embed(utils.DiscordColorSuccess)},
+				})
+			},
+			exp: CommandResultSuccess,
+		},
+		{
+			name: "warns when the command responds with a warning",
+			respond: func(s utils.DiscordSender, i *discordgo.Interaction) {
+				s.InteractionRespond(i, &discordgo.InteractionResponse{
+					Data: &discordgo.InteractionResponseData{
+						Embeds: []*discordgo.MessageEmbed{embed(utils.DiscordColorWarning)},
+					},
+				})
+			},
+			exp: CommandResultWarning,
+		},
+		{
+			name: "fails when the command responds with an error even if it responds again",
+			respond: func(s utils.DiscordSender, i *discordgo.Interaction) {
+				s.InteractionResponseEdit(i, &discordgo.WebhookEdit{
+					Embeds: &[]*discordgo.MessageEmbed{embed(utils.DiscordColorError)},
+				})
+				s.InteractionResponseEdit(i, &discordgo.WebhookEdit{
+					Embeds: &[]*discordgo.MessageEmbed{embed(utils.DiscordColorWarning)},
+				})
+			},
+			exp: CommandResultError,
+		},
+		{
+			name: "fails when the response can't be sent",
+			respond: func(s utils.DiscordSender, i *discordgo.Interaction) {
+				s.InteractionRespond(i, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				})
+			},
+			fail: true,
+			exp:  CommandResultError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			discord := discordtest.NewSender()
+			if test.fail {
+				discord.FailWith(errors.New("discord is down"))
+			}
+			recorder := newCommandResultRecorder(discord)
+
+			// Acting
+			test.respond(recorder, &discordgo.Interaction{ID: "1"})
+
+			// Asserting
+			assert.Equal(t, test.exp, recorder.Result())
+		})
+	}
+}
+
+func TestMetricsDiscordSenderCountsFailures(t *testing.T) {
+	// Arranging
+	discord := discordtest.NewSender()
+	metrics := NewMetrics()
+	sender := metrics.WrapDiscordSender(discord)
+
+	// Acting
+	_, err := sender.ChannelMessageSendComplex("1", &discordgo.MessageSend{Content: "sent"})
+	require.NoError(t, err)
+	discord.FailWith(errors.New("discord is down"))
+	_, err = sender.ChannelMessageSendComplex("1", &discordgo.MessageSend{Content: "not sent"})
+
+	// Asserting
+	assert.Error(t, err)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.discordSends.WithLabelValues("ChannelMessageSendComplex")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.discordFailures.WithLabelValues("ChannelMessageSendComplex")))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	subsRepo := NewMemorySubscriptionsRepo(NewMemoryStore())
+	for _, sub := range []*Subscription{
+		{GuildID: 1, SeriesID: 100, UserID: 10},
+		{GuildID: 1, SeriesID: 100, UserID: 11},
+		{GuildID: 1, SeriesID: 101, UserID: 10},
+	} {
+		require.NoError(t, subsRepo.Insert(ctx, sub))
+	}
+	metrics := NewMetrics()
+	metrics.RegisterSubscriptions(subsRepo)
+	metrics.ObserveRequest("/tv/{id}", http.StatusOK, time.Millisecond*20)
+	metrics.ObserveScan(&JobRun{DurationMS: 1500, SeriesChecked: 2, SeriesSkipped: 5})
+	metrics.ObserveCommand("subscribe", CommandResultSuccess)
+
+	// Acting
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Asserting
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	for _, line := range []string{
+		`tvbot_subscriptions{series_id="100"} 2`,
+		`tvbot_subscriptions{series_id="101"} 1`,
+		`tvbot_tmdb_requests_total{route="/tv/{id}",status="200"} 1`,
+		`tvbot_tmdb_request_duration_seconds_count{route="/tv/{id}"} 1`,
+		`tvbot_scan_duration_seconds_sum 1.5`,
+		`tvbot_scan_series_total{result="fetched"} 2`,
+		`tvbot_scan_series_total{result="skipped"} 5`,
+		`tvbot_commands_total{command="subscribe",result="success"} 1`,
+	} {
+		assert.Contains(t, body, line)
+	}
+}
@@ -0,0 +1,114 @@
+-- The schema as of the latest SQLite migration at the time Postgres support was added. Later migrations are
+-- added to both dialects with the same version
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE series (
+  id BIGINT NOT NULL PRIMARY KEY,
+  next_episode_air_date TIMESTAMPTZ,
+  data TEXT NOT NULL,
+  last_fetched_at TIMESTAMPTZ NOT NULL,
+  include_specials BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE user_preferences (
+  user_id BIGINT NOT NULL PRIMARY KEY,
+  delivery_mode VARCHAR(16) NOT NULL DEFAULT 'channel',
+  delivery_mode_changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  reminder_lead_minutes INT NOT NULL DEFAULT 0,
+  digest_frequency VARCHAR(16) NOT NULL DEFAULT '',
+  digest_minute INT NOT NULL DEFAULT 1200,
+  digest_weekday INT NOT NULL DEFAULT 0,
+  digest_timezone VARCHAR(64) NOT NULL DEFAULT '',
+  digest_last_sent_at TIMESTAMPTZ
+);
+
+CREATE TABLE guilds (
+  id BIGINT NOT NULL PRIMARY KEY,
+  notifications_channel_id BIGINT NOT NULL,
+  notifications_channel_set_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE subscriptions (
+  guild_id BIGINT NOT NULL DEFAULT 0,
+  series_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (guild_id, series_id, user_id)
+);
+
+CREATE TABLE notifications (
+  episode INT NOT NULL,
+  season INT NOT NULL,
+  series_id BIGINT NOT NULL,
+  guild_id BIGINT NOT NULL DEFAULT 0,
+  user_id BIGINT NOT NULL DEFAULT 0,
+  discord_message_id BIGINT NOT NULL,
+  discord_channel_id BIGINT NOT NULL DEFAULT 0,
+  embed_index INT NOT NULL DEFAULT 0,
+  complete BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT '1970-01-01 00:00:00+00',
+
+  PRIMARY KEY (episode, season, series_id, guild_id, user_id)
+);
+
+CREATE TABLE reminders (
+  series_id BIGINT NOT NULL,
+  season INT NOT NULL,
+  episode INT NOT NULL,
+  user_id BIGINT NOT NULL,
+  sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (series_id, season, episode, user_id)
+);
+
+CREATE TABLE digest_items (
+  user_id BIGINT NOT NULL,
+  series_id BIGINT NOT NULL,
+  season INT NOT NULL,
+  episode INT NOT NULL,
+  episode_name TEXT NOT NULL,
+  runtime INT NOT NULL,
+  air_date VARCHAR(10) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (user_id, series_id, season, episode)
+);
+
+CREATE TABLE outbox (
+  episode INT NOT NULL,
+  season INT NOT NULL,
+  series_id BIGINT NOT NULL,
+  guild_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  embed TEXT NOT NULL,
+  mention_ids TEXT NOT NULL,
+  complete BOOLEAN NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (episode, season, series_id, guild_id, user_id)
+);
+
+CREATE TABLE watermarks (
+  name VARCHAR(64) NOT NULL,
+  at TIMESTAMPTZ NOT NULL,
+
+  PRIMARY KEY (name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE watermarks;
+DROP TABLE outbox;
+DROP TABLE digest_items;
+DROP TABLE reminders;
+DROP TABLE notifications;
+DROP TABLE subscriptions;
+DROP TABLE guilds;
+DROP TABLE user_preferences;
+DROP TABLE series;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_preferences RENAME COLUMN delivery_mode_changed_at TO channel_delivery_started_at;
+ALTER TABLE user_preferences ADD COLUMN dm_delivery_started_at TIMESTAMPTZ;
+UPDATE user_preferences SET dm_delivery_started_at = channel_delivery_started_at;
+ALTER TABLE user_preferences ALTER COLUMN dm_delivery_started_at SET NOT NULL;
+ALTER TABLE user_preferences ALTER COLUMN dm_delivery_started_at SET DEFAULT CURRENT_TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_preferences DROP COLUMN dm_delivery_started_at;
+ALTER TABLE user_preferences RENAME COLUMN channel_delivery_started_at TO delivery_mode_changed_at;
+-- +goose StatementEnd
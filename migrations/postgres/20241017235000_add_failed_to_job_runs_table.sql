@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE job_runs ADD COLUMN failed BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE job_runs SET failed = error IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE job_runs DROP COLUMN failed;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `user_preferences` (
+  `user_id` BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+  `delivery_mode` VARCHAR(16) NOT NULL DEFAULT 'channel',
+  `delivery_mode_changed_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `user_preferences`;
+-- +goose StatementEnd
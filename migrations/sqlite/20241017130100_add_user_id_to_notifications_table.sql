@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `notifications_new` (
+  `episode` INT NOT NULL,
+  `season` INT NOT NULL,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+  `discord_message_id` BIGINT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`episode`, `season`, `series_id`, `user_id`)
+);
+
+INSERT INTO `notifications_new` (`episode`, `season`, `series_id`, `discord_message_id`)
+SELECT `episode`, `season`, `series_id`, `discord_message_id` FROM `notifications`;
+
+DROP TABLE `notifications`;
+ALTER TABLE `notifications_new` RENAME TO `notifications`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE `notifications_old` (
+  `episode` INT NOT NULL,
+  `season` INT NOT NULL,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `discord_message_id` BIGINT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`episode`, `season`, `series_id`)
+);
+
+INSERT INTO `notifications_old` (`episode`, `season`, `series_id`, `discord_message_id`)
+SELECT `episode`, `season`, `series_id`, `discord_message_id` FROM `notifications` WHERE `user_id` = 0;
+
+DROP TABLE `notifications`;
+ALTER TABLE `notifications_old` RENAME TO `notifications`;
+-- +goose StatementEnd
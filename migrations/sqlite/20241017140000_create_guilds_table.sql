@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `guilds` (
+  `id` BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+  `notifications_channel_id` BIGINT UNSIGNED NOT NULL,
+  `notifications_channel_set_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `guilds`;
+-- +goose StatementEnd
@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `subscriptions_new` (
+  `guild_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`guild_id`, `series_id`, `user_id`)
+);
+
+INSERT INTO `subscriptions_new` (`series_id`, `user_id`, `created_at`)
+SELECT `series_id`, `user_id`, `created_at` FROM `subscriptions`;
+
+DROP TABLE `subscriptions`;
+ALTER TABLE `subscriptions_new` RENAME TO `subscriptions`;
+
+CREATE TABLE `notifications_new` (
+  `episode` INT NOT NULL,
+  `season` INT NOT NULL,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `guild_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+  `user_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+  `discord_message_id` BIGINT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`episode`, `season`, `series_id`, `guild_id`, `user_id`)
+);
+
+INSERT INTO `notifications_new` (`episode`, `season`, `series_id`, `user_id`, `discord_message_id`)
+SELECT `episode`, `season`, `series_id`, `user_id`, `discord_message_id` FROM `notifications`;
+
+DROP TABLE `notifications`;
+ALTER TABLE `notifications_new` RENAME TO `notifications`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE `subscriptions_old` (
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`series_id`, `user_id`)
+);
+
+INSERT OR IGNORE INTO `subscriptions_old` (`series_id`, `user_id`, `created_at`)
+SELECT `series_id`, `user_id`, `created_at` FROM `subscriptions`;
+
+DROP TABLE `subscriptions`;
+ALTER TABLE `subscriptions_old` RENAME TO `subscriptions`;
+
+CREATE TABLE `notifications_old` (
+  `episode` INT NOT NULL,
+  `season` INT NOT NULL,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+  `discord_message_id` BIGINT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`episode`, `season`, `series_id`, `user_id`)
+);
+
+INSERT OR IGNORE INTO `notifications_old` (`episode`, `season`, `series_id`, `user_id`, `discord_message_id`)
+SELECT `episode`, `season`, `series_id`, `user_id`, `discord_message_id` FROM `notifications`;
+
+DROP TABLE `notifications`;
+ALTER TABLE `notifications_old` RENAME TO `notifications`;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `user_preferences` ADD COLUMN `reminder_lead_minutes` INT NOT NULL DEFAULT 0;
+
+CREATE TABLE `reminders` (
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `season` INT NOT NULL,
+  `episode` INT NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `sent_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`series_id`, `season`, `episode`, `user_id`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `reminders`;
+
+ALTER TABLE `user_preferences` DROP COLUMN `reminder_lead_minutes`;
+-- +goose StatementEnd
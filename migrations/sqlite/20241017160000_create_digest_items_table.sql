@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `user_preferences` ADD COLUMN `digest_frequency` VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE `user_preferences` ADD COLUMN `digest_minute` INT NOT NULL DEFAULT 1200;
+ALTER TABLE `user_preferences` ADD COLUMN `digest_weekday` INT NOT NULL DEFAULT 0;
+ALTER TABLE `user_preferences` ADD COLUMN `digest_timezone` VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE `user_preferences` ADD COLUMN `digest_last_sent_at` TIMESTAMP;
+
+CREATE TABLE `digest_items` (
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `season` INT NOT NULL,
+  `episode` INT NOT NULL,
+  `episode_name` TEXT NOT NULL,
+  `runtime` INT NOT NULL,
+  `air_date` VARCHAR(10) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`user_id`, `series_id`, `season`, `episode`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `digest_items`;
+
+ALTER TABLE `user_preferences` DROP COLUMN `digest_last_sent_at`;
+ALTER TABLE `user_preferences` DROP COLUMN `digest_timezone`;
+ALTER TABLE `user_preferences` DROP COLUMN `digest_weekday`;
+ALTER TABLE `user_preferences` DROP COLUMN `digest_minute`;
+ALTER TABLE `user_preferences` DROP COLUMN `digest_frequency`;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `notifications` ADD COLUMN `discord_channel_id` BIGINT UNSIGNED NOT NULL DEFAULT 0;
+ALTER TABLE `notifications` ADD COLUMN `embed_index` INT NOT NULL DEFAULT 0;
+ALTER TABLE `notifications` ADD COLUMN `complete` BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE `notifications` ADD COLUMN `created_at` TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `notifications` DROP COLUMN `created_at`;
+ALTER TABLE `notifications` DROP COLUMN `complete`;
+ALTER TABLE `notifications` DROP COLUMN `embed_index`;
+ALTER TABLE `notifications` DROP COLUMN `discord_channel_id`;
+-- +goose StatementEnd
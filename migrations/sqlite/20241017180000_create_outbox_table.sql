@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `outbox` (
+  `episode` INT NOT NULL,
+  `season` INT NOT NULL,
+  `series_id` BIGINT UNSIGNED NOT NULL,
+  `guild_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `embed` TEXT NOT NULL,
+  `mention_ids` TEXT NOT NULL,
+  `complete` BOOLEAN NOT NULL,
+  `attempts` INT NOT NULL DEFAULT 0,
+  `next_attempt_at` TIMESTAMP NOT NULL,
+  `last_error` TEXT,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`episode`, `season`, `series_id`, `guild_id`, `user_id`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `outbox`;
+-- +goose StatementEnd
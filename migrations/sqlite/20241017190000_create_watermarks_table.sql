@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `watermarks` (
+  `name` VARCHAR(64) NOT NULL,
+  `at` TIMESTAMP NOT NULL,
+
+  PRIMARY KEY (`name`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `watermarks`;
+-- +goose StatementEnd
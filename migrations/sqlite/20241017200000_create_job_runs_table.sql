@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `job_runs` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `job` VARCHAR(64) NOT NULL,
+  `started_at` TIMESTAMP NOT NULL,
+  `finished_at` TIMESTAMP NOT NULL,
+  `duration_ms` BIGINT NOT NULL,
+  `series_checked` INT NOT NULL DEFAULT 0,
+  `series_skipped` INT NOT NULL DEFAULT 0,
+  `series_failed` INT NOT NULL DEFAULT 0,
+  `episodes_notified` INT NOT NULL DEFAULT 0,
+  `tmdb_calls` BIGINT NOT NULL DEFAULT 0,
+  `error` TEXT NULL
+);
+
+CREATE INDEX `job_runs_job_started_at_index` ON `job_runs` (`job`, `started_at`);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `job_runs`;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `leases` (
+  `name` VARCHAR(64) NOT NULL,
+  `holder` VARCHAR(128) NOT NULL,
+  `term` BIGINT NOT NULL,
+  `heartbeat_at` TIMESTAMP NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+
+  PRIMARY KEY (`name`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `leases`;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `series` ADD COLUMN `next_check_at` TIMESTAMP;
+ALTER TABLE `series` ADD COLUMN `check_interval_ms` BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `series` DROP COLUMN `check_interval_ms`;
+ALTER TABLE `series` DROP COLUMN `next_check_at`;
+-- +goose StatementEnd
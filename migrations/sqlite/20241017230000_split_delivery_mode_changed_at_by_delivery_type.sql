@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `user_preferences_new` (
+  `user_id` BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+  `delivery_mode` VARCHAR(16) NOT NULL DEFAULT 'channel',
+  `channel_delivery_started_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `reminder_lead_minutes` INT NOT NULL DEFAULT 0,
+  `digest_frequency` VARCHAR(16) NOT NULL DEFAULT '',
+  `digest_minute` INT NOT NULL DEFAULT 1200,
+  `digest_weekday` INT NOT NULL DEFAULT 0,
+  `digest_timezone` VARCHAR(64) NOT NULL DEFAULT '',
+  `digest_last_sent_at` TIMESTAMP,
+  `dm_delivery_started_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO `user_preferences_new` (
+  `user_id`, `delivery_mode`, `channel_delivery_started_at`, `reminder_lead_minutes`, `digest_frequency`,
+  `digest_minute`, `digest_weekday`, `digest_timezone`, `digest_last_sent_at`, `dm_delivery_started_at`
+)
+SELECT
+  `user_id`, `delivery_mode`, `delivery_mode_changed_at`, `reminder_lead_minutes`, `digest_frequency`,
+  `digest_minute`, `digest_weekday`, `digest_timezone`, `digest_last_sent_at`, `delivery_mode_changed_at`
+FROM `user_preferences`;
+
+DROP TABLE `user_preferences`;
+ALTER TABLE `user_preferences_new` RENAME TO `user_preferences`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `user_preferences` DROP COLUMN `dm_delivery_started_at`;
+ALTER TABLE `user_preferences` RENAME COLUMN `channel_delivery_started_at` TO `delivery_mode_changed_at`;
+-- +goose StatementEnd
@@ -0,0 +1,7 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE `user_preferences` SET `reminder_lead_minutes` = 1440 WHERE `reminder_lead_minutes` > 0 AND `reminder_lead_minutes` < 1440;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Lead times that were rounded up can't be told apart from ones that were set to a day
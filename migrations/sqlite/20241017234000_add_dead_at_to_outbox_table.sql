@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `outbox` ADD COLUMN `dead_at` TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `outbox` DROP COLUMN `dead_at`;
+-- +goose StatementEnd
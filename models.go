@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/duke605/tv-bot/moviedb"
 )
 
@@ -22,6 +23,14 @@ func (n Null[T]) NullOrValue() interface{} {
 	return nil
 }
 
+func (n Null[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(n.V)
+}
+
 func NewNull[T any](v T, valid bool) Null[T] {
 	return Null[T]{
 		Null: sql.Null[T]{
@@ -57,16 +66,32 @@ func (j JSON[T]) Value() (driver.Value, error) {
 	return json.Marshal(j.V)
 }
 
+// NotificationTarget is where an episode is delivered to. Posts to a guild's notifications channel have a UserID
+// of 0 and direct messages have a GuildID of 0 so users in multiple guilds are only messaged once
+type NotificationTarget struct {
+	GuildID uint64 `db:"guild_id" json:"guild_id,string"`
+	UserID  uint64 `db:"user_id" json:"user_id,string"`
+}
+
+// Notification records that an episode was delivered to a target. Up to 10 episodes share a single message
+// so EmbedIndex is the index of the episode's embed in the message. Complete is false while the episode was
+// missing information when it was delivered so the message can be edited once the information is filled in
 type Notification struct {
-	Episode          int    `db:"episode"`
-	Season           int    `db:"name"`
-	SeriesID         uint64 `db:"series_id"`
-	DiscordMessageID uint64 `db:"discord_message_id"`
+	Episode  int    `db:"episode" json:"episode"`
+	Season   int    `db:"season" json:"season"`
+	SeriesID uint64 `db:"series_id" json:"series_id"`
+	NotificationTarget
+	DiscordChannelID uint64    `db:"discord_channel_id" json:"discord_channel_id,string"`
+	DiscordMessageID uint64    `db:"discord_message_id" json:"discord_message_id,string"`
+	EmbedIndex       int       `db:"embed_index" json:"embed_index"`
+	Complete         bool      `db:"complete" json:"complete"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
 }
 
 func (Notification) GetColumns() []string {
 	return []string{
-		"episode", "season", "series_id", "discord_message_id",
+		"episode", "season", "series_id", "guild_id", "user_id", "discord_channel_id", "discord_message_id",
+		"embed_index", "complete", "created_at",
 	}
 }
 
@@ -80,8 +105,20 @@ func (n *Notification) ToColumns(cols []string) []interface{} {
 			values[i] = n.Season
 		case "series_id":
 			values[i] = n.SeriesID
+		case "guild_id":
+			values[i] = n.GuildID
+		case "user_id":
+			values[i] = n.UserID
+		case "discord_channel_id":
+			values[i] = n.DiscordChannelID
 		case "discord_message_id":
 			values[i] = n.DiscordMessageID
+		case "embed_index":
+			values[i] = n.EmbedIndex
+		case "complete":
+			values[i] = n.Complete
+		case "created_at":
+			values[i] = n.CreatedAt
 		}
 	}
 
@@ -93,18 +130,26 @@ func (n *Notification) ToMap() map[string]any {
 		"episode":            n.Episode,
 		"season":             n.Season,
 		"series_id":          n.SeriesID,
+		"guild_id":           n.GuildID,
+		"user_id":            n.UserID,
+		"discord_channel_id": n.DiscordChannelID,
 		"discord_message_id": n.DiscordMessageID,
+		"embed_index":        n.EmbedIndex,
+		"complete":           n.Complete,
+		"created_at":         n.CreatedAt,
 	}
 }
 
 type Subscription struct {
-	SeriesID  uint64    `db:"series_id"`
-	UserID    uint64    `db:"user_id"`
-	CreatedAt time.Time `db:"created_at"`
+	GuildID   uint64    `db:"guild_id" json:"guild_id,string"`
+	SeriesID  uint64    `db:"series_id" json:"series_id"`
+	UserID    uint64    `db:"user_id" json:"user_id,string"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
 func (s *Subscription) ToMap() map[string]any {
 	return map[string]any{
+		"guild_id":   s.GuildID,
 		"series_id":  s.SeriesID,
 		"user_id":    s.UserID,
 		"created_at": s.CreatedAt,
@@ -116,6 +161,9 @@ type Series struct {
 	NextEpisodeAirDate Null[time.Time]              `db:"next_episode_air_date"`
 	Data               JSON[*moviedb.SeriesDetails] `db:"data"`
 	LastFetchedAt      time.Time                    `db:"last_fetched_at"`
+	IncludeSpecials    bool                         `db:"include_specials"`
+	NextCheckAt        Null[time.Time]              `db:"next_check_at"`
+	CheckIntervalMS    Null[int64]                  `db:"check_interval_ms"`
 }
 
 func (s *Series) ToMap() map[string]any {
@@ -124,5 +172,313 @@ func (s *Series) ToMap() map[string]any {
 		"next_episode_air_date": s.NextEpisodeAirDate,
 		"data":                  s.Data,
 		"last_fetched_at":       s.LastFetchedAt,
+		"include_specials":      s.IncludeSpecials,
+		"next_check_at":         s.NextCheckAt,
+		"check_interval_ms":     s.CheckIntervalMS,
+	}
+}
+
+// CheckInterval returns how often an admin set the series to be checked for new episodes. False is returned if
+// the series is checked on the poll schedule
+func (s *Series) CheckInterval() (time.Duration, bool) {
+	return time.Duration(s.CheckIntervalMS.V) * time.Millisecond, s.CheckIntervalMS.Valid
+}
+
+type DeliveryMode string
+
+const (
+	DeliveryModeChannel DeliveryMode = "channel"
+	DeliveryModeDM      DeliveryMode = "dm"
+	DeliveryModeBoth    DeliveryMode = "both"
+)
+
+// ToChannel returns true if episodes should be posted to the notifications channel
+func (m DeliveryMode) ToChannel() bool {
+	return m == DeliveryModeChannel || m == DeliveryModeBoth
+}
+
+// ToDM returns true if episodes should be sent as direct messages
+func (m DeliveryMode) ToDM() bool {
+	return m == DeliveryModeDM || m == DeliveryModeBoth
+}
+
+type DigestFrequency string
+
+const (
+	DigestFrequencyOff    DigestFrequency = ""
+	DigestFrequencyDaily  DigestFrequency = "daily"
+	DigestFrequencyWeekly DigestFrequency = "weekly"
+)
+
+type UserPreference struct {
+	UserID       uint64       `db:"user_id"`
+	DeliveryMode DeliveryMode `db:"delivery_mode"`
+	// ChannelDeliveryStartedAt and DMDeliveryStartedAt are when episodes started being delivered to the user in
+	// channels and as direct messages
+	ChannelDeliveryStartedAt time.Time `db:"channel_delivery_started_at"`
+	DMDeliveryStartedAt      time.Time `db:"dm_delivery_started_at"`
+	ReminderLeadMinutes      int       `db:"reminder_lead_minutes"`
+
+	DigestFrequency  DigestFrequency `db:"digest_frequency"`
+	DigestMinute     int             `db:"digest_minute"`
+	DigestWeekday    time.Weekday    `db:"digest_weekday"`
+	DigestTimezone   string          `db:"digest_timezone"`
+	DigestLastSentAt Null[time.Time] `db:"digest_last_sent_at"`
+}
+
+// DigestLocation returns the timezone the user's digest time is in. The bot's timezone is used if the user
+// hasn't picked one
+func (p *UserPreference) DigestLocation() *time.Location {
+	if p.DigestTimezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(p.DigestTimezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+// LastDigestDueAt returns the most recent time at or before now that the user's digest was scheduled for
+func (p *UserPreference) LastDigestDueAt(now time.Time) time.Time {
+	now = now.In(p.DigestLocation())
+	y, m, d := now.Date()
+	due := time.Date(y, m, d, p.DigestMinute/60, p.DigestMinute%60, 0, 0, now.Location())
+	if due.After(now) {
+		due = due.AddDate(0, 0, -1)
+	}
+
+	if p.DigestFrequency == DigestFrequencyWeekly {
+		due = due.AddDate(0, 0, -((int(due.Weekday()) - int(p.DigestWeekday) + 7) % 7))
+	}
+
+	return due
+}
+
+// ReminderLeadTime returns how long before an episode airs the user wants to be reminded about it. A
+// lead time of 0 means the user doesn't want reminders
+func (p *UserPreference) ReminderLeadTime() time.Duration {
+	return time.Duration(p.ReminderLeadMinutes) * time.Minute
+}
+
+// ReceivesInChannel returns true if new episodes are posted in channels mentioning the user. Users with digests
+// get every episode in their digest instead
+func (p *UserPreference) ReceivesInChannel() bool {
+	return p.DeliveryMode.ToChannel() && p.DigestFrequency == DigestFrequencyOff
+}
+
+// ReceivesDMs returns true if new episodes are sent to the user as direct messages or in a digest
+func (p *UserPreference) ReceivesDMs() bool {
+	return p.DeliveryMode.ToDM() || p.DigestFrequency != DigestFrequencyOff
+}
+
+// ChannelDeliveryStartedSince returns the start of the day episodes started being posted in channels for the
+// user. Episodes that aired before this are not posted in channels for the user
+func (p *UserPreference) ChannelDeliveryStartedSince() time.Time {
+	return startOfLocalDay(p.ChannelDeliveryStartedAt)
+}
+
+// DMDeliveryStartedSince returns the start of the day episodes started being sent to the user as direct
+// messages. Episodes that aired before this are not sent to the user
+func (p *UserPreference) DMDeliveryStartedSince() time.Time {
+	return startOfLocalDay(p.DMDeliveryStartedAt)
+}
+
+func startOfLocalDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func (p *UserPreference) ToMap() map[string]any {
+	return map[string]any{
+		"user_id":                     p.UserID,
+		"delivery_mode":               p.DeliveryMode,
+		"channel_delivery_started_at": p.ChannelDeliveryStartedAt,
+		"dm_delivery_started_at":      p.DMDeliveryStartedAt,
+		"reminder_lead_minutes":       p.ReminderLeadMinutes,
+		"digest_frequency":            p.DigestFrequency,
+		"digest_minute":               p.DigestMinute,
+		"digest_weekday":              p.DigestWeekday,
+		"digest_timezone":             p.DigestTimezone,
+		"digest_last_sent_at":         p.DigestLastSentAt,
+	}
+}
+
+type Guild struct {
+	ID                        uint64    `db:"id"`
+	NotificationsChannelID    uint64    `db:"notifications_channel_id"`
+	NotificationsChannelSetAt time.Time `db:"notifications_channel_set_at"`
+}
+
+// NotificationsChannelSetSince returns the start of the day the notifications channel was set on. Episodes that
+// aired before this are not posted to the guild
+func (g *Guild) NotificationsChannelSetSince() time.Time {
+	y, m, d := g.NotificationsChannelSetAt.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func (g *Guild) ToMap() map[string]any {
+	return map[string]any{
+		"id":                           g.ID,
+		"notifications_channel_id":     g.NotificationsChannelID,
+		"notifications_channel_set_at": g.NotificationsChannelSetAt,
+	}
+}
+
+type Reminder struct {
+	SeriesID uint64    `db:"series_id"`
+	Season   int       `db:"season"`
+	Episode  int       `db:"episode"`
+	UserID   uint64    `db:"user_id"`
+	SentAt   time.Time `db:"sent_at"`
+}
+
+func (r *Reminder) ToMap() map[string]any {
+	return map[string]any{
+		"series_id": r.SeriesID,
+		"season":    r.Season,
+		"episode":   r.Episode,
+		"user_id":   r.UserID,
+		"sent_at":   r.SentAt,
+	}
+}
+
+// DigestItem is an episode waiting to be sent to a user in their next digest
+type DigestItem struct {
+	UserID      uint64    `db:"user_id"`
+	SeriesID    uint64    `db:"series_id"`
+	Season      int       `db:"season"`
+	Episode     int       `db:"episode"`
+	EpisodeName string    `db:"episode_name"`
+	Runtime     int       `db:"runtime"`
+	AirDate     string    `db:"air_date"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (i *DigestItem) ToMap() map[string]any {
+	return map[string]any{
+		"user_id":      i.UserID,
+		"series_id":    i.SeriesID,
+		"season":       i.Season,
+		"episode":      i.Episode,
+		"episode_name": i.EpisodeName,
+		"runtime":      i.Runtime,
+		"air_date":     i.AirDate,
+		"created_at":   i.CreatedAt,
+	}
+}
+
+// OutboxItem is an episode waiting to be delivered to a target. Items for the same series and target are
+// delivered together in a single message and are retried with a backoff until they're delivered
+type OutboxItem struct {
+	Episode  int    `db:"episode"`
+	Season   int    `db:"season"`
+	SeriesID uint64 `db:"series_id"`
+	NotificationTarget
+	Embed         JSON[*discordgo.MessageEmbed] `db:"embed"`
+	MentionIDs    JSON[[]uint64]                `db:"mention_ids"`
+	Complete      bool                          `db:"complete"`
+	Attempts      int                           `db:"attempts"`
+	NextAttemptAt time.Time                     `db:"next_attempt_at"`
+	LastError     Null[string]                  `db:"last_error"`
+	// DeadAt is when delivering the item was given up on. Dead items are kept so the episode isn't queued again
+	DeadAt    Null[time.Time] `db:"dead_at"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+func (i *OutboxItem) ToMap() map[string]any {
+	return map[string]any{
+		"episode":         i.Episode,
+		"season":          i.Season,
+		"series_id":       i.SeriesID,
+		"guild_id":        i.GuildID,
+		"user_id":         i.UserID,
+		"embed":           i.Embed,
+		"mention_ids":     i.MentionIDs,
+		"complete":        i.Complete,
+		"attempts":        i.Attempts,
+		"next_attempt_at": i.NextAttemptAt,
+		"last_error":      i.LastError,
+		"dead_at":         i.DeadAt,
+		"created_at":      i.CreatedAt,
+	}
+}
+
+// Notification returns the notification recording that the item was delivered
+func (i *OutboxItem) Notification() *Notification {
+	return &Notification{
+		Episode:            i.Episode,
+		Season:             i.Season,
+		SeriesID:           i.SeriesID,
+		NotificationTarget: i.NotificationTarget,
+		Complete:           i.Complete,
+	}
+}
+
+// JobRunFindNewEpisodes is the name of the job that scans the series subscribed to for new episodes
+const JobRunFindNewEpisodes = "find_new_episodes"
+
+// JobRun records how a run of a scheduled job went. Error is set if the run failed or only partly succeeded
+type JobRun struct {
+	ID               int64        `db:"id" json:"id"`
+	Job              string       `db:"job" json:"job"`
+	StartedAt        time.Time    `db:"started_at" json:"started_at"`
+	FinishedAt       time.Time    `db:"finished_at" json:"finished_at"`
+	DurationMS       int64        `db:"duration_ms" json:"duration_ms"`
+	SeriesChecked    int          `db:"series_checked" json:"series_checked"`
+	SeriesSkipped    int          `db:"series_skipped" json:"series_skipped"`
+	SeriesFailed     int          `db:"series_failed" json:"series_failed"`
+	EpisodesNotified int          `db:"episodes_notified" json:"episodes_notified"`
+	TMDBCalls        int64        `db:"tmdb_calls" json:"tmdb_calls"`
+	Error            Null[string] `db:"error" json:"error"`
+	// Failed is true if the run failed as a whole. A run can finish with an error without failing when only some
+	// series couldn't be checked
+	Failed bool `db:"failed" json:"failed"`
+}
+
+func (r *JobRun) ToMap() map[string]any {
+	return map[string]any{
+		"job":               r.Job,
+		"started_at":        r.StartedAt,
+		"finished_at":       r.FinishedAt,
+		"duration_ms":       r.DurationMS,
+		"series_checked":    r.SeriesChecked,
+		"series_skipped":    r.SeriesSkipped,
+		"series_failed":     r.SeriesFailed,
+		"episodes_notified": r.EpisodesNotified,
+		"tmdb_calls":        r.TMDBCalls,
+		"error":             r.Error,
+		"failed":            r.Failed,
+	}
+}
+
+func (r *JobRun) Duration() time.Duration {
+	return time.Duration(r.DurationMS) * time.Millisecond
+}
+
+// Lease lets the instance holding it run the scheduled jobs until it expires. The holder renews the lease with a
+// heartbeat before it expires. Term is increased every time the lease is written so instances trying to acquire
+// it at the same time can't both succeed
+type Lease struct {
+	Name        string    `db:"name"`
+	Holder      string    `db:"holder"`
+	Term        int64     `db:"term"`
+	HeartbeatAt time.Time `db:"heartbeat_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+func (l *Lease) ToMap() map[string]any {
+	return map[string]any{
+		"name":         l.Name,
+		"holder":       l.Holder,
+		"term":         l.Term,
+		"heartbeat_at": l.HeartbeatAt,
+		"expires_at":   l.ExpiresAt,
 	}
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastDigestDueAt(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		pref     *UserPreference
+		now      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "is yesterday when today's digest time hasn't come yet",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyDaily, DigestMinute: 20 * 60, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 1, 20, 19, 59, 0, 0, newYork),
+			expected: time.Date(2024, 1, 19, 20, 0, 0, 0, newYork),
+		},
+		{
+			name:     "is today once the digest time has come",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyDaily, DigestMinute: 20 * 60, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 1, 20, 20, 0, 0, 0, newYork),
+			expected: time.Date(2024, 1, 20, 20, 0, 0, 0, newYork),
+		},
+		{
+			name:     "uses the date in the user's time zone",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyDaily, DigestMinute: 8 * 60, DigestTimezone: "Asia/Tokyo"},
+			now:      time.Date(2024, 1, 20, 0, 30, 0, 0, time.UTC),
+			expected: time.Date(2024, 1, 20, 8, 0, 0, 0, tokyo),
+		},
+		{
+			name:     "uses the bot's time zone when the user's is unknown",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyDaily, DigestMinute: 8 * 60, DigestTimezone: "Nowhere/Special"},
+			now:      time.Date(2024, 1, 20, 12, 0, 0, 0, time.Local),
+			expected: time.Date(2024, 1, 20, 8, 0, 0, 0, time.Local),
+		},
+		{
+			name:     "keeps the time of day across the start of daylight saving time",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyDaily, DigestMinute: 20 * 60, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 3, 10, 10, 0, 0, 0, newYork),
+			expected: time.Date(2024, 3, 9, 20, 0, 0, 0, newYork),
+		},
+		{
+			name:     "keeps the time of day across the end of daylight saving time",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyDaily, DigestMinute: 20 * 60, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 11, 3, 10, 0, 0, 0, newYork),
+			expected: time.Date(2024, 11, 2, 20, 0, 0, 0, newYork),
+		},
+		{
+			name:     "is the latest digest weekday",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyWeekly, DigestMinute: 8 * 60, DigestWeekday: time.Monday, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 1, 20, 12, 0, 0, 0, newYork),
+			expected: time.Date(2024, 1, 15, 8, 0, 0, 0, newYork),
+		},
+		{
+			name:     "is last week on the digest weekday before the digest time",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyWeekly, DigestMinute: 8 * 60, DigestWeekday: time.Monday, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 1, 22, 7, 59, 0, 0, newYork),
+			expected: time.Date(2024, 1, 15, 8, 0, 0, 0, newYork),
+		},
+		{
+			name:     "is today on the digest weekday after the digest time",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyWeekly, DigestMinute: 8 * 60, DigestWeekday: time.Monday, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 1, 22, 8, 0, 0, 0, newYork),
+			expected: time.Date(2024, 1, 22, 8, 0, 0, 0, newYork),
+		},
+		{
+			name:     "uses the weekday in the user's time zone",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyWeekly, DigestMinute: 18 * 60, DigestWeekday: time.Sunday, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 1, 22, 0, 30, 0, 0, time.UTC),
+			expected: time.Date(2024, 1, 21, 18, 0, 0, 0, newYork),
+		},
+		{
+			name:     "keeps the time of day when daylight saving time started during the week",
+			pref:     &UserPreference{DigestFrequency: DigestFrequencyWeekly, DigestMinute: 20 * 60, DigestWeekday: time.Friday, DigestTimezone: "America/New_York"},
+			now:      time.Date(2024, 3, 14, 12, 0, 0, 0, newYork),
+			expected: time.Date(2024, 3, 8, 20, 0, 0, 0, newYork),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Acting
+			actual := test.pref.LastDigestDueAt(test.now)
+
+			// Asserting
+			assert.True(t, test.expected.Equal(actual), "expected %s, got %s", test.expected, actual)
+		})
+	}
+}
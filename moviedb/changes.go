@@ -0,0 +1,91 @@
+package moviedb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ChangedItem is an entry in a change list. TMDB pages change lists the same way it pages search results
+type ChangedItem struct {
+	ID    uint64 `json:"id"`
+	Adult *bool  `json:"adult"`
+}
+
+type ChangeItem struct {
+	ID            string `json:"id"`
+	Action        string `json:"action"`
+	Time          string `json:"time"`
+	Iso6391       string `json:"iso_639_1"`
+	Iso31661      string `json:"iso_3166_1"`
+	Value         any    `json:"value"`
+	OriginalValue any    `json:"original_value"`
+}
+
+type SeriesChanges struct {
+	Changes []struct {
+		Key   string       `json:"key"`
+		Items []ChangeItem `json:"items"`
+	} `json:"changes"`
+}
+
+type ChangesService interface {
+	// GetTVChanges gets a page of the series that have changed. Pages start at 1 and the changes are from the
+	// last 24 hours unless a date range is provided
+	GetTVChanges(page int, dst *SearchResults[*ChangedItem], opts ...RequestOption) (*http.Response, error)
+	// GetTVSeriesChanges gets the changes made to a series. The changes are from the last 24 hours unless a
+	// date range is provided
+	GetTVSeriesChanges(id uint64, dst *SeriesChanges, opts ...RequestOption) (*http.Response, error)
+}
+
+type changesService struct {
+	service
+}
+
+func NewChangesService(c Client) ChangesService {
+	return &changesService{service{path: "tv", client: c}}
+}
+
+// RequestOptionWithDateRange limits a change list to changes made between the dates. TMDB doesn't allow ranges
+// longer than 14 days
+func RequestOptionWithDateRange(start, end time.Time) RequestOption {
+	return RequestOptionWithQueryParams(
+		"start_date", start.UTC().Format(time.DateOnly),
+		"end_date", end.UTC().Format(time.DateOnly),
+	)
+}
+
+func (cs *changesService) GetTVChanges(page int, dst *SearchResults[*ChangedItem], opts ...RequestOption) (*http.Response, error) {
+	opts = append(slices.Clip(opts), RequestOptionWithQueryParams("page", strconv.Itoa(page)))
+
+	resp, err := cs.do(http.MethodGet, "changes", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, err
+}
+
+func (cs *changesService) GetTVSeriesChanges(id uint64, dst *SeriesChanges, opts ...RequestOption) (*http.Response, error) {
+	resp, err := cs.do(http.MethodGet, fmt.Sprintf("%d/changes", id), opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, err
+}
@@ -1,13 +1,69 @@
 package moviedb
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 )
 
-type HTTPError struct {
-	Response *http.Response
+var (
+	// ErrNotFound is matched by API errors for resources that don't exist on TMDB
+	ErrNotFound = errors.New("moviedb: not found")
+	// ErrUnauthorized is matched by API errors caused by a missing or invalid access token
+	ErrUnauthorized = errors.New("moviedb: unauthorized")
+	// ErrRateLimited is matched by API errors caused by sending too many requests
+	ErrRateLimited = errors.New("moviedb: rate limited")
+)
+
+// APIError is returned when TMDB responds with a non-2xx status code. The response body has already been
+// read into Body and closed. StatusCode and StatusMessage are decoded from the body when TMDB includes them
+type APIError struct {
+	Response      *http.Response
+	Body          []byte
+	StatusCode    int    `json:"status_code"`
+	StatusMessage string `json:"status_message"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	err := &APIError{Response: resp, Body: body}
+	json.Unmarshal(body, err)
+
+	return err
+}
+
+func (err *APIError) Error() string {
+	msg := fmt.Sprintf("moviedb: %s %s responded with %d", err.Response.Request.Method, err.Response.Request.URL.Redacted(), err.Response.StatusCode)
+	if err.StatusMessage != "" {
+		msg += fmt.Sprintf(": %s (%d)", err.StatusMessage, err.StatusCode)
+	}
+
+	return msg
+}
+
+func (err *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return err.Response.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return err.Response.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return err.Response.StatusCode == http.StatusTooManyRequests
+	}
+
+	return false
 }
 
-func (err *HTTPError) Error() string {
-	return "moviedb: non-2xx status code"
+// IsTransient returns true if the error is likely to go away if the request is tried again later
+func IsTransient(err error) bool {
+	apiErr := &APIError{}
+	if errors.As(err, &apiErr) {
+		return errors.Is(apiErr, ErrRateLimited) || apiErr.Response.StatusCode >= 500
+	}
+
+	// Failing to send the request at all is usually a network issue
+	urlErr := &url.Error{}
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled)
 }
@@ -3,10 +3,14 @@ package moviedb
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/ratelimit"
 )
 
 type service struct {
@@ -31,18 +35,23 @@ type Client interface {
 	TVSeasonsService
 	TVEpisodesService
 	SearchService
+	ChangesService
 }
 
 type client struct {
 	httpClient        *http.Client
 	baseURL           *url.URL
 	globalRequestOpts []RequestOption
+	retryPolicy       RetryPolicy
+	rateLimiter       ratelimit.Limiter
+	observer          Observer
 
 	TVSeriesService
 	ConfigurationService
 	TVSeasonsService
 	TVEpisodesService
 	SearchService
+	ChangesService
 }
 
 type ClientOption = func(*client)
@@ -60,6 +69,57 @@ func ClientOptionGlobalRequestOption(opt RequestOption) ClientOption {
 	}
 }
 
+// ClientOptionRetryPolicy retries GET requests that fail because of a network error, a 429 or a 5xx
+// status code according to the policy
+func ClientOptionRetryPolicy(p RetryPolicy) ClientOption {
+	return func(cl *client) {
+		cl.retryPolicy = p
+	}
+}
+
+// ClientOptionRateLimit takes from the limiter before every attempt at sending a request
+func ClientOptionRateLimit(rl ratelimit.Limiter) ClientOption {
+	return func(cl *client) {
+		cl.rateLimiter = rl
+	}
+}
+
+// Observer is told about every attempt at sending a request so the client can be instrumented. The route is the
+// path of the request with its IDs replaced by {id}, e.g. /tv/{id}/season/{id}, and the status is 0 if no response
+// was received
+type Observer interface {
+	ObserveRequest(route string, status int, latency time.Duration)
+	ObserveRateLimitWait(wait time.Duration)
+}
+
+// ClientOptionObserver tells the observer about every attempt at sending a request and how long each attempt
+// waited on the rate limiter
+func ClientOptionObserver(o Observer) ClientOption {
+	return func(cl *client) {
+		cl.observer = o
+	}
+}
+
+// routeForPath replaces the IDs in the path with {id} so requests for different series share a route
+func routeForPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if segment != "" && strings.Trim(segment, "0123456789") == "" {
+			segments[i] = "{id}"
+		}
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+type requestCounterCtxKey struct{}
+
+// ContextWithRequestCounter returns a context that counts the requests sent with it in n. Every attempt at
+// sending a request is counted so retries are included
+func ContextWithRequestCounter(ctx context.Context, n *atomic.Int64) context.Context {
+	return context.WithValue(ctx, requestCounterCtxKey{}, n)
+}
+
 func RequestOptionWithContext(ctx context.Context) RequestOption {
 	return func(r *http.Request) *http.Request {
 		return r.WithContext(ctx)
@@ -103,7 +163,7 @@ func NewClient(baseURL string, opts ...ClientOption) (Client, error) {
 		return nil, err
 	}
 
-	c := &client{baseURL: u, httpClient: http.DefaultClient}
+	c := &client{baseURL: u, httpClient: http.DefaultClient, retryPolicy: RetryPolicy{MaxAttempts: 1}}
 	for _, opt := range opts {
 		opt(c)
 	}
@@ -113,33 +173,66 @@ func NewClient(baseURL string, opts ...ClientOption) (Client, error) {
 	c.TVSeasonsService = NewTVSeasonsService(c)
 	c.SearchService = NewSearchService(c)
 	c.TVEpisodesService = NewTVEpisodesService(c)
+	c.ChangesService = NewChangesService(c)
 
 	return c, nil
 }
 
 func (client *client) Do(method, path string, opts ...RequestOption) (*http.Response, error) {
 	u := client.baseURL.JoinPath(path)
-	req, err := http.NewRequest(method, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
+	for attempt := 1; ; attempt++ {
+		ctx := context.WithValue(context.Background(), attemptCtxKey{}, attempt)
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, opt := range client.globalRequestOpts {
-		req = opt(req)
-	}
-	for _, opt := range opts {
-		req = opt(req)
-	}
+		for _, opt := range client.globalRequestOpts {
+			req = opt(req)
+		}
+		for _, opt := range opts {
+			req = opt(req)
+		}
 
-	resp, err := client.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
+		if client.rateLimiter != nil {
+			start := time.Now()
+			client.rateLimiter.Take()
+			if client.observer != nil {
+				client.observer.ObserveRateLimitWait(time.Since(start))
+			}
+		}
+		if n, ok := req.Context().Value(requestCounterCtxKey{}).(*atomic.Int64); ok {
+			n.Add(1)
+		}
+		start := time.Now()
+		resp, err := client.httpClient.Do(req)
+		if client.observer != nil {
+			status := 0
+			if err == nil {
+				status = resp.StatusCode
+			}
+			client.observer.ObserveRequest(routeForPath(path), status, time.Since(start))
+		}
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return resp, nil
+		}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		fmt.Println(resp.StatusCode, u)
-		return nil, &HTTPError{resp}
-	}
+		// Draining and closing the body so the connection can be reused
+		if err == nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+			err = newAPIError(resp, body)
+		}
 
-	return resp, err
+		if !client.retryPolicy.shouldRetry(method, attempt, resp, err) || req.Context().Err() != nil {
+			return nil, err
+		}
+		delay, ok := client.retryPolicy.delay(attempt, resp)
+		if !ok {
+			return nil, err
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
 }
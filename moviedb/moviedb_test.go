@@ -0,0 +1,202 @@
+package moviedb_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duke605/tv-bot/moviedb"
+	"github.com/duke605/tv-bot/moviedb/moviedbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/ratelimit"
+)
+
+func TestClientRetriesTransientErrors(t *testing.T) {
+	policy := moviedb.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		name        string
+		fault       moviedbtest.Fault
+		expStatus   int
+		expRequests int
+	}{
+		{
+			name:        "retries rate limited requests after Retry-After",
+			fault:       moviedbtest.Fault{Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 2},
+			expRequests: 3,
+		},
+		{
+			name:        "retries server errors",
+			fault:       moviedbtest.Fault{Status: http.StatusInternalServerError, Times: 1},
+			expRequests: 2,
+		},
+		{
+			name:        "gives up after the max attempts",
+			fault:       moviedbtest.Fault{Status: http.StatusServiceUnavailable},
+			expStatus:   http.StatusServiceUnavailable,
+			expRequests: 3,
+		},
+		{
+			name:        "does not retry Retry-After longer than the max delay",
+			fault:       moviedbtest.Fault{Status: http.StatusTooManyRequests, RetryAfter: "60"},
+			expStatus:   http.StatusTooManyRequests,
+			expRequests: 1,
+		},
+		{
+			name:        "does not retry missing resources",
+			fault:       moviedbtest.Fault{Status: http.StatusNotFound},
+			expStatus:   http.StatusNotFound,
+			expRequests: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			tmdb := moviedbtest.NewServer(t)
+			require.NoError(t, tmdb.LoadFixture("../testdata/moviedb/returning_series.json"))
+			tmdb.InjectFault(test.fault)
+			client := tmdb.Client(moviedb.ClientOptionRetryPolicy(policy))
+
+			// Acting
+			series := &moviedb.SeriesDetails{}
+			_, err := client.GetTVSeriesDetails(100, series)
+
+			// Asserting
+			if test.expStatus != 0 {
+				apiErr := &moviedb.APIError{}
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, test.expStatus, apiErr.Response.StatusCode)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "The Test Series", series.Name)
+			}
+			assert.Len(t, tmdb.Requests(), test.expRequests)
+		})
+	}
+}
+
+func TestClientDecodesAPIErrors(t *testing.T) {
+	// Arranging
+	tmdb := moviedbtest.NewServer(t)
+	client := tmdb.Client()
+
+	// Acting
+	_, err := client.GetTVSeriesDetails(404, &moviedb.SeriesDetails{})
+
+	// Asserting
+	apiErr := &moviedb.APIError{}
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 34, apiErr.StatusCode)
+	assert.Equal(t, "The resource you requested could not be found.", apiErr.StatusMessage)
+	assert.ErrorIs(t, err, moviedb.ErrNotFound)
+	assert.NotErrorIs(t, err, moviedb.ErrUnauthorized)
+}
+
+func TestClientStopsWaitingWhenContextIsDone(t *testing.T) {
+	// Arranging
+	tmdb := moviedbtest.NewServer(t)
+	tmdb.InjectFault(moviedbtest.Fault{Latency: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	// Acting
+	_, err := tmdb.Client().GetTVSeriesDetails(100, &moviedb.SeriesDetails{}, moviedb.RequestOptionWithContext(ctx))
+
+	// Asserting
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClientCountsRequestsSentWithCounterContext(t *testing.T) {
+	// Arranging
+	tmdb := moviedbtest.NewServer(t)
+	require.NoError(t, tmdb.LoadFixture("../testdata/moviedb/returning_series.json"))
+	tmdb.InjectFault(moviedbtest.Fault{Status: http.StatusServiceUnavailable, Times: 1})
+	client := tmdb.Client(moviedb.ClientOptionRetryPolicy(moviedb.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	n := &atomic.Int64{}
+	ctx := moviedb.ContextWithRequestCounter(context.Background(), n)
+
+	// Acting
+	_, err := client.GetTVSeriesDetails(100, &moviedb.SeriesDetails{}, moviedb.RequestOptionWithContext(ctx))
+	require.NoError(t, err)
+	_, err = client.GetTVSeriesDetails(100, &moviedb.SeriesDetails{})
+	require.NoError(t, err)
+
+	// Asserting
+	assert.Equal(t, int64(2), n.Load())
+}
+
+type observedRequest struct {
+	route  string
+	status int
+}
+
+type fakeObserver struct {
+	mu        sync.Mutex
+	requests  []observedRequest
+	rateWaits int
+}
+
+func (o *fakeObserver) ObserveRequest(route string, status int, latency time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.requests = append(o.requests, observedRequest{route, status})
+}
+
+func (o *fakeObserver) ObserveRateLimitWait(wait time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.rateWaits++
+}
+
+func TestClientTellsObserverAboutEveryAttempt(t *testing.T) {
+	// Arranging
+	tmdb := moviedbtest.NewServer(t)
+	require.NoError(t, tmdb.LoadFixture("../testdata/moviedb/returning_series.json"))
+	tmdb.InjectFault(moviedbtest.Fault{Status: http.StatusServiceUnavailable, Times: 1})
+	observer := &fakeObserver{}
+	client := tmdb.Client(
+		moviedb.ClientOptionRetryPolicy(moviedb.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		moviedb.ClientOptionRateLimit(ratelimit.NewUnlimited()),
+		moviedb.ClientOptionObserver(observer),
+	)
+
+	// Acting
+	_, err := client.GetTVSeriesDetails(100, &moviedb.SeriesDetails{})
+	require.NoError(t, err)
+	_, err = client.GetTVSeasonDetails(100, 1, &moviedb.SeasonDetails{})
+	require.NoError(t, err)
+
+	// Asserting
+	assert.Equal(t, []observedRequest{
+		{"/tv/{id}", http.StatusServiceUnavailable},
+		{"/tv/{id}", http.StatusOK},
+		{"/tv/{id}/season/{id}", http.StatusOK},
+	}, observer.requests)
+	assert.Equal(t, 3, observer.rateWaits)
+}
+
+func TestGetTVSeriesDetailsWithAppends(t *testing.T) {
+	// Arranging
+	tmdb := moviedbtest.NewServer(t)
+	require.NoError(t, tmdb.LoadFixture("../testdata/moviedb/returning_series.json"))
+
+	// Acting
+	series := &moviedb.SeriesDetailsWithAppends{}
+	_, err := tmdb.Client().GetTVSeriesDetailsWithAppends(100, series,
+		moviedb.RequestOptionAppendToResponse(moviedb.AppendSeason(1), moviedb.AppendSeason(2)),
+	)
+
+	// Asserting
+	require.NoError(t, err)
+	assert.Equal(t, "The Test Series", series.Name)
+	require.Contains(t, series.AppendedSeasons, 1)
+	assert.Len(t, series.AppendedSeasons[1].Episodes, 3)
+	assert.NotContains(t, series.AppendedSeasons, 2)
+	assert.Equal(t, []string{"/tv/100"}, tmdb.Requests())
+}
@@ -0,0 +1,401 @@
+// Package moviedbtest provides an in-process fake of the TMDB API for tests. The fake is seeded with series
+// and seasons and serves them the same way TMDB does, including append_to_response, and can be told to
+// respond with errors or be slow to respond
+package moviedbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke605/tv-bot/moviedb"
+)
+
+// Fixture is the data the fake serves. Fixtures can be written as JSON and loaded with LoadFixture
+type Fixture struct {
+	Series        []*moviedb.SeriesDetails `json:"series"`
+	Seasons       []*Season                `json:"seasons"`
+	ChangedIDs    []uint64                 `json:"changed_ids"`
+	Configuration *moviedb.Configuration   `json:"configuration"`
+}
+
+// Season is a season of the series with the ID provided
+type Season struct {
+	SeriesID uint64 `json:"series_id"`
+	moviedb.SeasonDetails
+}
+
+// Fault makes requests to the path respond with the status code and/or wait before responding. Path is
+// the path of the request without the API version, e.g. /tv/1399, and an empty path matches every request
+type Fault struct {
+	Path string
+	// Status is the status code to respond with. The request is served normally if it's 0
+	Status int
+	// RetryAfter is the value of the Retry-After header sent with the status code
+	RetryAfter string
+	// Latency is how long to wait before responding
+	Latency time.Duration
+	// Times is how many requests the fault applies to. The fault applies to every request if it's 0
+	Times int
+}
+
+// Server is a fake TMDB API backed by an httptest.Server
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	series     map[uint64]*moviedb.SeriesDetails
+	seasons    map[uint64]map[int]*moviedb.SeasonDetails
+	changedIDs []uint64
+	config     *moviedb.Configuration
+	faults     []*Fault
+	requests   []string
+}
+
+// NewServer starts a fake TMDB API with no data. The server is closed when the test finishes
+func NewServer(t interface{ Cleanup(func()) }) *Server {
+	s := &Server{
+		series:  map[uint64]*moviedb.SeriesDetails{},
+		seasons: map[uint64]map[int]*moviedb.SeasonDetails{},
+		config:  &moviedb.Configuration{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /configuration", s.handleConfiguration)
+	mux.HandleFunc("GET /search/tv", s.handleSearch)
+	mux.HandleFunc("GET /tv/changes", s.handleChanges)
+	mux.HandleFunc("GET /tv/{id}", s.handleSeries)
+	mux.HandleFunc("GET /tv/{id}/changes", s.handleSeriesChanges)
+	mux.HandleFunc("GET /tv/{id}/season/{season}", s.handleSeason)
+	mux.HandleFunc("GET /tv/{id}/season/{season}/episode/{episode}", s.handleEpisode)
+
+	s.Server = httptest.NewServer(s.withFaults(mux))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns a client that sends requests to the fake
+func (s *Server) Client(opts ...moviedb.ClientOption) moviedb.Client {
+	opts = append([]moviedb.ClientOption{moviedb.ClientOptionWithHTTPClient(s.Server.Client())}, opts...)
+	c, err := moviedb.NewClient(s.URL, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// AddSeries adds the series to the fake replacing series with the same ID
+func (s *Server) AddSeries(series ...*moviedb.SeriesDetails) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sr := range series {
+		s.series[sr.ID] = sr
+	}
+}
+
+// RemoveSeries removes the series and its seasons from the fake so requests for it respond with a 404
+func (s *Server) RemoveSeries(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.series, id)
+	delete(s.seasons, id)
+}
+
+// AddSeason adds the season to the series with the ID provided replacing the season with the same number
+func (s *Server) AddSeason(seriesID uint64, season *moviedb.SeasonDetails) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seasons[seriesID] == nil {
+		s.seasons[seriesID] = map[int]*moviedb.SeasonDetails{}
+	}
+	s.seasons[seriesID][season.SeasonNumber] = season
+}
+
+// SetChangedIDs sets the IDs of the series listed by the changes endpoint
+func (s *Server) SetChangedIDs(ids ...uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.changedIDs = ids
+}
+
+// SetConfiguration sets the configuration served by the fake
+func (s *Server) SetConfiguration(config *moviedb.Configuration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+}
+
+// Load adds everything in the fixture to the fake
+func (s *Server) Load(f *Fixture) {
+	s.AddSeries(f.Series...)
+	for _, season := range f.Seasons {
+		s.AddSeason(season.SeriesID, &season.SeasonDetails)
+	}
+	if f.ChangedIDs != nil {
+		s.SetChangedIDs(f.ChangedIDs...)
+	}
+	if f.Configuration != nil {
+		s.SetConfiguration(f.Configuration)
+	}
+}
+
+// LoadFixture adds everything in the JSON fixture file to the fake
+func (s *Server) LoadFixture(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	f := &Fixture{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return fmt.Errorf("moviedbtest: failed to decode fixture %s: %w", path, err)
+	}
+	s.Load(f)
+
+	return nil
+}
+
+// InjectFault makes the fake misbehave for requests matching the fault. Faults are checked in the order they
+// were injected and only the first matching fault applies
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// Requests returns the paths of every request the fake has received in the order they were received
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		var fault *Fault
+		for i, f := range s.faults {
+			if f.Path != "" && f.Path != r.URL.Path {
+				continue
+			}
+
+			fault = f
+			if f.Times > 0 {
+				if f.Times--; f.Times == 0 {
+					s.faults = slices.Delete(s.faults, i, i+1)
+				}
+			}
+			break
+		}
+		s.mu.Unlock()
+
+		if fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if fault.Latency > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(fault.Latency):
+			}
+		}
+		if fault.RetryAfter != "" {
+			w.Header().Set("Retry-After", fault.RetryAfter)
+		}
+		if fault.Status == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writeError(w, fault.Status)
+	})
+}
+
+func (s *Server) handleConfiguration(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, s.config)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := strings.ToLower(r.URL.Query().Get("query"))
+	results := &moviedb.SearchResults[*moviedb.SearchSeriesDetails]{Page: 1, Results: []*moviedb.SearchSeriesDetails{}}
+	for _, sr := range s.series {
+		if query == "" || !strings.Contains(strings.ToLower(sr.Name), query) {
+			continue
+		}
+
+		results.Results = append(results.Results, &moviedb.SearchSeriesDetails{
+			ID:           sr.ID,
+			Name:         sr.Name,
+			Overview:     sr.Overview,
+			PosterPath:   sr.PosterPath,
+			BackdropPath: sr.BackdropPath,
+			FirstAirDate: sr.FirstAirDate,
+			Popularity:   sr.Popularity,
+		})
+	}
+	slices.SortFunc(results.Results, func(a, b *moviedb.SearchSeriesDetails) int {
+		return int(a.ID) - int(b.ID)
+	})
+	results.TotalResults = len(results.Results)
+	if results.TotalResults > 0 {
+		results.TotalPages = 1
+	}
+
+	writeJSON(w, results)
+}
+
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Paging the changes 100 at a time like TMDB does
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	results := &moviedb.SearchResults[*moviedb.ChangedItem]{
+		Page:         page,
+		TotalPages:   (len(s.changedIDs) + 99) / 100,
+		TotalResults: len(s.changedIDs),
+		Results:      []*moviedb.ChangedItem{},
+	}
+	for _, id := range s.changedIDs[min((page-1)*100, len(s.changedIDs)):min(page*100, len(s.changedIDs))] {
+		results.Results = append(results.Results, &moviedb.ChangedItem{ID: id})
+	}
+
+	writeJSON(w, results)
+}
+
+func (s *Server) handleSeries(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, _ := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	series := s.series[id]
+	if series == nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	// Merging the sub-resources into the series the same way TMDB does
+	b, _ := json.Marshal(series)
+	resp := map[string]any{}
+	json.Unmarshal(b, &resp)
+	for _, a := range strings.Split(r.URL.Query().Get("append_to_response"), ",") {
+		if after, ok := strings.CutPrefix(a, "season/"); ok {
+			n, _ := strconv.Atoi(after)
+			if season := s.seasons[id][n]; season != nil {
+				resp[a] = season
+			}
+		} else if a == string(moviedb.AppendChanges) {
+			resp[a] = &moviedb.SeriesChanges{}
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleSeriesChanges(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, _ := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if s.series[id] == nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, &moviedb.SeriesChanges{})
+}
+
+func (s *Server) handleSeason(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	season := s.getSeason(r)
+	if season == nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, season)
+}
+
+func (s *Server) handleEpisode(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	season := s.getSeason(r)
+	if season == nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	n, _ := strconv.Atoi(r.PathValue("episode"))
+	for _, episode := range season.Episodes {
+		if episode.EpisodeNumber == n {
+			writeJSON(w, episode)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound)
+}
+
+func (s *Server) getSeason(r *http.Request) *moviedb.SeasonDetails {
+	id, _ := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	n, _ := strconv.Atoi(r.PathValue("season"))
+
+	return s.seasons[id][n]
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with the status code and the error body TMDB sends with it
+func writeError(w http.ResponseWriter, status int) {
+	statusCode, message := 0, http.StatusText(status)
+	switch status {
+	case http.StatusUnauthorized:
+		statusCode, message = 7, "Invalid API key: You must be granted a valid key."
+	case http.StatusNotFound:
+		statusCode, message = 34, "The resource you requested could not be found."
+	case http.StatusTooManyRequests:
+		statusCode, message = 25, "Your request count is over the allowed limit."
+	case http.StatusInternalServerError:
+		statusCode, message = 11, "Internal error: Something went wrong, contact TMDB."
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":        false,
+		"status_code":    statusCode,
+		"status_message": message,
+	})
+}
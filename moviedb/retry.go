@@ -0,0 +1,85 @@
+package moviedb
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail because of a network error, a 429 or a 5xx status code are
+// retried. Only GET requests are retried as they're the only requests that are safe to send more than once
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request will be sent including the first attempt
+	MaxAttempts int
+	// BaseDelay is how long to wait before the first retry. The delay doubles with every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. A Retry-After longer than this is not waited for
+	MaxDelay time.Duration
+}
+
+type attemptCtxKey struct{}
+
+// RequestAttempt returns which attempt at sending the request this is starting at 1. Global request options
+// are applied to every attempt so this can be used to tell retries apart
+func RequestAttempt(r *http.Request) int {
+	if attempt, ok := r.Context().Value(attemptCtxKey{}).(int); ok {
+		return attempt
+	}
+
+	return 1
+}
+
+// shouldRetry returns true if the request can be attempted again after the response or error
+func (p RetryPolicy) shouldRetry(method string, attempt int, resp *http.Response, err error) bool {
+	if method != http.MethodGet || attempt >= p.MaxAttempts {
+		return false
+	} else if resp == nil {
+		return err != nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// delay returns how long to wait before the next attempt. The Retry-After header of the response is used if it's
+// set otherwise the delay grows exponentially with jitter. False is returned if the wait would be longer than the
+// max delay
+func (p RetryPolicy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return retryAfter, retryAfter <= p.MaxDelay
+		}
+	}
+
+	d := min(p.BaseDelay<<min(attempt-1, 30), p.MaxDelay)
+	return d/2 + rand.N(d/2+1), true
+}
+
+// parseRetryAfter parses the value of a Retry-After header which is either a number of seconds or an HTTP date
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the duration or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"slices"
 	"strconv"
+	"strings"
 )
 
 type SearchSeriesDetails struct {
@@ -40,6 +41,17 @@ type PartialEpisodeDetails struct {
 	StillPath      string  `json:"still_path"`
 }
 
+type PartialSeasonDetails struct {
+	ID           uint64  `json:"id"`
+	AirDate      string  `json:"air_date"`
+	EpisodeCount int     `json:"episode_count"`
+	Name         string  `json:"name"`
+	Overview     string  `json:"overview"`
+	PosterPath   string  `json:"poster_path"`
+	SeasonNumber int     `json:"season_number"`
+	VoteAverage  float64 `json:"vote_average"`
+}
+
 type SeriesDetails struct {
 	ID           uint64 `json:"id"`
 	Adult        bool   `json:"adult"`
@@ -88,16 +100,7 @@ type SeriesDetails struct {
 		Iso31661 string `json:"iso_3166_1"`
 		Name     string `json:"name"`
 	} `json:"production_countries"`
-	Seasons []struct {
-		ID           uint64  `json:"id"`
-		AirDate      string  `json:"air_date"`
-		EpisodeCount int     `json:"episode_count"`
-		Name         string  `json:"name"`
-		Overview     string  `json:"overview"`
-		PosterPath   string  `json:"poster_path"`
-		SeasonNumber int     `json:"season_number"`
-		VoteAverage  float64 `json:"vote_average"`
-	} `json:"seasons"`
+	Seasons         []PartialSeasonDetails `json:"seasons"`
 	SpokenLanguages []struct {
 		EnglishName string `json:"english_name"`
 		Iso6391     string `json:"iso_639_1"`
@@ -110,8 +113,76 @@ type SeriesDetails struct {
 	VoteCount   int     `json:"vote_count"`
 }
 
+// SeriesDetailsWithAppends is the details of a series along with the sub-resources requested with
+// RequestOptionAppendToResponse. Sub-resources that weren't requested are left empty
+type SeriesDetailsWithAppends struct {
+	SeriesDetails
+	// AppendedSeasons are the seasons requested with AppendSeason keyed by season number
+	AppendedSeasons map[int]*SeasonDetails
+	Changes         *SeriesChanges
+}
+
+func (s *SeriesDetailsWithAppends) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &s.SeriesDetails); err != nil {
+		return err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	s.AppendedSeasons = map[int]*SeasonDetails{}
+	for key, raw := range fields {
+		if after, ok := strings.CutPrefix(key, "season/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil {
+				continue
+			}
+
+			season := new(SeasonDetails)
+			if err := json.Unmarshal(raw, season); err != nil {
+				return err
+			}
+			s.AppendedSeasons[n] = season
+		} else if key == string(AppendChanges) {
+			s.Changes = new(SeriesChanges)
+			if err := json.Unmarshal(raw, s.Changes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Append is a sub-resource that can be included in the response of another request
+type Append string
+
+// AppendChanges includes the changes made to a series in the last 24 hours
+const AppendChanges Append = "changes"
+
+// AppendSeason includes the details of a season of a series
+func AppendSeason(season int) Append {
+	return Append("season/" + strconv.Itoa(season))
+}
+
+// RequestOptionAppendToResponse includes the sub-resources in the response so they don't need to be requested
+// separately. TMDB allows at most 20 sub-resources per request
+func RequestOptionAppendToResponse(appends ...Append) RequestOption {
+	values := make([]string, len(appends))
+	for i, a := range appends {
+		values[i] = string(a)
+	}
+
+	return RequestOptionWithQueryParams("append_to_response", strings.Join(values, ","))
+}
+
 type TVSeriesService interface {
 	GetTVSeriesDetails(id uint64, dst *SeriesDetails, opts ...RequestOption) (*http.Response, error)
+	// GetTVSeriesDetailsWithAppends gets the details of a series along with the sub-resources requested with
+	// RequestOptionAppendToResponse
+	GetTVSeriesDetailsWithAppends(id uint64, dst *SeriesDetailsWithAppends, opts ...RequestOption) (*http.Response, error)
 }
 
 type tvSeriesService struct {
@@ -157,3 +228,18 @@ func (ss *searchService) SearchTVSeriesDetails(name string, dst *SearchResults[*
 
 	return resp, err
 }
+
+func (tvs *tvSeriesService) GetTVSeriesDetailsWithAppends(id uint64, dst *SeriesDetailsWithAppends, opts ...RequestOption) (*http.Response, error) {
+	resp, err := tvs.do(http.MethodGet, strconv.FormatUint(id, 10), opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, err
+}
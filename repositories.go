@@ -12,23 +12,36 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-type NotificationsRepo struct {
+// NotificationsRepo stores the episodes that were delivered to each target
+type NotificationsRepo interface {
+	InsertMany(ctx context.Context, notis []*Notification) error
+	DeleteAllNotifications(ctx context.Context) (int64, error)
+	ExistsForEpisodeSeasonAndSeries(ctx context.Context, episode, season int, seriesID uint64) (bool, error)
+	GetNotifiedTargets(ctx context.Context, episode, season int, seriesID uint64) ([]NotificationTarget, error)
+	GetIncompleteForSeries(ctx context.Context, seriesID uint64) ([]*Notification, error)
+	GetLatest(ctx context.Context, limit uint64) ([]*Notification, error)
+	MarkComplete(ctx context.Context, notis ...*Notification) error
+}
+
+type SQLNotificationsRepo struct {
 	db *sqlx.DB
+	sb sq.StatementBuilderType
 }
 
-func NewNotificationsRepo(db *sqlx.DB) *NotificationsRepo {
-	return &NotificationsRepo{
+func NewSQLNotificationsRepo(db *sqlx.DB) *SQLNotificationsRepo {
+	return &SQLNotificationsRepo{
 		db: db,
+		sb: statementBuilder(db),
 	}
 }
 
-func (repo *NotificationsRepo) InsertMany(ctx context.Context, notis []*Notification) error {
+func (repo *SQLNotificationsRepo) InsertMany(ctx context.Context, notis []*Notification) error {
 	if len(notis) == 0 {
 		return nil
 	}
 
 	cols := notis[0].GetColumns()
-	builder := sq.Insert("notifications").Columns(cols...)
+	builder := repo.sb.Insert("notifications").Columns(cols...)
 	for _, noti := range notis {
 		builder = builder.Values(noti.ToColumns(cols)...)
 	}
@@ -44,8 +57,8 @@ func (repo *NotificationsRepo) InsertMany(ctx context.Context, notis []*Notifica
 	return err
 }
 
-func (repo *NotificationsRepo) DeleteAllNotifications(ctx context.Context) (int64, error) {
-	query, _, err := sq.Delete("notifications").ToSql()
+func (repo *SQLNotificationsRepo) DeleteAllNotifications(ctx context.Context) (int64, error) {
+	query, _, err := repo.sb.Delete("notifications").ToSql()
 	if err != nil {
 		return 0, err
 	}
@@ -61,8 +74,8 @@ func (repo *NotificationsRepo) DeleteAllNotifications(ctx context.Context) (int6
 	return n, err
 }
 
-func (repo *NotificationsRepo) ExistsForEpisodeSeasonAndSeries(ctx context.Context, episode, season int, seriesID uint64) (bool, error) {
-	query, args, err := sq.Select("series_id").
+func (repo *SQLNotificationsRepo) ExistsForEpisodeSeasonAndSeries(ctx context.Context, episode, season int, seriesID uint64) (bool, error) {
+	query, args, err := repo.sb.Select("series_id").
 		From("notifications").
 		Where(sq.Eq{
 			"series_id": seriesID,
@@ -87,22 +100,145 @@ func (repo *NotificationsRepo) ExistsForEpisodeSeasonAndSeries(ctx context.Conte
 	return true, nil
 }
 
-type SubscriptionsRepo struct {
+// GetNotifiedTargets returns all the targets the episode was delivered to
+func (repo *SQLNotificationsRepo) GetNotifiedTargets(ctx context.Context, episode, season int, seriesID uint64) ([]NotificationTarget, error) {
+	query, args, err := repo.sb.Select("guild_id", "user_id").
+		From("notifications").
+		Where(sq.Eq{
+			"series_id": seriesID,
+			"season":    season,
+			"episode":   episode,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting targets notified about episode", start, "query", query, "args", args)
+	targets := []NotificationTarget{}
+	if err = repo.db.SelectContext(ctx, &targets, query, args...); err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// GetIncompleteForSeries returns the notifications for the series that were delivered while the episode was
+// missing information
+func (repo *SQLNotificationsRepo) GetIncompleteForSeries(ctx context.Context, seriesID uint64) ([]*Notification, error) {
+	query, args, err := repo.sb.Select("*").
+		From("notifications").
+		Where(sq.Eq{
+			"series_id": seriesID,
+			"complete":  false,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting incomplete notifications for series", start, "query", query, "args", args)
+	notis := []*Notification{}
+	if err = repo.db.SelectContext(ctx, &notis, query, args...); err != nil {
+		return nil, err
+	}
+
+	return notis, nil
+}
+
+// GetLatest returns up to limit of the most recently delivered notifications, latest first
+func (repo *SQLNotificationsRepo) GetLatest(ctx context.Context, limit uint64) ([]*Notification, error) {
+	query, args, err := repo.sb.Select("*").
+		From("notifications").
+		OrderBy("created_at DESC", "series_id", "season", "episode").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting latest notifications", start, "query", query, "args", args)
+	notis := []*Notification{}
+	if err = repo.db.SelectContext(ctx, &notis, query, args...); err != nil {
+		return nil, err
+	}
+
+	return notis, nil
+}
+
+// MarkComplete marks the notifications as no longer needing to be updated
+func (repo *SQLNotificationsRepo) MarkComplete(ctx context.Context, notis ...*Notification) error {
+	if len(notis) == 0 {
+		return nil
+	}
+
+	where := sq.Or{}
+	for _, n := range notis {
+		where = append(where, sq.Eq{
+			"episode":   n.Episode,
+			"season":    n.Season,
+			"series_id": n.SeriesID,
+			"guild_id":  n.GuildID,
+			"user_id":   n.UserID,
+		})
+	}
+
+	query, args, err := repo.sb.Update("notifications").
+		Set("complete", true).
+		Where(where).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Marking notifications complete", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// SubscriptionsRepo stores the series users are subscribed to in each guild
+type SubscriptionsRepo interface {
+	// GetDistinctSeriesIDsWithEpoch pages through every series subscribed to, 10 at a time in order of series ID,
+	// along with when the latest subscription to the series was made
+	GetDistinctSeriesIDsWithEpoch(ctx context.Context) utils.Pager[utils.Tuple[uint64, time.Time]]
+	GetAllSubscribedToSeries(ctx context.Context, seriesID ...uint64) ([]uint64, error)
+	GetSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) ([]*Subscription, error)
+	GetUserSubscriptions(ctx context.Context, guildID, userID uint64) ([]*Subscription, error)
+	Insert(ctx context.Context, sub *Subscription) error
+	UserIsSubscribed(ctx context.Context, guildID, seriesID, userID uint64) (bool, error)
+	DeleteSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) error
+	DeleteUserSubscription(ctx context.Context, guildID, seriesID, userID uint64) error
+	// CountPerSeries returns how many subscriptions there are to every series subscribed to in order of series ID
+	CountPerSeries(ctx context.Context) ([]utils.Tuple[uint64, int], error)
+}
+
+type SQLSubscriptionsRepo struct {
 	db *sqlx.DB
+	sb sq.StatementBuilderType
 }
 
-func NewSubscriptionsRepo(db *sqlx.DB) *SubscriptionsRepo {
-	return &SubscriptionsRepo{
+func NewSQLSubscriptionsRepo(db *sqlx.DB) *SQLSubscriptionsRepo {
+	return &SQLSubscriptionsRepo{
 		db: db,
+		sb: statementBuilder(db),
 	}
 }
 
-func (repo *SubscriptionsRepo) GetDistinctSeriesIDsWithEpoch(ctx context.Context) utils.Pager[utils.Tuple[uint64, time.Time]] {
-	builder := sq.Select("series_id, MAX(created_at)").From("subscriptions").Limit(10).GroupBy("series_id")
+func (repo *SQLSubscriptionsRepo) GetDistinctSeriesIDsWithEpoch(ctx context.Context) utils.Pager[utils.Tuple[uint64, time.Time]] {
+	builder := repo.sb.Select("series_id, MAX(created_at)").
+		From("subscriptions").
+		GroupBy("series_id").
+		OrderBy("series_id").
+		Limit(10)
 	timeFormats := []string{
 		"2006-01-02 15:04:05.999999999Z07:00",
 		"2006-01-02 15:04:05",
 		"2006-01-02 15:04:05Z07:00",
+		time.RFC3339Nano,
 	}
 
 	return utils.NewPager(func(page int, buf []utils.Tuple[uint64, time.Time]) ([]utils.Tuple[uint64, time.Time], error) {
@@ -148,8 +284,8 @@ func (repo *SubscriptionsRepo) GetDistinctSeriesIDsWithEpoch(ctx context.Context
 }
 
 // GetAllSubscribedToSeries returns a slice of user IDs that are subscribed to the series ID provided
-func (repo *SubscriptionsRepo) GetAllSubscribedToSeries(ctx context.Context, seriesID ...uint64) ([]uint64, error) {
-	query, args, err := sq.Select("user_id").
+func (repo *SQLSubscriptionsRepo) GetAllSubscribedToSeries(ctx context.Context, seriesID ...uint64) ([]uint64, error) {
+	query, args, err := repo.sb.Select("DISTINCT user_id").
 		From("subscriptions").
 		Where(sq.Eq{"series_id": seriesID}).
 		ToSql()
@@ -167,11 +303,62 @@ func (repo *SubscriptionsRepo) GetAllSubscribedToSeries(ctx context.Context, ser
 	return userIDs, nil
 }
 
-// GetAllSubscribedToSeries returns a slice of user IDs that are subscribed to the series ID provided
-func (repo *SubscriptionsRepo) GetUserSubscriptions(ctx context.Context, userID uint64) ([]*Subscription, error) {
-	query, args, err := sq.Select("*").
+func (repo *SQLSubscriptionsRepo) CountPerSeries(ctx context.Context) ([]utils.Tuple[uint64, int], error) {
+	query, args, err := repo.sb.Select("series_id", "COUNT(*) AS subscriptions").
 		From("subscriptions").
-		Where(sq.Eq{"user_id": userID}).
+		GroupBy("series_id").
+		OrderBy("series_id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Counting subscriptions per series", start, "query", query, "args", args)
+	rows := []struct {
+		SeriesID      uint64 `db:"series_id"`
+		Subscriptions int    `db:"subscriptions"`
+	}{}
+	if err = repo.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	counts := make([]utils.Tuple[uint64, int], len(rows))
+	for i, row := range rows {
+		counts[i] = utils.Tuple[uint64, int]{T: row.SeriesID, V: row.Subscriptions}
+	}
+
+	return counts, nil
+}
+
+// GetSubscriptionsForSeries returns all the subscriptions in every guild for the series IDs provided
+func (repo *SQLSubscriptionsRepo) GetSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) ([]*Subscription, error) {
+	query, args, err := repo.sb.Select("*").
+		From("subscriptions").
+		Where(sq.Eq{"series_id": seriesID}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting subscriptions for series", start, "query", query, "args", args)
+	subs := []*Subscription{}
+	if err = repo.db.SelectContext(ctx, &subs, query, args...); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// GetUserSubscriptions returns the subscriptions the user has in the guild
+func (repo *SQLSubscriptionsRepo) GetUserSubscriptions(ctx context.Context, guildID, userID uint64) ([]*Subscription, error) {
+	query, args, err := repo.sb.Select("*").
+		From("subscriptions").
+		Where(sq.Eq{
+			"guild_id": guildID,
+			"user_id":  userID,
+		}).
 		ToSql()
 	if err != nil {
 		return nil, err
@@ -187,8 +374,8 @@ func (repo *SubscriptionsRepo) GetUserSubscriptions(ctx context.Context, userID
 	return subs, nil
 }
 
-func (repo *SubscriptionsRepo) Insert(ctx context.Context, sub *Subscription) error {
-	query, args, err := sq.Insert("subscriptions").
+func (repo *SQLSubscriptionsRepo) Insert(ctx context.Context, sub *Subscription) error {
+	query, args, err := repo.sb.Insert("subscriptions").
 		SetMap(sub.ToMap()).
 		ToSql()
 	if err != nil {
@@ -201,11 +388,12 @@ func (repo *SubscriptionsRepo) Insert(ctx context.Context, sub *Subscription) er
 	return err
 }
 
-func (repo *SubscriptionsRepo) UserIsSubscribed(ctx context.Context, seriesID, userID uint64) (bool, error) {
-	query, args, err := sq.Select("true").
+func (repo *SQLSubscriptionsRepo) UserIsSubscribed(ctx context.Context, guildID, seriesID, userID uint64) (bool, error) {
+	query, args, err := repo.sb.Select("true").
 		From("subscriptions").
 		Limit(1).
 		Where(sq.Eq{
+			"guild_id":  guildID,
 			"user_id":   userID,
 			"series_id": seriesID,
 		}).
@@ -227,8 +415,8 @@ func (repo *SubscriptionsRepo) UserIsSubscribed(ctx context.Context, seriesID, u
 	return f, nil
 }
 
-func (repo *SubscriptionsRepo) DeleteSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) error {
-	query, args, err := sq.Delete("subscriptions").
+func (repo *SQLSubscriptionsRepo) DeleteSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) error {
+	query, args, err := repo.sb.Delete("subscriptions").
 		Where(sq.Eq{"series_id": seriesID}).
 		ToSql()
 	if err != nil {
@@ -245,42 +433,93 @@ func (repo *SubscriptionsRepo) DeleteSubscriptionsForSeries(ctx context.Context,
 	return nil
 }
 
-type SeriesRepo struct {
+// DeleteUserSubscription deletes the subscription the user has for the series in the guild
+func (repo *SQLSubscriptionsRepo) DeleteUserSubscription(ctx context.Context, guildID, seriesID, userID uint64) error {
+	query, args, err := repo.sb.Delete("subscriptions").
+		Where(sq.Eq{
+			"guild_id":  guildID,
+			"user_id":   userID,
+			"series_id": seriesID,
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Deleting user subscription", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+type PreferencesRepo struct {
 	db *sqlx.DB
+	sb sq.StatementBuilderType
 }
 
-func NewSeriesRepo(db *sqlx.DB) *SeriesRepo {
-	return &SeriesRepo{db}
+func NewPreferencesRepo(db *sqlx.DB) *PreferencesRepo {
+	return &PreferencesRepo{db, statementBuilder(db)}
 }
 
-func (repo *SeriesRepo) GetSeriesByID(ctx context.Context, seriesID uint64) (*Series, error) {
-	query, args, err := sq.Select("*").
-		From("series").
-		Where(sq.Eq{"id": seriesID}).
-		Limit(1).
+// GetUserPreferences returns the stored preferences for the users keyed by user ID. Users that have
+// never set their preferences are not included
+func (repo *PreferencesRepo) GetUserPreferences(ctx context.Context, userIDs ...uint64) (map[uint64]*UserPreference, error) {
+	query, args, err := repo.sb.Select("*").
+		From("user_preferences").
+		Where(sq.Eq{"user_id": userIDs}).
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	series := new(Series)
 	start := time.Now()
-	defer logQuery(ctx, "Getting series by ID", start, "query", query, "args", args)
-	err = repo.db.GetContext(ctx, series, query, args...)
+	defer logQuery(ctx, "Getting user preferences", start, "query", query, "args", args)
+	prefs := []*UserPreference{}
+	if err = repo.db.SelectContext(ctx, &prefs, query, args...); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[uint64]*UserPreference, len(prefs))
+	for _, p := range prefs {
+		ret[p.UserID] = p
+	}
+
+	return ret, nil
+}
+
+// GetUsersWithDigests returns the preferences of every user that has digests turned on
+func (repo *PreferencesRepo) GetUsersWithDigests(ctx context.Context) ([]*UserPreference, error) {
+	query, args, err := repo.sb.Select("*").
+		From("user_preferences").
+		Where(sq.NotEq{"digest_frequency": DigestFrequencyOff}).
+		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	return series, nil
+	start := time.Now()
+	defer logQuery(ctx, "Getting users with digests", start, "query", query, "args", args)
+	prefs := []*UserPreference{}
+	if err = repo.db.SelectContext(ctx, &prefs, query, args...); err != nil {
+		return nil, err
+	}
+
+	return prefs, nil
 }
 
-func (repo *SeriesRepo) Upsert(ctx context.Context, s *Series) error {
-	query, args, err := sq.Insert("series").
-		SetMap(s.ToMap()).
-		Suffix(`ON CONFLICT (id) DO UPDATE SET
-			next_episode_air_date=excluded.next_episode_air_date,
-			data=excluded.data,
-			last_fetched_at=excluded.last_fetched_at
+func (repo *PreferencesRepo) Upsert(ctx context.Context, p *UserPreference) error {
+	query, args, err := repo.sb.Insert("user_preferences").
+		SetMap(p.ToMap()).
+		Suffix(`ON CONFLICT (user_id) DO UPDATE SET
+			delivery_mode=excluded.delivery_mode,
+			channel_delivery_started_at=excluded.channel_delivery_started_at,
+			dm_delivery_started_at=excluded.dm_delivery_started_at,
+			reminder_lead_minutes=excluded.reminder_lead_minutes,
+			digest_frequency=excluded.digest_frequency,
+			digest_minute=excluded.digest_minute,
+			digest_weekday=excluded.digest_weekday,
+			digest_timezone=excluded.digest_timezone,
+			digest_last_sent_at=excluded.digest_last_sent_at
 		`).
 		ToSql()
 	if err != nil {
@@ -288,12 +527,826 @@ func (repo *SeriesRepo) Upsert(ctx context.Context, s *Series) error {
 	}
 
 	start := time.Now()
-	defer logQuery(ctx, "Upserting series", start, "query", query, "args", args)
+	defer logQuery(ctx, "Upserting user preferences", start, "query", query, "args", args)
 	_, err = repo.db.ExecContext(ctx, query, args...)
 	return err
 }
 
-func logQuery(ctx context.Context, msg string, start time.Time, args ...interface{}) {
-	args = append(args, "duration", time.Since(start))
-	slog.DebugContext(ctx, msg, args...)
+type RemindersRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewRemindersRepo(db *sqlx.DB) *RemindersRepo {
+	return &RemindersRepo{db, statementBuilder(db)}
+}
+
+// GetRemindedUserIDs returns the IDs of the users that have already been reminded about the episode
+func (repo *RemindersRepo) GetRemindedUserIDs(ctx context.Context, episode, season int, seriesID uint64) ([]uint64, error) {
+	query, args, err := repo.sb.Select("user_id").
+		From("reminders").
+		Where(sq.Eq{
+			"series_id": seriesID,
+			"season":    season,
+			"episode":   episode,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting users reminded about episode", start, "query", query, "args", args)
+	userIDs := []uint64{}
+	if err = repo.db.SelectContext(ctx, &userIDs, query, args...); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+func (repo *RemindersRepo) Insert(ctx context.Context, r *Reminder) error {
+	query, args, err := repo.sb.Insert("reminders").
+		SetMap(r.ToMap()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Inserting reminder", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+type DigestRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewDigestRepo(db *sqlx.DB) *DigestRepo {
+	return &DigestRepo{db, statementBuilder(db)}
+}
+
+// InsertWithNotification queues the item for the user's next digest and records the notification for it in
+// the same transaction so the episode is never queued twice
+func (repo *DigestRepo) InsertWithNotification(ctx context.Context, item *DigestItem, noti *Notification) error {
+	itemQuery, itemArgs, err := repo.sb.Insert("digest_items").
+		SetMap(item.ToMap()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	notiQuery, notiArgs, err := repo.sb.Insert("notifications").
+		SetMap(noti.ToMap()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	start := time.Now()
+	defer logQuery(ctx, "Inserting digest item", start, "query", itemQuery, "args", itemArgs)
+	if _, err = tx.ExecContext(ctx, itemQuery, itemArgs...); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, notiQuery, notiArgs...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetUserItems returns every item waiting to be sent in the user's next digest
+func (repo *DigestRepo) GetUserItems(ctx context.Context, userID uint64) ([]*DigestItem, error) {
+	query, args, err := repo.sb.Select("*").
+		From("digest_items").
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("series_id", "season", "episode").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting user digest items", start, "query", query, "args", args)
+	items := []*DigestItem{}
+	if err = repo.db.SelectContext(ctx, &items, query, args...); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// DeleteItems deletes the items provided from their user's digest
+func (repo *DigestRepo) DeleteItems(ctx context.Context, items []*DigestItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	where := sq.Or{}
+	for _, item := range items {
+		where = append(where, sq.Eq{
+			"user_id":   item.UserID,
+			"series_id": item.SeriesID,
+			"season":    item.Season,
+			"episode":   item.Episode,
+		})
+	}
+
+	query, args, err := repo.sb.Delete("digest_items").
+		Where(where).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Deleting digest items", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+type OutboxRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewOutboxRepo(db *sqlx.DB) *OutboxRepo {
+	return &OutboxRepo{db, statementBuilder(db)}
+}
+
+// Insert adds the items to the outbox. Items already in the outbox are left untouched
+func (repo *OutboxRepo) Insert(ctx context.Context, items ...*OutboxItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	cols := []string{
+		"episode", "season", "series_id", "guild_id", "user_id", "embed", "mention_ids", "complete",
+		"attempts", "next_attempt_at", "last_error", "created_at",
+	}
+	builder := repo.sb.Insert("outbox").Columns(cols...).Suffix("ON CONFLICT DO NOTHING")
+	for _, item := range items {
+		m := item.ToMap()
+		builder = builder.Values(utils.MapSlice(cols, func(col string, _ int) any {
+			return m[col]
+		})...)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Inserting outbox items", start, "query", query)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetQueuedTargets returns all the targets the episode is waiting to be delivered to
+func (repo *OutboxRepo) GetQueuedTargets(ctx context.Context, episode, season int, seriesID uint64) ([]NotificationTarget, error) {
+	query, args, err := repo.sb.Select("guild_id", "user_id").
+		From("outbox").
+		Where(sq.Eq{
+			"series_id": seriesID,
+			"season":    season,
+			"episode":   episode,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting targets episode is queued for", start, "query", query, "args", args)
+	targets := []NotificationTarget{}
+	if err = repo.db.SelectContext(ctx, &targets, query, args...); err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// GetItems returns every item in the outbox in the order they should be delivered
+func (repo *OutboxRepo) GetItems(ctx context.Context) ([]*OutboxItem, error) {
+	query, args, err := repo.sb.Select("*").
+		From("outbox").
+		OrderBy("created_at", "series_id", "season", "episode").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting outbox items", start, "query", query, "args", args)
+	items := []*OutboxItem{}
+	if err = repo.db.SelectContext(ctx, &items, query, args...); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// MarkDelivered removes the items from the outbox and records the notifications for them. Notifications that
+// already exist, like when an episode is resent, are replaced with the new message
+func (repo *OutboxRepo) MarkDelivered(ctx context.Context, items []*OutboxItem, notis []*Notification) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	deleteQuery, deleteArgs, err := repo.sb.Delete("outbox").
+		Where(outboxItemsWhere(items)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	cols := notis[0].GetColumns()
+	builder := repo.sb.Insert("notifications").Columns(cols...).
+		Suffix("ON CONFLICT (episode, season, series_id, guild_id, user_id) DO UPDATE SET " +
+			"discord_channel_id = excluded.discord_channel_id, discord_message_id = excluded.discord_message_id, " +
+			"embed_index = excluded.embed_index, complete = excluded.complete, created_at = excluded.created_at")
+	for _, noti := range notis {
+		builder = builder.Values(noti.ToColumns(cols)...)
+	}
+	notiQuery, notiArgs, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	start := time.Now()
+	defer logQuery(ctx, "Marking outbox items delivered", start, "query", deleteQuery, "args", deleteArgs)
+	if _, err = tx.ExecContext(ctx, deleteQuery, deleteArgs...); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, notiQuery, notiArgs...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkFailed records a failed attempt at delivering the items and when they should next be attempted
+func (repo *OutboxRepo) MarkFailed(ctx context.Context, items []*OutboxItem, nextAttemptAt time.Time, lastError string) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	query, args, err := repo.sb.Update("outbox").
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("next_attempt_at", nextAttemptAt).
+		Set("last_error", lastError).
+		Where(outboxItemsWhere(items)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Marking outbox items failed", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// MarkDead records a final failed attempt at delivering the items. Dead items stay in the outbox so the episode
+// isn't queued for the target again but are never attempted again
+func (repo *OutboxRepo) MarkDead(ctx context.Context, items []*OutboxItem, deadAt time.Time, lastError string) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	query, args, err := repo.sb.Update("outbox").
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("dead_at", deadAt).
+		Set("last_error", lastError).
+		Where(outboxItemsWhere(items)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Marking outbox items dead", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func outboxItemsWhere(items []*OutboxItem) sq.Or {
+	where := sq.Or{}
+	for _, item := range items {
+		where = append(where, sq.Eq{
+			"episode":   item.Episode,
+			"season":    item.Season,
+			"series_id": item.SeriesID,
+			"guild_id":  item.GuildID,
+			"user_id":   item.UserID,
+		})
+	}
+
+	return where
+}
+
+type GuildsRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewGuildsRepo(db *sqlx.DB) *GuildsRepo {
+	return &GuildsRepo{db, statementBuilder(db)}
+}
+
+// GetGuildsByIDs returns the guilds that have been set up keyed by guild ID. Guilds that have not been
+// set up are not included
+func (repo *GuildsRepo) GetGuildsByIDs(ctx context.Context, guildIDs ...uint64) (map[uint64]*Guild, error) {
+	query, args, err := repo.sb.Select("*").
+		From("guilds").
+		Where(sq.Eq{"id": guildIDs}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting guilds by IDs", start, "query", query, "args", args)
+	guilds := []*Guild{}
+	if err = repo.db.SelectContext(ctx, &guilds, query, args...); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[uint64]*Guild, len(guilds))
+	for _, g := range guilds {
+		ret[g.ID] = g
+	}
+
+	return ret, nil
+}
+
+func (repo *GuildsRepo) Upsert(ctx context.Context, g *Guild) error {
+	query, args, err := repo.sb.Insert("guilds").
+		SetMap(g.ToMap()).
+		Suffix(`ON CONFLICT (id) DO UPDATE SET
+			notifications_channel_id=excluded.notifications_channel_id,
+			notifications_channel_set_at=excluded.notifications_channel_set_at
+		`).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Upserting guild", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// CountLegacySubscriptions returns the number of subscriptions made before the bot supported multiple guilds that
+// haven't been moved into a guild yet
+func (repo *GuildsRepo) CountLegacySubscriptions(ctx context.Context) (int64, error) {
+	query, args, err := repo.sb.Select("COUNT(*)").
+		From("subscriptions").
+		Where(sq.Eq{"guild_id": 0}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Counting legacy subscriptions", start, "query", query, "args", args)
+	var n int64
+	err = repo.db.GetContext(ctx, &n, query, args...)
+	return n, err
+}
+
+// ClaimLegacySubscriptions moves subscriptions and channel notifications made before the bot supported multiple
+// guilds into the guild provided. Legacy rows the guild already has are dropped instead of moved
+func (repo *GuildsRepo) ClaimLegacySubscriptions(ctx context.Context, guildID uint64) (int64, error) {
+	type statement struct {
+		msg   string
+		query string
+		args  []interface{}
+	}
+
+	// Copying the legacy rows into the guild before deleting them since updating them in place would fail for
+	// rows the guild already has
+	statements := []statement{}
+	claim := func(table string, legacy sq.Eq, cols ...string) error {
+		insertQuery, insertArgs, err := repo.sb.Insert(table).
+			Columns(append([]string{"guild_id"}, cols...)...).
+			Select(repo.sb.Select().
+				Column(sq.Expr("CAST(? AS BIGINT)", guildID)).
+				Columns(cols...).
+				From(table).
+				Where(legacy)).
+			Suffix("ON CONFLICT DO NOTHING").
+			ToSql()
+		if err != nil {
+			return err
+		}
+		deleteQuery, deleteArgs, err := repo.sb.Delete(table).Where(legacy).ToSql()
+		if err != nil {
+			return err
+		}
+
+		statements = append(statements,
+			statement{"Claiming legacy " + table, insertQuery, insertArgs},
+			statement{"Deleting claimed legacy " + table, deleteQuery, deleteArgs},
+		)
+		return nil
+	}
+	err := claim("subscriptions", sq.Eq{"guild_id": 0}, "series_id", "user_id", "created_at")
+	if err != nil {
+		return 0, err
+	}
+	err = claim("notifications", sq.Eq{"guild_id": 0, "user_id": 0},
+		"episode", "season", "series_id", "user_id", "discord_channel_id", "discord_message_id", "embed_index",
+		"complete", "created_at",
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var claimed int64
+	for i, stmt := range statements {
+		start := time.Now()
+		r, err := tx.ExecContext(ctx, stmt.query, stmt.args...)
+		logQuery(ctx, stmt.msg, start, "query", stmt.query, "args", stmt.args)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			claimed, _ = r.RowsAffected()
+		}
+	}
+
+	return claimed, tx.Commit()
+}
+
+// SeriesRepo caches the details of the series subscribed to
+type SeriesRepo interface {
+	// GetSeriesByID returns the cached series. sql.ErrNoRows is returned if the series isn't cached
+	GetSeriesByID(ctx context.Context, seriesID uint64) (*Series, error)
+	GetSeriesSubscribedToByUser(ctx context.Context, guildID, userID uint64) ([]*Series, error)
+	// Upsert caches the series. Whether specials are included and the check interval are left as is for series
+	// that are already cached
+	Upsert(ctx context.Context, s *Series) error
+	GetSeriesWithNextEpisode(ctx context.Context) ([]*Series, error)
+	SetIncludeSpecials(ctx context.Context, seriesID uint64, include bool) error
+	// SetCheckInterval sets how often the series is checked for new episodes along with when it's next checked.
+	// An invalid interval puts the series back on the poll schedule
+	SetCheckInterval(ctx context.Context, seriesID uint64, intervalMS Null[int64], nextCheckAt Null[time.Time]) error
+}
+
+type SQLSeriesRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewSQLSeriesRepo(db *sqlx.DB) *SQLSeriesRepo {
+	return &SQLSeriesRepo{db, statementBuilder(db)}
+}
+
+func (repo *SQLSeriesRepo) GetSeriesByID(ctx context.Context, seriesID uint64) (*Series, error) {
+	query, args, err := repo.sb.Select("*").
+		From("series").
+		Where(sq.Eq{"id": seriesID}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	series := new(Series)
+	start := time.Now()
+	defer logQuery(ctx, "Getting series by ID", start, "query", query, "args", args)
+	err = repo.db.GetContext(ctx, series, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// GetSeriesSubscribedToByUser returns the cached series for all the subscriptions the user has in the guild.
+// Subscriptions for series that have not been cached yet are not returned
+func (repo *SQLSeriesRepo) GetSeriesSubscribedToByUser(ctx context.Context, guildID, userID uint64) ([]*Series, error) {
+	query, args, err := repo.sb.Select("series.*").
+		From("series").
+		Join("subscriptions ON subscriptions.series_id = series.id").
+		Where(sq.Eq{
+			"subscriptions.guild_id": guildID,
+			"subscriptions.user_id":  userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting series subscribed to by user", start, "query", query, "args", args)
+	series := []*Series{}
+	if err = repo.db.SelectContext(ctx, &series, query, args...); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (repo *SQLSeriesRepo) Upsert(ctx context.Context, s *Series) error {
+	query, args, err := repo.sb.Insert("series").
+		SetMap(s.ToMap()).
+		Suffix(`ON CONFLICT (id) DO UPDATE SET
+			next_episode_air_date=excluded.next_episode_air_date,
+			data=excluded.data,
+			last_fetched_at=excluded.last_fetched_at,
+			next_check_at=excluded.next_check_at
+		`).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Upserting series", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetSeriesWithNextEpisode returns all the cached series that have a date set for their next episode
+func (repo *SQLSeriesRepo) GetSeriesWithNextEpisode(ctx context.Context) ([]*Series, error) {
+	query, args, err := repo.sb.Select("*").
+		From("series").
+		Where(sq.NotEq{"next_episode_air_date": nil}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting series with next episode", start, "query", query, "args", args)
+	series := []*Series{}
+	if err = repo.db.SelectContext(ctx, &series, query, args...); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// SetIncludeSpecials sets whether episodes from the specials season (season 0) of the series should be notified about
+func (repo *SQLSeriesRepo) SetIncludeSpecials(ctx context.Context, seriesID uint64, include bool) error {
+	query, args, err := repo.sb.Update("series").
+		Set("include_specials", include).
+		Where(sq.Eq{"id": seriesID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Setting include specials for series", start, "query", query, "args", args)
+	r, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := r.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (repo *SQLSeriesRepo) SetCheckInterval(ctx context.Context, seriesID uint64, intervalMS Null[int64], nextCheckAt Null[time.Time]) error {
+	query, args, err := repo.sb.Update("series").
+		Set("check_interval_ms", intervalMS).
+		Set("next_check_at", nextCheckAt).
+		Where(sq.Eq{"id": seriesID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Setting check interval for series", start, "query", query, "args", args)
+	r, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := r.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// statementBuilder returns a query builder that uses the placeholders the database's driver expects
+func statementBuilder(db *sqlx.DB) sq.StatementBuilderType {
+	if sqlx.BindType(db.DriverName()) == sqlx.DOLLAR {
+		return sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	}
+
+	return sq.StatementBuilder
+}
+
+func logQuery(ctx context.Context, msg string, start time.Time, args ...interface{}) {
+	args = append(args, "duration", time.Since(start))
+	slog.DebugContext(ctx, msg, args...)
+}
+
+type WatermarksRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewWatermarksRepo(db *sqlx.DB) *WatermarksRepo {
+	return &WatermarksRepo{db, statementBuilder(db)}
+}
+
+// Get returns the time the watermark was last moved to. sql.ErrNoRows is returned if it has never been set
+func (repo *WatermarksRepo) Get(ctx context.Context, name string) (time.Time, error) {
+	query, args, err := repo.sb.Select("at").
+		From("watermarks").
+		Where(sq.Eq{"name": name}).
+		ToSql()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting watermark", start, "query", query, "args", args)
+	at := time.Time{}
+	if err = repo.db.GetContext(ctx, &at, query, args...); err != nil {
+		return time.Time{}, err
+	}
+
+	return at, nil
+}
+
+// Set moves the watermark to the time provided
+func (repo *WatermarksRepo) Set(ctx context.Context, name string, at time.Time) error {
+	query, args, err := repo.sb.Insert("watermarks").
+		SetMap(map[string]any{
+			"name": name,
+			"at":   at,
+		}).
+		Suffix("ON CONFLICT (name) DO UPDATE SET at=excluded.at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Setting watermark", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+type JobRunsRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewJobRunsRepo(db *sqlx.DB) *JobRunsRepo {
+	return &JobRunsRepo{db, statementBuilder(db)}
+}
+
+func (repo *JobRunsRepo) Insert(ctx context.Context, run *JobRun) error {
+	query, args, err := repo.sb.Insert("job_runs").
+		SetMap(run.ToMap()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Inserting job run", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetLatest returns up to limit of the most recent runs of the job starting with the latest
+func (repo *JobRunsRepo) GetLatest(ctx context.Context, job string, limit uint64) ([]*JobRun, error) {
+	query, args, err := repo.sb.Select("*").
+		From("job_runs").
+		Where(sq.Eq{"job": job}).
+		OrderBy("started_at DESC", "id DESC").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting latest job runs", start, "query", query, "args", args)
+	runs := []*JobRun{}
+	if err = repo.db.SelectContext(ctx, &runs, query, args...); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+type LeasesRepo struct {
+	db *sqlx.DB
+	sb sq.StatementBuilderType
+}
+
+func NewLeasesRepo(db *sqlx.DB) *LeasesRepo {
+	return &LeasesRepo{db, statementBuilder(db)}
+}
+
+// Get returns the lease. sql.ErrNoRows is returned if the lease has never been acquired or was released
+func (repo *LeasesRepo) Get(ctx context.Context, name string) (*Lease, error) {
+	query, args, err := repo.sb.Select("*").
+		From("leases").
+		Where(sq.Eq{"name": name}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Getting lease", start, "query", query, "args", args)
+	lease := &Lease{}
+	if err = repo.db.GetContext(ctx, lease, query, args...); err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// Insert creates the lease. False is returned if the lease already exists
+func (repo *LeasesRepo) Insert(ctx context.Context, l *Lease) (bool, error) {
+	query, args, err := repo.sb.Insert("leases").
+		SetMap(l.ToMap()).
+		Suffix("ON CONFLICT (name) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Inserting lease", start, "query", query, "args", args)
+	r, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := r.RowsAffected()
+	return n > 0, err
+}
+
+// Update overwrites the lease if it's still at the term provided. False is returned if the lease was written by
+// another instance since it was read
+func (repo *LeasesRepo) Update(ctx context.Context, l *Lease, term int64) (bool, error) {
+	query, args, err := repo.sb.Update("leases").
+		SetMap(l.ToMap()).
+		Where(sq.Eq{"name": l.Name, "term": term}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Updating lease", start, "query", query, "args", args)
+	r, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := r.RowsAffected()
+	return n > 0, err
+}
+
+// Delete releases the lease if it's held by the holder provided
+func (repo *LeasesRepo) Delete(ctx context.Context, name, holder string) error {
+	query, args, err := repo.sb.Delete("leases").
+		Where(sq.Eq{"name": name, "holder": holder}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer logQuery(ctx, "Deleting lease", start, "query", query, "args", args)
+	_, err = repo.db.ExecContext(ctx, query, args...)
+	return err
 }
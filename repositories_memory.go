@@ -0,0 +1,421 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/duke605/tv-bot/moviedb"
+	"github.com/duke605/tv-bot/utils"
+)
+
+var (
+	_ NotificationsRepo = (*MemoryNotificationsRepo)(nil)
+	_ SubscriptionsRepo = (*MemorySubscriptionsRepo)(nil)
+	_ SeriesRepo        = (*MemorySeriesRepo)(nil)
+)
+
+type notificationKey struct {
+	episodeKey
+	SeriesID uint64
+	NotificationTarget
+}
+
+type subscriptionKey struct {
+	GuildID  uint64
+	SeriesID uint64
+	UserID   uint64
+}
+
+// MemoryStore holds the data of the in-memory repos. Repos created with the same store see each other's data the
+// same way the SQL repos do through the database. The SQL repos that touch the same tables in a transaction, like
+// the outbox and guilds repos, do not see the store
+type MemoryStore struct {
+	mu            sync.Mutex
+	notifications map[notificationKey]Notification
+	subscriptions map[subscriptionKey]Subscription
+	series        map[uint64]Series
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		notifications: map[notificationKey]Notification{},
+		subscriptions: map[subscriptionKey]Subscription{},
+		series:        map[uint64]Series{},
+	}
+}
+
+type MemoryNotificationsRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryNotificationsRepo(store *MemoryStore) *MemoryNotificationsRepo {
+	return &MemoryNotificationsRepo{store}
+}
+
+func (repo *MemoryNotificationsRepo) InsertMany(ctx context.Context, notis []*Notification) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	// Checking every key first so nothing is inserted if one is taken, the same as a failed insert statement
+	keys := make([]notificationKey, len(notis))
+	for i, n := range notis {
+		keys[i] = notificationKey{episodeKey{n.Season, n.Episode}, n.SeriesID, n.NotificationTarget}
+		if _, ok := repo.store.notifications[keys[i]]; ok || slices.Contains(keys[:i], keys[i]) {
+			return fmt.Errorf("notification for episode %d of season %d of series %d already exists", n.Episode, n.Season, n.SeriesID)
+		}
+	}
+	for i, n := range notis {
+		repo.store.notifications[keys[i]] = *n
+	}
+
+	return nil
+}
+
+func (repo *MemoryNotificationsRepo) DeleteAllNotifications(ctx context.Context) (int64, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	n := len(repo.store.notifications)
+	clear(repo.store.notifications)
+
+	return int64(n), nil
+}
+
+func (repo *MemoryNotificationsRepo) ExistsForEpisodeSeasonAndSeries(ctx context.Context, episode, season int, seriesID uint64) (bool, error) {
+	targets, err := repo.GetNotifiedTargets(ctx, episode, season, seriesID)
+	return len(targets) > 0, err
+}
+
+func (repo *MemoryNotificationsRepo) GetNotifiedTargets(ctx context.Context, episode, season int, seriesID uint64) ([]NotificationTarget, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	targets := []NotificationTarget{}
+	for key := range repo.store.notifications {
+		if key.Episode == episode && key.Season == season && key.SeriesID == seriesID {
+			targets = append(targets, key.NotificationTarget)
+		}
+	}
+
+	return targets, nil
+}
+
+func (repo *MemoryNotificationsRepo) GetIncompleteForSeries(ctx context.Context, seriesID uint64) ([]*Notification, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	notis := []*Notification{}
+	for _, n := range repo.store.notifications {
+		if n.SeriesID == seriesID && !n.Complete {
+			notis = append(notis, &n)
+		}
+	}
+
+	return notis, nil
+}
+
+func (repo *MemoryNotificationsRepo) GetLatest(ctx context.Context, limit uint64) ([]*Notification, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	notis := []*Notification{}
+	for _, n := range repo.store.notifications {
+		notis = append(notis, &n)
+	}
+	slices.SortFunc(notis, func(a, b *Notification) int {
+		return cmp.Or(
+			b.CreatedAt.Compare(a.CreatedAt),
+			cmp.Compare(a.SeriesID, b.SeriesID),
+			cmp.Compare(a.Season, b.Season),
+			cmp.Compare(a.Episode, b.Episode),
+		)
+	})
+
+	return notis[:min(uint64(len(notis)), limit)], nil
+}
+
+func (repo *MemoryNotificationsRepo) MarkComplete(ctx context.Context, notis ...*Notification) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	for _, n := range notis {
+		key := notificationKey{episodeKey{n.Season, n.Episode}, n.SeriesID, n.NotificationTarget}
+		if stored, ok := repo.store.notifications[key]; ok {
+			stored.Complete = true
+			repo.store.notifications[key] = stored
+		}
+	}
+
+	return nil
+}
+
+type MemorySubscriptionsRepo struct {
+	store *MemoryStore
+}
+
+func NewMemorySubscriptionsRepo(store *MemoryStore) *MemorySubscriptionsRepo {
+	return &MemorySubscriptionsRepo{store}
+}
+
+func (repo *MemorySubscriptionsRepo) GetDistinctSeriesIDsWithEpoch(ctx context.Context) utils.Pager[utils.Tuple[uint64, time.Time]] {
+	return utils.NewPager(func(page int, buf []utils.Tuple[uint64, time.Time]) ([]utils.Tuple[uint64, time.Time], error) {
+		repo.store.mu.Lock()
+		defer repo.store.mu.Unlock()
+
+		// Working out the epochs on every page like the query does so subscriptions made while paging are seen
+		epochs := map[uint64]time.Time{}
+		for _, sub := range repo.store.subscriptions {
+			if epoch, ok := epochs[sub.SeriesID]; !ok || sub.CreatedAt.After(epoch) {
+				epochs[sub.SeriesID] = sub.CreatedAt
+			}
+		}
+		ids := make([]uint64, 0, len(epochs))
+		for id := range epochs {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+
+		buf = buf[:0]
+		for i := page * 10; i < len(ids) && i < (page+1)*10; i++ {
+			buf = append(buf, utils.Tuple[uint64, time.Time]{T: ids[i], V: epochs[ids[i]]})
+		}
+
+		return buf, nil
+	})
+}
+
+func (repo *MemorySubscriptionsRepo) GetAllSubscribedToSeries(ctx context.Context, seriesID ...uint64) ([]uint64, error) {
+	subs, err := repo.GetSubscriptionsForSeries(ctx, seriesID...)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := []uint64{}
+	for _, sub := range subs {
+		if !slices.Contains(userIDs, sub.UserID) {
+			userIDs = append(userIDs, sub.UserID)
+		}
+	}
+
+	return userIDs, nil
+}
+
+func (repo *MemorySubscriptionsRepo) GetSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) ([]*Subscription, error) {
+	return repo.filter(func(sub *Subscription) bool {
+		return slices.Contains(seriesID, sub.SeriesID)
+	}), nil
+}
+
+func (repo *MemorySubscriptionsRepo) GetUserSubscriptions(ctx context.Context, guildID, userID uint64) ([]*Subscription, error) {
+	return repo.filter(func(sub *Subscription) bool {
+		return sub.GuildID == guildID && sub.UserID == userID
+	}), nil
+}
+
+func (repo *MemorySubscriptionsRepo) Insert(ctx context.Context, sub *Subscription) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	key := subscriptionKey{sub.GuildID, sub.SeriesID, sub.UserID}
+	if _, ok := repo.store.subscriptions[key]; ok {
+		return fmt.Errorf("user %d is already subscribed to series %d in guild %d", sub.UserID, sub.SeriesID, sub.GuildID)
+	}
+	repo.store.subscriptions[key] = *sub
+
+	return nil
+}
+
+func (repo *MemorySubscriptionsRepo) UserIsSubscribed(ctx context.Context, guildID, seriesID, userID uint64) (bool, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	_, ok := repo.store.subscriptions[subscriptionKey{guildID, seriesID, userID}]
+	return ok, nil
+}
+
+func (repo *MemorySubscriptionsRepo) DeleteSubscriptionsForSeries(ctx context.Context, seriesID ...uint64) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	for key := range repo.store.subscriptions {
+		if slices.Contains(seriesID, key.SeriesID) {
+			delete(repo.store.subscriptions, key)
+		}
+	}
+
+	return nil
+}
+
+func (repo *MemorySubscriptionsRepo) DeleteUserSubscription(ctx context.Context, guildID, seriesID, userID uint64) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	delete(repo.store.subscriptions, subscriptionKey{guildID, seriesID, userID})
+	return nil
+}
+
+func (repo *MemorySubscriptionsRepo) CountPerSeries(ctx context.Context) ([]utils.Tuple[uint64, int], error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	counts := map[uint64]int{}
+	for key := range repo.store.subscriptions {
+		counts[key.SeriesID]++
+	}
+	ret := make([]utils.Tuple[uint64, int], 0, len(counts))
+	for id, n := range counts {
+		ret = append(ret, utils.Tuple[uint64, int]{T: id, V: n})
+	}
+	slices.SortFunc(ret, func(a, b utils.Tuple[uint64, int]) int {
+		return cmp.Compare(a.T, b.T)
+	})
+
+	return ret, nil
+}
+
+// filter returns copies of the subscriptions the function returns true for
+func (repo *MemorySubscriptionsRepo) filter(fn func(*Subscription) bool) []*Subscription {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	subs := []*Subscription{}
+	for _, sub := range repo.store.subscriptions {
+		if fn(&sub) {
+			subs = append(subs, &sub)
+		}
+	}
+
+	return subs
+}
+
+type MemorySeriesRepo struct {
+	store *MemoryStore
+}
+
+func NewMemorySeriesRepo(store *MemoryStore) *MemorySeriesRepo {
+	return &MemorySeriesRepo{store}
+}
+
+func (repo *MemorySeriesRepo) GetSeriesByID(ctx context.Context, seriesID uint64) (*Series, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	s, ok := repo.store.series[seriesID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return copySeries(s)
+}
+
+func (repo *MemorySeriesRepo) GetSeriesSubscribedToByUser(ctx context.Context, guildID, userID uint64) ([]*Series, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	ret := []*Series{}
+	for key := range repo.store.subscriptions {
+		s, ok := repo.store.series[key.SeriesID]
+		if !ok || key.GuildID != guildID || key.UserID != userID {
+			continue
+		}
+
+		copied, err := copySeries(s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, copied)
+	}
+
+	return ret, nil
+}
+
+func (repo *MemorySeriesRepo) Upsert(ctx context.Context, s *Series) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	copied, err := copySeries(*s)
+	if err != nil {
+		return err
+	}
+	if existing, ok := repo.store.series[s.ID]; ok {
+		copied.IncludeSpecials = existing.IncludeSpecials
+		copied.CheckIntervalMS = existing.CheckIntervalMS
+	}
+	repo.store.series[s.ID] = *copied
+
+	return nil
+}
+
+func (repo *MemorySeriesRepo) GetSeriesWithNextEpisode(ctx context.Context) ([]*Series, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	ret := []*Series{}
+	for _, s := range repo.store.series {
+		if !s.NextEpisodeAirDate.Valid {
+			continue
+		}
+
+		copied, err := copySeries(s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, copied)
+	}
+
+	return ret, nil
+}
+
+func (repo *MemorySeriesRepo) SetIncludeSpecials(ctx context.Context, seriesID uint64, include bool) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	s, ok := repo.store.series[seriesID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	s.IncludeSpecials = include
+	repo.store.series[seriesID] = s
+
+	return nil
+}
+
+func (repo *MemorySeriesRepo) SetCheckInterval(ctx context.Context, seriesID uint64, intervalMS Null[int64], nextCheckAt Null[time.Time]) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	s, ok := repo.store.series[seriesID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	s.CheckIntervalMS = intervalMS
+	s.NextCheckAt = nextCheckAt
+	repo.store.series[seriesID] = s
+
+	return nil
+}
+
+// copySeries copies the series along with its details so changes to the copy aren't seen by the store, the same
+// as the details being read back from the database
+func copySeries(s Series) (*Series, error) {
+	if s.Data.V == nil {
+		return &s, nil
+	}
+
+	b, err := json.Marshal(s.Data.V)
+	if err != nil {
+		return nil, err
+	}
+	s.Data.V = &moviedb.SeriesDetails{}
+	if err = json.Unmarshal(b, s.Data.V); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
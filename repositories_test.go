@@ -0,0 +1,831 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/duke605/tv-bot/moviedb"
+	"github.com/duke605/tv-bot/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type repos struct {
+	notis  NotificationsRepo
+	subs   SubscriptionsRepo
+	series SeriesRepo
+}
+
+// repoBackends are the implementations the repo conformance tests are run against. All of them are expected to
+// behave the same for every test
+var repoBackends = []struct {
+	name string
+	new  func(t *testing.T) *repos
+}{
+	{
+		name: "sqlite",
+		new: func(t *testing.T) *repos {
+			db := newTestDB(t)
+			return &repos{NewSQLNotificationsRepo(db), NewSQLSubscriptionsRepo(db), NewSQLSeriesRepo(db)}
+		},
+	},
+	{
+		name: "postgres",
+		new: func(t *testing.T) *repos {
+			db := newPostgresTestDB(t)
+			return &repos{NewSQLNotificationsRepo(db), NewSQLSubscriptionsRepo(db), NewSQLSeriesRepo(db)}
+		},
+	},
+	{
+		name: "memory",
+		new: func(t *testing.T) *repos {
+			store := NewMemoryStore()
+			return &repos{NewMemoryNotificationsRepo(store), NewMemorySubscriptionsRepo(store), NewMemorySeriesRepo(store)}
+		},
+	},
+}
+
+// newPostgresTestDB creates a migrated schema in the Postgres database at TEST_POSTGRES_DSN that is dropped when the
+// test finishes. The test is skipped if TEST_POSTGRES_DSN is not set unless it's running in CI, where Postgres is
+// always provided, so the Postgres tests can't silently stop running
+func newPostgresTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" && os.Getenv("CI") != "" {
+		t.Fatal("TEST_POSTGRES_DSN must be set in CI")
+	} else if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sqlx.Connect("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	config, err := pgx.ParseConfig(dsn)
+	require.NoError(t, err)
+	config.RuntimeParams["search_path"] = schema
+	db := sqlx.NewDb(stdlib.OpenDB(*config), "pgx")
+	t.Cleanup(func() { db.Close() })
+
+	dir, err := setupGoose(db)
+	require.NoError(t, err)
+	require.NoError(t, goose.Up(db.DB, dir))
+
+	return db
+}
+
+// runRepoConformance runs the test against every repo backend
+func runRepoConformance(t *testing.T, test func(t *testing.T, ctx context.Context, r *repos)) {
+	for _, backend := range repoBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, context.Background(), backend.new(t))
+		})
+	}
+}
+
+// sqlRepoBackends are the databases the repos that only have a SQL implementation are tested against
+var sqlRepoBackends = []struct {
+	name string
+	new  func(t *testing.T) *sqlx.DB
+}{
+	{name: "sqlite", new: newTestDB},
+	{name: "postgres", new: newPostgresTestDB},
+}
+
+// runSQLRepoConformance runs the test against every database the SQL repos support
+func runSQLRepoConformance(t *testing.T, test func(t *testing.T, ctx context.Context, db *sqlx.DB)) {
+	for _, backend := range sqlRepoBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, context.Background(), backend.new(t))
+		})
+	}
+}
+
+func TestNotificationsRepoGetNotifiedTargets(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		require.NoError(t, r.notis.InsertMany(ctx, []*Notification{
+			{Episode: 2, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}},
+			{Episode: 2, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{UserID: 10}},
+			{Episode: 3, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 2}},
+			{Episode: 2, Season: 1, SeriesID: 101, NotificationTarget: NotificationTarget{GuildID: 3}},
+		}))
+
+		// Acting
+		targets, err := r.notis.GetNotifiedTargets(ctx, 2, 1, 100)
+		exists, existsErr := r.notis.ExistsForEpisodeSeasonAndSeries(ctx, 2, 1, 100)
+		missing, missingErr := r.notis.ExistsForEpisodeSeasonAndSeries(ctx, 1, 1, 100)
+
+		// Asserting
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []NotificationTarget{{GuildID: 1}, {UserID: 10}}, targets)
+		require.NoError(t, existsErr)
+		assert.True(t, exists)
+		require.NoError(t, missingErr)
+		assert.False(t, missing)
+	})
+}
+
+func TestNotificationsRepoInsertManyRejectsExistingNotifications(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		existing := &Notification{Episode: 2, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}}
+		require.NoError(t, r.notis.InsertMany(ctx, []*Notification{existing}))
+
+		// Acting
+		err := r.notis.InsertMany(ctx, []*Notification{
+			{Episode: 3, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}},
+			existing,
+		})
+
+		// Asserting
+		assert.Error(t, err)
+		exists, err := r.notis.ExistsForEpisodeSeasonAndSeries(ctx, 3, 1, 100)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestNotificationsRepoMarkComplete(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		createdAt := time.Date(2024, 1, 17, 12, 0, 0, 0, time.Local)
+		notis := []*Notification{
+			{Episode: 1, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}, DiscordMessageID: 5, CreatedAt: createdAt},
+			{Episode: 2, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}, CreatedAt: createdAt},
+			{Episode: 2, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{UserID: 10}, CreatedAt: createdAt},
+			{Episode: 3, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}, Complete: true},
+			{Episode: 1, Season: 1, SeriesID: 101, NotificationTarget: NotificationTarget{GuildID: 1}},
+		}
+		require.NoError(t, r.notis.InsertMany(ctx, notis))
+
+		// Acting
+		before, err := r.notis.GetIncompleteForSeries(ctx, 100)
+		require.NoError(t, err)
+		require.NoError(t, r.notis.MarkComplete(ctx, notis[1]))
+		after, err := r.notis.GetIncompleteForSeries(ctx, 100)
+
+		// Asserting
+		require.NoError(t, err)
+		require.Len(t, before, 3)
+		require.Len(t, after, 2)
+		assert.ElementsMatch(t, []NotificationTarget{{GuildID: 1}, {UserID: 10}}, utils.MapSlice(after, func(n *Notification, _ int) NotificationTarget {
+			return n.NotificationTarget
+		}))
+		for _, n := range after {
+			assert.True(t, createdAt.Equal(n.CreatedAt))
+			if n.Episode == 1 {
+				assert.Equal(t, uint64(5), n.DiscordMessageID)
+			}
+		}
+	})
+}
+
+func TestNotificationsRepoGetLatest(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		at := time.Date(2024, 1, 17, 12, 0, 0, 0, time.Local)
+		require.NoError(t, r.notis.InsertMany(ctx, []*Notification{
+			{Episode: 1, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}, CreatedAt: at},
+			{Episode: 3, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}, CreatedAt: at.Add(time.Hour * 2)},
+			{Episode: 2, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{UserID: 10}, CreatedAt: at.Add(time.Hour)},
+		}))
+
+		// Acting
+		notis, err := r.notis.GetLatest(ctx, 2)
+
+		// Asserting
+		require.NoError(t, err)
+		assert.Equal(t, []int{3, 2}, utils.MapSlice(notis, func(n *Notification, _ int) int {
+			return n.Episode
+		}))
+	})
+}
+
+func TestNotificationsRepoDeleteAllNotifications(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		require.NoError(t, r.notis.InsertMany(ctx, []*Notification{
+			{Episode: 1, Season: 1, SeriesID: 100},
+			{Episode: 2, Season: 1, SeriesID: 100},
+		}))
+
+		// Acting
+		n, err := r.notis.DeleteAllNotifications(ctx)
+
+		// Asserting
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+		exists, err := r.notis.ExistsForEpisodeSeasonAndSeries(ctx, 1, 1, 100)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestSubscriptionsRepoGetDistinctSeriesIDsWithEpoch(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		start := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+		expected := []utils.Tuple[uint64, time.Time]{}
+		for i := 12; i > 0; i-- {
+			createdAt := start.Add(time.Hour * time.Duration(i))
+			require.NoError(t, r.subs.Insert(ctx, &Subscription{GuildID: 1, SeriesID: uint64(i), UserID: 10, CreatedAt: createdAt}))
+			require.NoError(t, r.subs.Insert(ctx, &Subscription{GuildID: 2, SeriesID: uint64(i), UserID: 10, CreatedAt: createdAt.Add(time.Minute)}))
+			expected = append([]utils.Tuple[uint64, time.Time]{{T: uint64(i), V: createdAt.Add(time.Minute)}}, expected...)
+		}
+
+		// Acting
+		pager := r.subs.GetDistinctSeriesIDsWithEpoch(ctx)
+		actual := []utils.Tuple[uint64, time.Time]{}
+		for {
+			row, more, err := pager.Next()
+			require.NoError(t, err)
+			if !more {
+				break
+			}
+			actual = append(actual, row)
+		}
+
+		// Asserting
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			assert.Equal(t, expected[i].T, actual[i].T)
+			assert.True(t, expected[i].V.Equal(actual[i].V), "epoch of series %d is %s", actual[i].T, actual[i].V)
+		}
+	})
+}
+
+func TestSubscriptionsRepoQueries(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		for _, sub := range []*Subscription{
+			{GuildID: 1, SeriesID: 100, UserID: 10},
+			{GuildID: 1, SeriesID: 100, UserID: 11},
+			{GuildID: 2, SeriesID: 100, UserID: 10},
+			{GuildID: 1, SeriesID: 101, UserID: 10},
+			{GuildID: 1, SeriesID: 102, UserID: 12},
+		} {
+			require.NoError(t, r.subs.Insert(ctx, sub))
+		}
+
+		// Acting
+		userIDs, err := r.subs.GetAllSubscribedToSeries(ctx, 100, 101)
+		require.NoError(t, err)
+		seriesSubs, err := r.subs.GetSubscriptionsForSeries(ctx, 100)
+		require.NoError(t, err)
+		userSubs, err := r.subs.GetUserSubscriptions(ctx, 1, 10)
+		require.NoError(t, err)
+		subscribed, err := r.subs.UserIsSubscribed(ctx, 2, 100, 10)
+		require.NoError(t, err)
+		notSubscribed, err := r.subs.UserIsSubscribed(ctx, 2, 101, 10)
+		require.NoError(t, err)
+		duplicateErr := r.subs.Insert(ctx, &Subscription{GuildID: 1, SeriesID: 100, UserID: 10})
+		counts, err := r.subs.CountPerSeries(ctx)
+		require.NoError(t, err)
+
+		// Asserting
+		assert.ElementsMatch(t, []uint64{10, 11}, userIDs)
+		assert.Len(t, seriesSubs, 3)
+		assert.ElementsMatch(t, []uint64{100, 101}, utils.MapSlice(userSubs, func(s *Subscription, _ int) uint64 {
+			return s.SeriesID
+		}))
+		assert.True(t, subscribed)
+		assert.False(t, notSubscribed)
+		assert.Error(t, duplicateErr)
+		assert.Equal(t, []utils.Tuple[uint64, int]{{T: 100, V: 3}, {T: 101, V: 1}, {T: 102, V: 1}}, counts)
+	})
+}
+
+func TestSubscriptionsRepoDeletes(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		for _, sub := range []*Subscription{
+			{GuildID: 1, SeriesID: 100, UserID: 10},
+			{GuildID: 2, SeriesID: 100, UserID: 11},
+			{GuildID: 1, SeriesID: 101, UserID: 10},
+			{GuildID: 1, SeriesID: 102, UserID: 10},
+			{GuildID: 1, SeriesID: 102, UserID: 11},
+		} {
+			require.NoError(t, r.subs.Insert(ctx, sub))
+		}
+
+		// Acting
+		require.NoError(t, r.subs.DeleteSubscriptionsForSeries(ctx, 100, 101))
+		require.NoError(t, r.subs.DeleteUserSubscription(ctx, 1, 102, 11))
+
+		// Asserting
+		subs, err := r.subs.GetSubscriptionsForSeries(ctx, 100, 101, 102)
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		assert.Equal(t, Subscription{GuildID: 1, SeriesID: 102, UserID: 10}, Subscription{
+			GuildID:  subs[0].GuildID,
+			SeriesID: subs[0].SeriesID,
+			UserID:   subs[0].UserID,
+		})
+	})
+}
+
+func TestSeriesRepoUpsertKeepsIncludeSpecials(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		lastFetchedAt := time.Date(2024, 1, 17, 12, 0, 0, 0, time.Local)
+		series := &Series{ID: 100, LastFetchedAt: lastFetchedAt}
+		series.Data.V = &moviedb.SeriesDetails{ID: 100, Name: "The Test Series"}
+		require.NoError(t, r.series.Upsert(ctx, series))
+		require.NoError(t, r.series.SetIncludeSpecials(ctx, 100, true))
+
+		// Acting
+		series.Data.V.Name = "The Renamed Series"
+		series.NextEpisodeAirDate = NewNull(lastFetchedAt.AddDate(0, 0, 7), true)
+		require.NoError(t, r.series.Upsert(ctx, series))
+		series.Data.V.Name = "Not saved"
+		actual, err := r.series.GetSeriesByID(ctx, 100)
+
+		// Asserting
+		require.NoError(t, err)
+		assert.Equal(t, "The Renamed Series", actual.Data.V.Name)
+		assert.True(t, actual.IncludeSpecials)
+		assert.True(t, lastFetchedAt.Equal(actual.LastFetchedAt))
+		assert.True(t, actual.NextEpisodeAirDate.Valid)
+		assert.True(t, lastFetchedAt.AddDate(0, 0, 7).Equal(actual.NextEpisodeAirDate.V))
+	})
+}
+
+func TestSeriesRepoUpsertKeepsCheckInterval(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		lastFetchedAt := time.Date(2024, 1, 17, 12, 0, 0, 0, time.Local)
+		series := &Series{ID: 100, LastFetchedAt: lastFetchedAt}
+		series.Data.V = &moviedb.SeriesDetails{ID: 100}
+		require.NoError(t, r.series.Upsert(ctx, series))
+		require.NoError(t, r.series.SetCheckInterval(ctx, 100, NewNull(time.Hour.Milliseconds(), true), NewNull(lastFetchedAt.Add(time.Hour), true)))
+
+		// Acting
+		series.NextCheckAt = NewNull(lastFetchedAt.Add(time.Hour*2), true)
+		require.NoError(t, r.series.Upsert(ctx, series))
+		actual, err := r.series.GetSeriesByID(ctx, 100)
+
+		// Asserting
+		require.NoError(t, err)
+		interval, ok := actual.CheckInterval()
+		assert.True(t, ok)
+		assert.Equal(t, time.Hour, interval)
+		assert.True(t, actual.NextCheckAt.Valid)
+		assert.True(t, lastFetchedAt.Add(time.Hour*2).Equal(actual.NextCheckAt.V))
+	})
+}
+
+func TestSeriesRepoMissingSeries(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Acting
+		_, getErr := r.series.GetSeriesByID(ctx, 100)
+		setErr := r.series.SetIncludeSpecials(ctx, 100, true)
+		setIntervalErr := r.series.SetCheckInterval(ctx, 100, NewNull(int64(0), false), NewNull(time.Time{}, false))
+
+		// Asserting
+		assert.ErrorIs(t, getErr, sql.ErrNoRows)
+		assert.ErrorIs(t, setErr, sql.ErrNoRows)
+		assert.ErrorIs(t, setIntervalErr, sql.ErrNoRows)
+	})
+}
+
+func TestSeriesRepoQueries(t *testing.T) {
+	runRepoConformance(t, func(t *testing.T, ctx context.Context, r *repos) {
+		// Arranging
+		nextEpisode := time.Date(2024, 1, 24, 0, 0, 0, 0, time.Local)
+		for _, s := range []*Series{
+			{ID: 100, NextEpisodeAirDate: NewNull(nextEpisode, true)},
+			{ID: 101},
+			{ID: 102, NextEpisodeAirDate: NewNull(nextEpisode, true)},
+		} {
+			s.Data.V = &moviedb.SeriesDetails{ID: s.ID}
+			require.NoError(t, r.series.Upsert(ctx, s))
+		}
+		for _, sub := range []*Subscription{
+			{GuildID: 1, SeriesID: 100, UserID: 10},
+			{GuildID: 1, SeriesID: 101, UserID: 10},
+			{GuildID: 1, SeriesID: 103, UserID: 10},
+			{GuildID: 2, SeriesID: 102, UserID: 10},
+		} {
+			require.NoError(t, r.subs.Insert(ctx, sub))
+		}
+		ids := func(series []*Series, err error) []uint64 {
+			require.NoError(t, err)
+			return utils.MapSlice(series, func(s *Series, _ int) uint64 {
+				return s.Data.V.ID
+			})
+		}
+
+		// Acting
+		subscribed := ids(r.series.GetSeriesSubscribedToByUser(ctx, 1, 10))
+		withNextEpisode := ids(r.series.GetSeriesWithNextEpisode(ctx))
+
+		// Asserting
+		assert.ElementsMatch(t, []uint64{100, 101}, subscribed)
+		assert.ElementsMatch(t, []uint64{100, 102}, withNextEpisode)
+	})
+}
+
+func TestGuildsRepoClaimLegacySubscriptions(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		guilds := NewGuildsRepo(db)
+		subs := NewSQLSubscriptionsRepo(db)
+		notis := NewSQLNotificationsRepo(db)
+		subscribedAt := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		for _, sub := range []*Subscription{
+			{SeriesID: 100, UserID: 10, CreatedAt: subscribedAt},
+			{SeriesID: 101, UserID: 10, CreatedAt: subscribedAt},
+			{GuildID: 1, SeriesID: 100, UserID: 10, CreatedAt: subscribedAt.Add(time.Hour)},
+		} {
+			require.NoError(t, subs.Insert(ctx, sub))
+		}
+		require.NoError(t, notis.InsertMany(ctx, []*Notification{
+			{Episode: 1, Season: 1, SeriesID: 100, DiscordMessageID: 5},
+			{Episode: 2, Season: 1, SeriesID: 100, DiscordMessageID: 6},
+			{Episode: 1, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{GuildID: 1}, DiscordMessageID: 7},
+		}))
+
+		// Acting
+		claimed, err := guilds.ClaimLegacySubscriptions(ctx, 1)
+
+		// Asserting
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), claimed)
+		legacy, err := guilds.CountLegacySubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, legacy)
+		series100, err := subs.GetSubscriptionsForSeries(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, series100, 1)
+		assert.Equal(t, uint64(1), series100[0].GuildID)
+		assert.True(t, subscribedAt.Add(time.Hour).Equal(series100[0].CreatedAt))
+		series101, err := subs.GetSubscriptionsForSeries(ctx, 101)
+		require.NoError(t, err)
+		require.Len(t, series101, 1)
+		assert.Equal(t, uint64(1), series101[0].GuildID)
+		latest, err := notis.GetLatest(ctx, 10)
+		require.NoError(t, err)
+		messageIDs := utils.MapSlice(latest, func(n *Notification, _ int) uint64 {
+			assert.Equal(t, NotificationTarget{GuildID: 1}, n.NotificationTarget)
+			return n.DiscordMessageID
+		})
+		assert.ElementsMatch(t, []uint64{7, 6}, messageIDs)
+	})
+}
+
+func TestGuildsRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		guilds := NewGuildsRepo(db)
+		subs := NewSQLSubscriptionsRepo(db)
+		setAt := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		for _, g := range []*Guild{
+			{ID: 1, NotificationsChannelID: 10, NotificationsChannelSetAt: setAt},
+			{ID: 2, NotificationsChannelID: 20, NotificationsChannelSetAt: setAt},
+			{ID: 1, NotificationsChannelID: 11, NotificationsChannelSetAt: setAt.Add(time.Hour)},
+		} {
+			require.NoError(t, guilds.Upsert(ctx, g))
+		}
+		for _, sub := range []*Subscription{
+			{SeriesID: 100, UserID: 10},
+			{SeriesID: 101, UserID: 10},
+			{GuildID: 1, SeriesID: 100, UserID: 10},
+		} {
+			require.NoError(t, subs.Insert(ctx, sub))
+		}
+
+		// Acting
+		found, err := guilds.GetGuildsByIDs(ctx, 1, 2, 3)
+		legacy, legacyErr := guilds.CountLegacySubscriptions(ctx)
+
+		// Asserting
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.Equal(t, uint64(11), found[1].NotificationsChannelID)
+		assert.True(t, setAt.Add(time.Hour).Equal(found[1].NotificationsChannelSetAt))
+		assert.Equal(t, uint64(20), found[2].NotificationsChannelID)
+		require.NoError(t, legacyErr)
+		assert.Equal(t, int64(2), legacy)
+	})
+}
+
+func TestPreferencesRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		prefs := NewPreferencesRepo(db)
+		startedAt := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		sentAt := time.Date(2024, 1, 19, 20, 0, 0, 0, time.UTC)
+		for _, p := range []*UserPreference{
+			{UserID: 10, DeliveryMode: DeliveryModeChannel, ChannelDeliveryStartedAt: startedAt, DMDeliveryStartedAt: startedAt},
+			{UserID: 11, DeliveryMode: DeliveryModeChannel, ChannelDeliveryStartedAt: startedAt, DMDeliveryStartedAt: startedAt},
+			{
+				UserID:                   10,
+				DeliveryMode:             DeliveryModeBoth,
+				ChannelDeliveryStartedAt: startedAt,
+				DMDeliveryStartedAt:      startedAt.Add(time.Hour),
+				ReminderLeadMinutes:      1440,
+				DigestFrequency:          DigestFrequencyWeekly,
+				DigestMinute:             8 * 60,
+				DigestWeekday:            time.Monday,
+				DigestTimezone:           "America/New_York",
+				DigestLastSentAt:         NewNull(sentAt, true),
+			},
+		} {
+			require.NoError(t, prefs.Upsert(ctx, p))
+		}
+
+		// Acting
+		found, err := prefs.GetUserPreferences(ctx, 10, 11, 12)
+		withDigests, digestsErr := prefs.GetUsersWithDigests(ctx)
+
+		// Asserting
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		updated := found[10]
+		assert.Equal(t, DeliveryModeBoth, updated.DeliveryMode)
+		assert.True(t, startedAt.Equal(updated.ChannelDeliveryStartedAt))
+		assert.True(t, startedAt.Add(time.Hour).Equal(updated.DMDeliveryStartedAt))
+		assert.Equal(t, 1440, updated.ReminderLeadMinutes)
+		assert.Equal(t, DigestFrequencyWeekly, updated.DigestFrequency)
+		assert.Equal(t, 8*60, updated.DigestMinute)
+		assert.Equal(t, time.Monday, updated.DigestWeekday)
+		assert.Equal(t, "America/New_York", updated.DigestTimezone)
+		assert.True(t, updated.DigestLastSentAt.Valid)
+		assert.True(t, sentAt.Equal(updated.DigestLastSentAt.V))
+		assert.Equal(t, DeliveryModeChannel, found[11].DeliveryMode)
+		require.NoError(t, digestsErr)
+		assert.Equal(t, []uint64{10}, utils.MapSlice(withDigests, func(p *UserPreference, _ int) uint64 {
+			return p.UserID
+		}))
+	})
+}
+
+func TestRemindersRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		reminders := NewRemindersRepo(db)
+		sentAt := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+		for _, r := range []*Reminder{
+			{SeriesID: 100, Season: 1, Episode: 2, UserID: 10, SentAt: sentAt},
+			{SeriesID: 100, Season: 1, Episode: 2, UserID: 11, SentAt: sentAt},
+			{SeriesID: 100, Season: 1, Episode: 3, UserID: 12, SentAt: sentAt},
+			{SeriesID: 101, Season: 1, Episode: 2, UserID: 13, SentAt: sentAt},
+		} {
+			require.NoError(t, reminders.Insert(ctx, r))
+		}
+
+		// Acting
+		userIDs, err := reminders.GetRemindedUserIDs(ctx, 2, 1, 100)
+		dupErr := reminders.Insert(ctx, &Reminder{SeriesID: 100, Season: 1, Episode: 2, UserID: 10, SentAt: sentAt})
+
+		// Asserting
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []uint64{10, 11}, userIDs)
+		assert.Error(t, dupErr)
+	})
+}
+
+func TestDigestRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		digests := NewDigestRepo(db)
+		notis := NewSQLNotificationsRepo(db)
+		queuedAt := time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)
+		for _, item := range []*DigestItem{
+			{UserID: 10, SeriesID: 101, Season: 1, Episode: 1, EpisodeName: "Pilot", Runtime: 45, AirDate: "2024-01-17"},
+			{UserID: 10, SeriesID: 100, Season: 1, Episode: 2, EpisodeName: "Second", Runtime: 30, AirDate: "2024-01-17"},
+			{UserID: 10, SeriesID: 100, Season: 1, Episode: 1, EpisodeName: "First", Runtime: 30, AirDate: "2024-01-03"},
+			{UserID: 11, SeriesID: 100, Season: 1, Episode: 1, EpisodeName: "First", Runtime: 30, AirDate: "2024-01-03"},
+		} {
+			item.CreatedAt = queuedAt
+			noti := &Notification{
+				Episode:            item.Episode,
+				Season:             item.Season,
+				SeriesID:           item.SeriesID,
+				NotificationTarget: NotificationTarget{UserID: item.UserID},
+				CreatedAt:          queuedAt,
+			}
+			require.NoError(t, digests.InsertWithNotification(ctx, item, noti))
+		}
+		episodes := func(items []*DigestItem, err error) []string {
+			require.NoError(t, err)
+			return utils.MapSlice(items, func(item *DigestItem, _ int) string {
+				return fmt.Sprintf("%d S%dE%d", item.SeriesID, item.Season, item.Episode)
+			})
+		}
+
+		// Acting
+		queued := episodes(digests.GetUserItems(ctx, 10))
+		targets, targetsErr := notis.GetNotifiedTargets(ctx, 1, 1, 100)
+		// Queuing an episode the user was already notified about fails without queuing it again
+		dupErr := digests.InsertWithNotification(ctx,
+			&DigestItem{UserID: 11, SeriesID: 100, Season: 1, Episode: 1, CreatedAt: queuedAt},
+			&Notification{Episode: 1, Season: 1, SeriesID: 100, NotificationTarget: NotificationTarget{UserID: 11}},
+		)
+		items, err := digests.GetUserItems(ctx, 10)
+		require.NoError(t, err)
+		require.NoError(t, digests.DeleteItems(ctx, items[:2]))
+		remaining := episodes(digests.GetUserItems(ctx, 10))
+		other := episodes(digests.GetUserItems(ctx, 11))
+
+		// Asserting
+		assert.Equal(t, []string{"100 S1E1", "100 S1E2", "101 S1E1"}, queued)
+		require.NoError(t, targetsErr)
+		assert.ElementsMatch(t, []NotificationTarget{{UserID: 10}, {UserID: 11}}, targets)
+		assert.Error(t, dupErr)
+		assert.Equal(t, []string{"101 S1E1"}, remaining)
+		assert.Equal(t, []string{"100 S1E1"}, other)
+	})
+}
+
+func TestOutboxRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		outbox := NewOutboxRepo(db)
+		notis := NewSQLNotificationsRepo(db)
+		queuedAt := time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)
+		newItem := func(episode int, target NotificationTarget, createdAt time.Time) *OutboxItem {
+			item := &OutboxItem{
+				Episode:            episode,
+				Season:             1,
+				SeriesID:           100,
+				NotificationTarget: target,
+				Complete:           true,
+				NextAttemptAt:      createdAt,
+				CreatedAt:          createdAt,
+			}
+			item.Embed.V = &discordgo.MessageEmbed{Title: fmt.Sprintf("Episode %d", episode)}
+			item.MentionIDs.V = []uint64{10, 11}
+			return item
+		}
+		channel := newItem(2, NotificationTarget{GuildID: 1}, queuedAt.Add(time.Minute))
+		dm := newItem(2, NotificationTarget{UserID: 10}, queuedAt)
+		later := newItem(3, NotificationTarget{GuildID: 1}, queuedAt.Add(time.Hour))
+		require.NoError(t, outbox.Insert(ctx, channel, dm, later))
+		require.NoError(t, notis.InsertMany(ctx, []*Notification{{
+			Episode:            2,
+			Season:             1,
+			SeriesID:           100,
+			NotificationTarget: NotificationTarget{GuildID: 1},
+			DiscordMessageID:   5,
+			Complete:           false,
+		}}))
+		nextAttemptAt := queuedAt.Add(time.Hour * 2)
+		deadAt := queuedAt.Add(time.Hour * 3)
+
+		// Acting
+		// Items already in the outbox are left as they are
+		require.NoError(t, outbox.Insert(ctx, newItem(2, NotificationTarget{GuildID: 1}, queuedAt.Add(time.Hour*24))))
+		targets, targetsErr := outbox.GetQueuedTargets(ctx, 2, 1, 100)
+		queued, queuedErr := outbox.GetItems(ctx)
+		require.NoError(t, outbox.MarkFailed(ctx, []*OutboxItem{dm}, nextAttemptAt, "timed out"))
+		require.NoError(t, outbox.MarkDead(ctx, []*OutboxItem{later}, deadAt, "missing access"))
+		require.NoError(t, outbox.MarkDelivered(ctx, []*OutboxItem{channel}, []*Notification{{
+			Episode:            2,
+			Season:             1,
+			SeriesID:           100,
+			NotificationTarget: NotificationTarget{GuildID: 1},
+			DiscordChannelID:   1,
+			DiscordMessageID:   6,
+			Complete:           true,
+			CreatedAt:          queuedAt,
+		}}))
+		remaining, err := outbox.GetItems(ctx)
+		require.NoError(t, err)
+		latest, err := notis.GetLatest(ctx, 10)
+		require.NoError(t, err)
+
+		// Asserting
+		require.NoError(t, targetsErr)
+		assert.ElementsMatch(t, []NotificationTarget{{GuildID: 1}, {UserID: 10}}, targets)
+		require.NoError(t, queuedErr)
+		require.Len(t, queued, 3)
+		assert.Equal(t, []NotificationTarget{{UserID: 10}, {GuildID: 1}, {GuildID: 1}},
+			utils.MapSlice(queued, func(item *OutboxItem, _ int) NotificationTarget { return item.NotificationTarget }))
+		assert.Equal(t, "Episode 2", queued[1].Embed.V.Title)
+		assert.Equal(t, []uint64{10, 11}, queued[1].MentionIDs.V)
+		assert.True(t, queued[1].CreatedAt.Equal(channel.CreatedAt))
+		require.Len(t, remaining, 2)
+		failed, dead := remaining[0], remaining[1]
+		assert.Equal(t, 1, failed.Attempts)
+		assert.True(t, nextAttemptAt.Equal(failed.NextAttemptAt))
+		assert.Equal(t, NewNull("timed out", true), failed.LastError)
+		assert.False(t, failed.DeadAt.Valid)
+		assert.Equal(t, 3, dead.Episode)
+		assert.Equal(t, 1, dead.Attempts)
+		assert.True(t, dead.DeadAt.Valid)
+		assert.True(t, deadAt.Equal(dead.DeadAt.V))
+		assert.Equal(t, NewNull("missing access", true), dead.LastError)
+		require.Len(t, latest, 1)
+		assert.Equal(t, uint64(6), latest[0].DiscordMessageID)
+		assert.True(t, latest[0].Complete)
+	})
+}
+
+func TestWatermarksRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		watermarks := NewWatermarksRepo(db)
+		at := time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+
+		// Acting
+		_, missingErr := watermarks.Get(ctx, tvChangesWatermark)
+		require.NoError(t, watermarks.Set(ctx, tvChangesWatermark, at))
+		require.NoError(t, watermarks.Set(ctx, tvChangesWatermark, at.Add(time.Hour)))
+		require.NoError(t, watermarks.Set(ctx, "other", at))
+		moved, err := watermarks.Get(ctx, tvChangesWatermark)
+
+		// Asserting
+		assert.ErrorIs(t, missingErr, sql.ErrNoRows)
+		require.NoError(t, err)
+		assert.True(t, at.Add(time.Hour).Equal(moved))
+	})
+}
+
+func TestJobRunsRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		runs := NewJobRunsRepo(db)
+		startedAt := time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+		for i, run := range []*JobRun{
+			{Job: "find_new_episodes", StartedAt: startedAt, SeriesChecked: 1},
+			{Job: "find_new_episodes", StartedAt: startedAt.Add(time.Hour * 2), SeriesChecked: 3, Error: NewNull("boom", true), Failed: true},
+			{Job: "find_new_episodes", StartedAt: startedAt.Add(time.Hour), SeriesChecked: 2},
+			{Job: "send_reminders", StartedAt: startedAt.Add(time.Hour * 3)},
+		} {
+			run.FinishedAt = run.StartedAt.Add(time.Second)
+			run.DurationMS = int64(i)
+			require.NoError(t, runs.Insert(ctx, run))
+		}
+
+		// Acting
+		latest, err := runs.GetLatest(ctx, "find_new_episodes", 2)
+
+		// Asserting
+		require.NoError(t, err)
+		require.Len(t, latest, 2)
+		assert.Equal(t, 3, latest[0].SeriesChecked)
+		assert.True(t, startedAt.Add(time.Hour*2).Equal(latest[0].StartedAt))
+		assert.True(t, startedAt.Add(time.Hour*2+time.Second).Equal(latest[0].FinishedAt))
+		assert.Equal(t, NewNull("boom", true), latest[0].Error)
+		assert.True(t, latest[0].Failed)
+		assert.Equal(t, 2, latest[1].SeriesChecked)
+		assert.False(t, latest[1].Error.Valid)
+		assert.False(t, latest[1].Failed)
+	})
+}
+
+func TestLeasesRepoQueries(t *testing.T) {
+	runSQLRepoConformance(t, func(t *testing.T, ctx context.Context, db *sqlx.DB) {
+		// Arranging
+		leases := NewLeasesRepo(db)
+		now := time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+		lease := &Lease{Name: "scheduler", Holder: "a", Term: 1, HeartbeatAt: now, ExpiresAt: now.Add(time.Minute)}
+		taken := &Lease{Name: "scheduler", Holder: "b", Term: 2, HeartbeatAt: now, ExpiresAt: now.Add(time.Minute)}
+
+		// Acting
+		_, missingErr := leases.Get(ctx, "scheduler")
+		inserted, insertErr := leases.Insert(ctx, lease)
+		insertedAgain, insertAgainErr := leases.Insert(ctx, taken)
+		staleUpdate, staleErr := leases.Update(ctx, taken, 2)
+		updated, updateErr := leases.Update(ctx, taken, 1)
+		held, heldErr := leases.Get(ctx, "scheduler")
+		require.NoError(t, leases.Delete(ctx, "scheduler", "a"))
+		_, notReleasedErr := leases.Get(ctx, "scheduler")
+		require.NoError(t, leases.Delete(ctx, "scheduler", "b"))
+		_, releasedErr := leases.Get(ctx, "scheduler")
+
+		// Asserting
+		assert.ErrorIs(t, missingErr, sql.ErrNoRows)
+		require.NoError(t, insertErr)
+		assert.True(t, inserted)
+		require.NoError(t, insertAgainErr)
+		assert.False(t, insertedAgain)
+		require.NoError(t, staleErr)
+		assert.False(t, staleUpdate)
+		require.NoError(t, updateErr)
+		assert.True(t, updated)
+		require.NoError(t, heldErr)
+		assert.Equal(t, "b", held.Holder)
+		assert.Equal(t, int64(2), held.Term)
+		assert.True(t, now.Add(time.Minute).Equal(held.ExpiresAt))
+		assert.NoError(t, notReleasedErr)
+		assert.ErrorIs(t, releasedErr, sql.ErrNoRows)
+	})
+}
@@ -1,40 +1,135 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/bwmarrin/discordgo"
 	"github.com/duke605/tv-bot/moviedb"
 	"github.com/duke605/tv-bot/utils"
 	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
 	"github.com/spf13/viper"
 )
 
+// PollSchedule decides when each series is next checked for new episodes. Series are checked on every tick of the
+// interval from shortly before their next episode airs until it's found and are checked less often the longer it
+// is until they have something new
+type PollSchedule struct {
+	// Interval is how often series that are due are checked
+	Interval time.Duration
+	// AirWindow is how long before the next episode airs the series is checked on every tick
+	AirWindow time.Duration
+	// UpcomingInterval is how often series with a date for their next episode are checked in case the date changes
+	UpcomingInterval time.Duration
+	// UnannouncedInterval is how often series without a date for their next episode are checked
+	UnannouncedInterval time.Duration
+	// HiatusAfter is how long after its last episode a returning series without a date for its next episode is
+	// considered on hiatus
+	HiatusAfter time.Duration
+	// HiatusInterval is how often series on hiatus are checked
+	HiatusInterval time.Duration
+}
+
+// defaultPollSchedule is the poll schedule used for the settings that aren't configured
+var defaultPollSchedule = PollSchedule{
+	Interval:            time.Minute * 10,
+	AirWindow:           time.Hour,
+	UpcomingInterval:    time.Hour * 24,
+	UnannouncedInterval: time.Hour * 24 * 7,
+	HiatusAfter:         time.Hour * 24 * 90,
+	HiatusInterval:      time.Hour * 24 * 30,
+}
+
+// NextCheck returns when the series should next be checked for new episodes. The interval set on the series by an
+// admin is used over the schedule
+func (ps PollSchedule) NextCheck(s *Series) time.Time {
+	if interval, ok := s.CheckInterval(); ok {
+		return s.LastFetchedAt.Add(interval)
+	}
+
+	if s.NextEpisodeAirDate.Valid {
+		next := s.NextEpisodeAirDate.V.Add(-ps.AirWindow)
+		if capped := s.LastFetchedAt.Add(ps.UpcomingInterval); capped.Before(next) {
+			return capped
+		}
+		return next
+	}
+
+	// Backing off for returning series that haven't aired in a while since they're usually between seasons and
+	// the next season isn't announced for months
+	if s.Data.V != nil && s.Data.V.Status == "Returning Series" && s.Data.V.LastEpisodeToAir != nil {
+		lastAired, err := time.ParseInLocation(time.DateOnly, s.Data.V.LastEpisodeToAir.AirDate, time.Local)
+		if err == nil && s.LastFetchedAt.Sub(lastAired) > ps.HiatusAfter {
+			return s.LastFetchedAt.Add(ps.HiatusInterval)
+		}
+	}
+
+	return s.LastFetchedAt.Add(ps.UnannouncedInterval)
+}
+
 type SeriesService struct {
-	notiRepo      *NotificationsRepo
+	notiRepo      NotificationsRepo
 	subsSrv       *SubscriptionsService
-	seriesRepo    *SeriesRepo
-	discord       *discordgo.Session
+	prefsSrv      *PreferencesService
+	guildsSrv     *GuildsService
+	digestSrv     *DigestService
+	outboxSrv     *OutboxService
+	seriesRepo    SeriesRepo
+	watermarkRepo *WatermarksRepo
+	discord       utils.DiscordSender
 	movieDBClient moviedb.Client
+	clock         clock.Clock
+	scanWorkers   int
+	schedule      PollSchedule
 
 	searchCache *expirable.LRU[string, []utils.Tuple[string, uint64]]
 }
 
-func NewSeriesService(nr *NotificationsRepo, ss *SubscriptionsService, d *discordgo.Session, mdbc moviedb.Client, sr *SeriesRepo) *SeriesService {
+func NewSeriesService(
+	nr NotificationsRepo,
+	ss *SubscriptionsService,
+	ps *PreferencesService,
+	gs *GuildsService,
+	ds *DigestService,
+	obs *OutboxService,
+	d utils.DiscordSender,
+	mdbc moviedb.Client,
+	sr SeriesRepo,
+	wr *WatermarksRepo,
+	scanWorkers int,
+	schedule PollSchedule,
+	c clock.Clock,
+) *SeriesService {
 	return &SeriesService{
 		notiRepo:      nr,
 		subsSrv:       ss,
+		prefsSrv:      ps,
+		guildsSrv:     gs,
+		digestSrv:     ds,
+		outboxSrv:     obs,
 		discord:       d,
 		seriesRepo:    sr,
+		watermarkRepo: wr,
 		movieDBClient: mdbc,
+		clock:         c,
+		scanWorkers:   scanWorkers,
+		schedule:      schedule,
 		searchCache:   expirable.NewLRU[string, []utils.Tuple[string, uint64]](100, nil, time.Minute*10),
 	}
 }
@@ -43,7 +138,7 @@ func NewSeriesService(nr *NotificationsRepo, ss *SubscriptionsService, d *discor
 func (srv *SeriesService) CacheSeries(ctx context.Context, s *moviedb.SeriesDetails) error {
 	seriesModel := &Series{}
 	seriesModel.ID = s.ID
-	seriesModel.LastFetchedAt = time.Now()
+	seriesModel.LastFetchedAt = srv.clock.Now()
 	seriesModel.Data.V = s
 	if s.NextEpisodeToAir != nil {
 		t, err := time.ParseInLocation(time.DateOnly, s.NextEpisodeToAir.AirDate, time.Local)
@@ -55,9 +150,41 @@ func (srv *SeriesService) CacheSeries(ctx context.Context, s *moviedb.SeriesDeta
 		seriesModel.NextEpisodeAirDate.Valid = true
 	}
 
+	// Scheduling the next check with the interval an admin set on the series if there is one
+	existing, err := srv.seriesRepo.GetSeriesByID(ctx, s.ID)
+	if err == nil {
+		seriesModel.CheckIntervalMS = existing.CheckIntervalMS
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	seriesModel.NextCheckAt = NewNull(srv.schedule.NextCheck(seriesModel), true)
+
 	return srv.seriesRepo.Upsert(ctx, seriesModel)
 }
 
+// SetCheckInterval makes the series get checked for new episodes every interval instead of on the poll schedule.
+// A zero interval puts the series back on the poll schedule
+func (srv *SeriesService) SetCheckInterval(ctx context.Context, seriesID uint64, interval time.Duration) (*moviedb.SeriesDetails, error) {
+	// Making sure the series is cached so the interval has a row to live on
+	series, seriesModel, err := srv.GetSeriesDetails(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	if seriesModel == nil {
+		if seriesModel, err = srv.seriesRepo.GetSeriesByID(ctx, seriesID); err != nil {
+			return nil, err
+		}
+	}
+
+	seriesModel.CheckIntervalMS = NewNull(interval.Milliseconds(), interval > 0)
+	seriesModel.NextCheckAt = NewNull(srv.schedule.NextCheck(seriesModel), true)
+	if err := srv.seriesRepo.SetCheckInterval(ctx, seriesID, seriesModel.CheckIntervalMS, seriesModel.NextCheckAt); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
 // SearchSeries searches for series that partially or fully match the provided name and returns an array of tuples containing
 // the name of the series and its id
 func (srv *SeriesService) SearchSeries(ctx context.Context, name string) ([]utils.Tuple[string, uint64], error) {
@@ -96,83 +223,284 @@ func (srv *SeriesService) SearchSeries(ctx context.Context, name string) ([]util
 	return ret, nil
 }
 
-// FindNewEpisodes finds new episodes for all the series subscribed to in the database
-// and notifies all subscribers about them in the discord
-func (srv *SeriesService) FindNewEpisodes(ctx context.Context) error {
+// ScanSummary counts what happened to the series during a scan for new episodes. Checked series were looked up
+// on TMDB and Notified is how many of those had new episodes to deliver. Episodes is the number of new episodes
+type ScanSummary struct {
+	Checked  int
+	Skipped  int
+	Notified int
+	Failed   int
+	Episodes int
+}
+
+func (s ScanSummary) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("checked", s.Checked),
+		slog.Int("skipped", s.Skipped),
+		slog.Int("notified", s.Notified),
+		slog.Int("failed", s.Failed),
+		slog.Int("episodes", s.Episodes),
+	)
+}
+
+// seriesScan is what happened to a single series during a scan for new episodes. Finished is set to the details of
+// the series if it has ended or been cancelled
+type seriesScan struct {
+	skipped  bool
+	episodes int
+	finished *moviedb.SeriesDetails
+	// removed is true if the series was deleted from TMDB
+	removed bool
+}
+
+// ErrScanIncomplete is wrapped by the error returned when a scan for new episodes finished but some series
+// couldn't be checked
+var ErrScanIncomplete = errors.New("scan finished with errors")
+
+// FindNewEpisodes finds new episodes for all the series subscribed to in the database. The series are checked by
+// a pool of workers that share the TMDB rate limit. A series that fails to be checked doesn't stop the others
+// from being checked, the errors of every series that failed are returned together wrapping ErrScanIncomplete
+// once the scan is done
+func (srv *SeriesService) FindNewEpisodes(ctx context.Context) (ScanSummary, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	seriesPager := srv.subsSrv.GetDistinctSeriesIDsWithEpoch(ctx)
-	now := time.Now()
-	var subscribersIDs []uint64
-	outstanding := utils.NewDualBatcher(10, func(embeds []*discordgo.MessageEmbed, notifications []*Notification) error {
-		return srv.sendEmbedsAndMakeNotifications(ctx, notifications, embeds, subscribersIDs)
-	})
+	now := srv.clock.Now()
+	summary := ScanSummary{}
 	finishedSeries := []*moviedb.SeriesDetails{}
+	removedSeriesIDs := []uint64{}
+	errs := []error{}
 
-	for {
+	// Only series that changed on TMDB since the last check need to be fetched again. Series are fetched on
+	// their schedule if the changes since then can't be listed
+	changed, err := srv.getChangedSeriesIDs(ctx, now)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get changed series, checking every series", "error", err)
+	}
+
+	mu := sync.Mutex{}
+	var fatalErr error
+	rows := make(chan utils.Tuple[uint64, time.Time])
+	wg := sync.WaitGroup{}
+	for range max(srv.scanWorkers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for row := range rows {
+				scan, err := srv.findNewEpisodesForSeries(ctx, row.T, row.V, now, changed)
+
+				mu.Lock()
+				switch {
+				case errors.Is(err, moviedb.ErrUnauthorized):
+					// Every other series would fail the same way so the scan is stopped
+					if fatalErr == nil {
+						fatalErr = err
+					}
+					cancel()
+				case err != nil:
+					summary.Failed++
+					errs = append(errs, fmt.Errorf("series %d: %w", row.T, err))
+				case scan.skipped:
+					summary.Skipped++
+				default:
+					summary.Checked++
+					summary.Episodes += scan.episodes
+					if scan.episodes > 0 {
+						summary.Notified++
+					}
+				}
+				if err == nil && scan.finished != nil {
+					finishedSeries = append(finishedSeries, scan.finished)
+				}
+				if err == nil && scan.removed {
+					removedSeriesIDs = append(removedSeriesIDs, row.T)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Paging here instead of in the workers since the pager can't be shared between goroutines
+	seriesPager := srv.subsSrv.GetDistinctSeriesIDsWithEpoch(ctx)
+	var pageErr error
+	for ctx.Err() == nil {
 		row, more, err := seriesPager.Next()
 		if err != nil {
-			return err
+			pageErr = err
+			break
 		} else if !more {
 			break
 		}
-		seriesID, epoch := row.T, row.V
-		logger := slog.With("series_id", seriesID)
-		logger.DebugContext(ctx, "Looking for new episodes for series")
 
-		// Getting subscribers to the series and skipping if none
-		subscribersIDs, err = srv.subsSrv.GetAllSubscribedToSeries(ctx, seriesID)
-		if err != nil {
-			return err
+		select {
+		case rows <- row:
+		case <-ctx.Done():
 		}
-		if len(subscribersIDs) == 0 {
-			logger.WarnContext(ctx, "No subscribers for series")
-			continue
+	}
+	close(rows)
+	wg.Wait()
+
+	if fatalErr != nil {
+		return summary, fatalErr
+	} else if pageErr != nil {
+		return summary, pageErr
+	} else if err = ctx.Err(); err != nil {
+		return summary, err
+	}
+
+	// Unsubscribing from series deleted from TMDB once paging is done since deleting subscriptions while paging
+	// would shift the pages and skip series
+	if len(removedSeriesIDs) > 0 {
+		if err = srv.subsSrv.DeleteSubscriptionsForSeries(ctx, removedSeriesIDs...); err != nil {
+			slog.ErrorContext(ctx, "Failed unsubscribing subscribers from deleted series", "series", removedSeriesIDs, "error", err)
+			errs = append(errs, err)
 		}
+	}
 
-		series, seriesModel, err := srv.GetSeriesDetails(ctx, seriesID)
-		if err != nil {
-			logger.ErrorContext(ctx, "Failed to get series", "cached", seriesModel != nil, "error", err)
-		} else if seriesModel != nil {
-			if srv.canSkipCheckForNewEpisodes(ctx, seriesModel, epoch) {
-				logger.DebugContext(ctx, "Skipping series look up", "series_name", series.Name)
-				continue
-			}
+	// Sorting so the finished series are announced in the same order no matter which worker checked them
+	slices.SortFunc(finishedSeries, func(a, b *moviedb.SeriesDetails) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if err = srv.sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(ctx, finishedSeries); err != nil {
+		errs = append(errs, err)
+	}
+	slog.InfoContext(ctx, "Finished scan for new episodes", "summary", summary)
 
-			// Getting series from TMDB instead of using cache
-			series = &moviedb.SeriesDetails{}
-			_, err = srv.movieDBClient.GetTVSeriesDetails(seriesID, series,
-				moviedb.RequestOptionWithQueryParams("language", "en-US"),
-				moviedb.RequestOptionWithContext(ctx),
-			)
-			if err != nil {
-				return err
-			}
+	// Leaving the watermark where it is if a series failed so its changes are picked up on the next check
+	if len(errs) == 0 {
+		errs = append(errs, srv.watermarkRepo.Set(ctx, tvChangesWatermark, now))
+	}
+	if err = errors.Join(errs...); err != nil {
+		return summary, fmt.Errorf("%w: %w", ErrScanIncomplete, err)
+	}
+
+	return summary, nil
+}
+
+// findNewEpisodesForSeries queues the episodes of the series that aired since the epoch for everyone that hasn't
+// been notified about them yet
+func (srv *SeriesService) findNewEpisodesForSeries(
+	ctx context.Context,
+	seriesID uint64,
+	epoch, now time.Time,
+	changed *seriesChanges,
+) (seriesScan, error) {
+	logger := slog.With("series_id", seriesID)
+	logger.DebugContext(ctx, "Looking for new episodes for series")
+
+	// Getting everyone that should be notified about the series and skipping if nobody
+	audience, err := srv.getSeriesAudience(ctx, seriesID)
+	if err != nil {
+		return seriesScan{}, err
+	}
+	targets := audience.Targets()
+	if len(targets) == 0 {
+		logger.WarnContext(ctx, "No one to notify for series")
+		return seriesScan{skipped: true}, nil
+	}
+	queued := []*OutboxItem{}
+	scan := seriesScan{}
 
-			if err = srv.CacheSeries(ctx, series); err != nil {
-				logger.ErrorContext(ctx, "Failed to cache series", "error", err)
+	// Getting the notifications that were delivered before the episode's information was filled in
+	// so they can be edited once it is
+	incomplete, err := srv.notiRepo.GetIncompleteForSeries(ctx, seriesID)
+	if err != nil {
+		return scan, err
+	}
+	incompleteByEpisode := map[episodeKey][]*Notification{}
+	for _, n := range incomplete {
+		key := episodeKey{n.Season, n.Episode}
+		incompleteByEpisode[key] = append(incompleteByEpisode[key], n)
+	}
+
+	// Only checking the seasons that could have had episodes added since the series was last fetched. Series
+	// that were never fetched are checked back to when they were first subscribed to
+	from := epoch
+	includeSpecials := false
+	seasonsToCheck := func(series *moviedb.SeriesDetails) []int {
+		seasons := srv.seasonsAiredBetween(series, from, now, includeSpecials)
+		for key := range incompleteByEpisode {
+			if !slices.Contains(seasons, key.Season) {
+				seasons = append(seasons, key.Season)
 			}
 		}
+		slices.Sort(seasons)
 
-		// Adding the series to the finished slice to inform subscribers that a series
-		// they subscribe to has ended or been cancelled
-		if series.Status == "Ended" || series.Status == "Canceled" || series.Status == "Cancelled" {
-			finishedSeries = append(finishedSeries, series)
+		return seasons
+	}
+
+	appendedSeasons := map[int]*moviedb.SeasonDetails{}
+	series, seriesModel, err := srv.GetSeriesDetails(ctx, seriesID)
+	if err == nil && seriesModel != nil {
+		if len(incomplete) == 0 && srv.canSkipCheckForNewEpisodes(ctx, seriesModel, epoch, audience, changed) {
+			logger.DebugContext(ctx, "Skipping series look up", "series_name", series.Name)
+			// Subscribers of a finished series are still subscribed if they couldn't be told it finished
+			if seriesIsFinished(series) {
+				return seriesScan{skipped: true, finished: series}, nil
+			}
+			return seriesScan{skipped: true}, nil
+		}
+		if fetched := seriesModel.LastFetchedAt.Add(-seasonCheckSlack); fetched.After(from) {
+			from = fetched
 		}
+		includeSpecials = seriesModel.IncludeSpecials
 
+		// Getting series from TMDB instead of using cache along with the seasons the cache says will need
+		// to be checked so they don't need to be requested separately
+		series, appendedSeasons, err = srv.refreshSeriesDetailsWithSeasons(ctx, seriesID, seasonsToCheck(series)...)
+	}
+	if errors.Is(err, moviedb.ErrNotFound) {
+		// The series was deleted from TMDB so there will never be new episodes to notify about
+		logger.WarnContext(ctx, "Series no longer exists, unsubscribing subscribers", "error", err)
+		return seriesScan{removed: true}, nil
+	} else if errors.Is(err, moviedb.ErrUnauthorized) {
+		return scan, err
+	} else if err != nil {
+		// Skipping the series until the next check since TMDB is likely having issues
+		logger.ErrorContext(ctx, "Failed to get series", "transient", moviedb.IsTransient(err), "error", err)
+		return scan, err
+	}
+
+	// Returning the series once it's checked to inform subscribers that a series they subscribe to has
+	// ended or been cancelled
+	if seriesIsFinished(series) {
+		scan.finished = series
+	}
+
+	var seasonErr error
+	for _, seasonNumber := range seasonsToCheck(series) {
 		season := moviedb.SeasonDetails{}
-		_, err = srv.movieDBClient.GetTVSeasonDetails(series.ID, series.NumberOfSeasons, &season,
-			moviedb.RequestOptionWithQueryParams("language", "en-US"),
-			moviedb.RequestOptionWithContext(ctx),
-		)
-		if err != nil {
-			return err
+		var err error
+		if appended := appendedSeasons[seasonNumber]; appended != nil {
+			season = *appended
+		} else {
+			_, err = srv.movieDBClient.GetTVSeasonDetails(series.ID, seasonNumber, &season,
+				moviedb.RequestOptionWithQueryParams("language", "en-US"),
+				moviedb.RequestOptionWithContext(ctx),
+			)
+		}
+		if errors.Is(err, moviedb.ErrNotFound) {
+			logger.WarnContext(ctx, "Season no longer exists", "season_number", seasonNumber)
+			continue
+		} else if errors.Is(err, moviedb.ErrUnauthorized) {
+			return scan, err
+		} else if err != nil {
+			// Queuing what was found so far and trying the remaining seasons on the next check
+			logger.ErrorContext(ctx, "Failed to get season", "season_number", seasonNumber, "transient", moviedb.IsTransient(err), "error", err)
+			seasonErr = err
+			break
 		}
-		logger = logger.With("season_number", season.SeasonNumber)
+		logger := logger.With("season_number", season.SeasonNumber)
 
 		// Making a list of episodes that we haven't notified discord about
 		for _, episode := range season.Episodes {
+			if notis := incompleteByEpisode[episodeKey{season.SeasonNumber, episode.EpisodeNumber}]; len(notis) > 0 {
+				if err := srv.updateIncompleteNotifications(ctx, series, &season, &episode, audience, notis); err != nil {
+					logger.ErrorContext(ctx, "Failed to update incomplete notifications", "episode_id", episode.EpisodeNumber, "error", err)
+				}
+			}
+
 			// Checking if the episode has aired yet or the episode aired before we started listening
 			if episode.AirDate == "" {
 				continue
@@ -182,52 +510,134 @@ func (srv *SeriesService) FindNewEpisodes(ctx context.Context) error {
 				continue
 			}
 
-			// Not showing notification if it's missing a description, runtime, or still path unless the time is past 8:00PM
-			if (episode.Overview == "" || episode.StillPath == "" || episode.Runtime == 0) && time.Since(releaseDate) < time.Hour*48 {
-				slog.DebugContext(ctx, "Found new episode but has missing information. Delaying notification...",
-					slog.String("series", series.Name),
-					slog.Uint64("series_id", series.ID),
-					slog.Int("episode", episode.EpisodeNumber),
-					slog.Int("season", episode.SeasonNumber),
-					slog.Bool("missing_overview", episode.Overview == ""),
-					slog.Bool("missing_runtime", episode.Runtime == 0),
-					slog.Bool("missing_still_path", episode.StillPath == ""),
-					slog.String("since_air_date", time.Since(releaseDate).String()),
-				)
-				continue
-			}
-			fmt.Println(time.Since(releaseDate))
-			fmt.Println(releaseDate)
-
-			// Checking if we've already notified discord about this episode
-			exists, err := srv.notiRepo.ExistsForEpisodeSeasonAndSeries(ctx, episode.EpisodeNumber, season.SeasonNumber, series.ID)
+			// Checking who we've already notified about this episode
+			notified, err := srv.getNotifiedOrQueuedTargets(ctx, episode.EpisodeNumber, season.SeasonNumber, series.ID)
 			if err != nil {
-				return err
-			} else if exists {
+				return scan, err
+			}
+			pending := audience.PendingTargets(releaseDate, notified)
+			if len(pending) == 0 {
 				continue
 			}
+			scan.episodes++
 
-			embed := srv.makeEmbedForEpisode(series, &season, &episode, subscribersIDs)
-			noti := &Notification{
-				Episode:  episode.EpisodeNumber,
-				Season:   episode.SeasonNumber,
-				SeriesID: series.ID,
-			}
 			logger.InfoContext(ctx, "New episode found",
 				"episode_id", episode.EpisodeNumber,
 				"episode_type", episode.EpisodeType,
+				"targets", len(pending),
 			)
-			if err := outstanding.Add(embed, noti); err != nil {
-				return err
+			// Episodes missing information are still sent and the message is edited once the information is filled in
+			complete := episodeIsComplete(&episode)
+			if !complete {
+				logger.DebugContext(ctx, "New episode is missing information",
+					"episode_id", episode.EpisodeNumber,
+					"missing_overview", episode.Overview == "",
+					"missing_runtime", episode.Runtime == 0,
+					"missing_still_path", episode.StillPath == "",
+				)
+			}
+			for _, target := range pending {
+				noti := &Notification{
+					Episode:            episode.EpisodeNumber,
+					Season:             episode.SeasonNumber,
+					SeriesID:           series.ID,
+					NotificationTarget: target,
+					Complete:           complete,
+					CreatedAt:          now,
+				}
+				if audience.InDigest(target) {
+					// Digests aren't edited once they're sent
+					noti.Complete = true
+					if err := srv.digestSrv.Queue(ctx, series, &episode, noti); err != nil {
+						logger.ErrorContext(ctx, "Failed to queue episode for digest", "user_id", target.UserID, "error", err)
+					}
+					continue
+				}
+
+				item := &OutboxItem{
+					Episode:            noti.Episode,
+					Season:             noti.Season,
+					SeriesID:           noti.SeriesID,
+					NotificationTarget: target,
+					Complete:           complete,
+				}
+				item.Embed.V = srv.makeEmbedForEpisode(series, &season, &episode, audience.WatcherIDs(target))
+				item.MentionIDs.V = audience.MentionIDs(target)
+				queued = append(queued, item)
 			}
 		}
+	}
 
-		if err = outstanding.Flush(); err != nil {
-			return err
+	// The outbox delivers the episodes in the background and records the notifications once they're delivered
+	if err = srv.outboxSrv.Enqueue(ctx, queued...); err != nil {
+		return scan, err
+	}
+
+	return scan, seasonErr
+}
+
+// seasonCheckSlack is how long before a series was last fetched its seasons are still checked so episodes that
+// were added to TMDB late or missed by a failed check are still found
+const seasonCheckSlack = time.Hour * 24 * 7
+
+// tvChangesWatermark is the name of the watermark for when the TMDB series changes were last checked
+const tvChangesWatermark = "tv_changes"
+
+// seriesChanges are the series that changed on TMDB between since and the start of the scan
+type seriesChanges struct {
+	since time.Time
+	ids   map[uint64]bool
+}
+
+// getChangedSeriesIDs returns the series that changed on TMDB between the last check and now. Nil is returned
+// if there hasn't been a check yet or it was too long ago for TMDB to list the changes since then
+func (srv *SeriesService) getChangedSeriesIDs(ctx context.Context, now time.Time) (*seriesChanges, error) {
+	since, err := srv.watermarkRepo.Get(ctx, tvChangesWatermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if now.Sub(since) > time.Hour*24*14 {
+		return nil, nil
+	}
+
+	totalPages := 0
+	pager := utils.NewPager(func(page int, buf []uint64) ([]uint64, error) {
+		buf = buf[:0]
+		if page > 0 && page >= totalPages {
+			return buf, nil
+		}
+
+		results := moviedb.SearchResults[*moviedb.ChangedItem]{}
+		_, err := srv.movieDBClient.GetTVChanges(page+1, &results,
+			moviedb.RequestOptionWithDateRange(since, now),
+			moviedb.RequestOptionWithContext(ctx),
+		)
+		if err != nil {
+			return nil, err
+		}
+		totalPages = results.TotalPages
+
+		for _, item := range results.Results {
+			buf = append(buf, item.ID)
+		}
+
+		return buf, nil
+	})
+
+	changed := &seriesChanges{since: since, ids: map[uint64]bool{}}
+	for {
+		id, more, err := pager.Next()
+		if err != nil {
+			return nil, err
+		} else if !more {
+			break
 		}
+
+		changed.ids[id] = true
 	}
 
-	return srv.sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(ctx, finishedSeries)
+	return changed, nil
 }
 
 // GetSeriesDetails gets details about a series. Function will attempt to look for the details in the cache
@@ -241,154 +651,500 @@ func (srv *SeriesService) GetSeriesDetails(ctx context.Context, seriesID uint64)
 		slog.ErrorContext(ctx, "Failed to get series from database", "error", err)
 	}
 
-	series := new(moviedb.SeriesDetails)
-	_, err = srv.movieDBClient.GetTVSeriesDetails(seriesID, series,
-		moviedb.RequestOptionWithContext(ctx),
-		moviedb.RequestOptionWithQueryParams("language", "en-US"),
-	)
+	series, err := srv.RefreshSeriesDetails(ctx, seriesID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if err = srv.CacheSeries(ctx, series); err != nil {
-		slog.ErrorContext(ctx, "Failed to cache series", "series", series, "error", err)
-	}
-
 	return series, nil, nil
 }
 
-func (srv *SeriesService) canSkipCheckForNewEpisodes(ctx context.Context, seriesModel *Series, epoch time.Time) bool {
-	day := time.Hour * 24
-	nextReleaseDate := seriesModel.NextEpisodeAirDate.V
-	lastEpisode := seriesModel.Data.V.LastEpisodeToAir
+// RefreshSeriesDetails gets details about a series from TMDB, skipping the cache, and caches them
+func (srv *SeriesService) RefreshSeriesDetails(ctx context.Context, seriesID uint64) (*moviedb.SeriesDetails, error) {
+	series, _, err := srv.refreshSeriesDetailsWithSeasons(ctx, seriesID)
+	return series, err
+}
 
-	// Checking to see if we've made a notification for the last released episode yet (If we should've)
-	if lastEpisode != nil {
-		t, _ := time.Parse(time.DateOnly, lastEpisode.AirDate)
-		if !t.Before(epoch) {
-			en := lastEpisode.EpisodeNumber
-			sn := lastEpisode.SeasonNumber
-			f, err := srv.notiRepo.ExistsForEpisodeSeasonAndSeries(ctx, en, sn, seriesModel.ID)
-			if err == nil && !f {
-				return false
-			}
-		}
+// refreshSeriesDetailsWithSeasons does the same as RefreshSeriesDetails but also gets the details of the seasons
+// provided in the same request. The seasons are keyed by season number and only the first 20 seasons are fetched
+// as that's the most TMDB allows in one request
+func (srv *SeriesService) refreshSeriesDetailsWithSeasons(
+	ctx context.Context,
+	seriesID uint64,
+	seasons ...int,
+) (*moviedb.SeriesDetails, map[int]*moviedb.SeasonDetails, error) {
+	opts := []moviedb.RequestOption{
+		moviedb.RequestOptionWithContext(ctx),
+		moviedb.RequestOptionWithQueryParams("language", "en-US"),
+	}
+	if len(seasons) > 0 {
+		appends := utils.MapSlice(seasons[:min(len(seasons), 20)], func(season int, _ int) moviedb.Append {
+			return moviedb.AppendSeason(season)
+		})
+		opts = append(opts, moviedb.RequestOptionAppendToResponse(appends...))
 	}
 
-	// Allowing skip if there is no next episode date set yet and it has been less than
-	// a week since the last fetch. The next episode date not being set *usually* only
-	// happens when the next season is still in the works, at which point the next episode
-	// day is set much before the next episode actually airs
-	if !seriesModel.NextEpisodeAirDate.Valid && time.Since(seriesModel.LastFetchedAt) < day*7 {
-		return true
+	series := new(moviedb.SeriesDetailsWithAppends)
+	if _, err := srv.movieDBClient.GetTVSeriesDetailsWithAppends(seriesID, series, opts...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := srv.CacheSeries(ctx, &series.SeriesDetails); err != nil {
+		slog.ErrorContext(ctx, "Failed to cache series", "series_id", seriesID, "error", err)
 	}
 
-	return seriesModel.NextEpisodeAirDate.Valid && time.Until(nextReleaseDate) > time.Hour
+	return &series.SeriesDetails, series.AppendedSeasons, nil
 }
 
-func (srv *SeriesService) sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(
+// UpcomingEpisode is the next episode of a series along with when it airs
+type UpcomingEpisode struct {
+	Series  *moviedb.SeriesDetails
+	Episode *moviedb.PartialEpisodeDetails
+	AirsAt  time.Time
+}
+
+// GetUpcomingEpisodes gets the next episode of every series the user is subscribed to in the guild that airs
+// within the number of days provided, counting today as the first, sorted by when they air. Series that don't
+// have a date announced for their next episode are returned separately. Cached series that may be out of date
+// are refreshed first and used as they are if refreshing fails. Series that aren't cached and can't be fetched
+// are left out
+func (srv *SeriesService) GetUpcomingEpisodes(
 	ctx context.Context,
-	series []*moviedb.SeriesDetails,
-) error {
-	if len(series) == 0 {
-		return nil
+	guildID, userID uint64,
+	days int,
+) ([]*UpcomingEpisode, []*moviedb.SeriesDetails, error) {
+	subs, err := srv.subsSrv.GetUserSubscriptions(ctx, guildID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cached, err := srv.seriesRepo.GetSeriesSubscribedToByUser(ctx, guildID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cachedByID := make(map[uint64]*Series, len(cached))
+	for _, s := range cached {
+		cachedByID[s.ID] = s
 	}
 
-	seriesIDs := make([]uint64, 0, len(series))
-	cancelled := ""
-	ended := ""
-
-	for _, s := range series {
-		seriesIDs = append(seriesIDs, s.ID)
-		if s.Status == "Ended" {
-			ended += "\n- " + s.Name
+	now := srv.clock.Now()
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	until := today.AddDate(0, 0, days)
+
+	upcoming := []*UpcomingEpisode{}
+	unannounced := []*moviedb.SeriesDetails{}
+	for _, sub := range subs {
+		var series *moviedb.SeriesDetails
+		model := cachedByID[sub.SeriesID]
+
+		// Refreshing series that haven't been fetched in a day or whose next episode has already aired
+		if model == nil {
+			if series, _, err = srv.GetSeriesDetails(ctx, sub.SeriesID); err != nil {
+				slog.WarnContext(ctx, "Failed to get subscribed series", "series_id", sub.SeriesID, "error", err)
+				continue
+			}
+		} else if now.Sub(model.LastFetchedAt) > time.Hour*24 || (model.NextEpisodeAirDate.Valid && model.NextEpisodeAirDate.V.Before(today)) {
+			if series, err = srv.RefreshSeriesDetails(ctx, sub.SeriesID); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh series, using cached details", "series_id", sub.SeriesID, "error", err)
+				series = model.Data.V
+			}
 		} else {
-			cancelled += "\n- " + s.Name
+			series = model.Data.V
 		}
-	}
-	cancelled = strings.Trim(cancelled, "\n")
-	ended = strings.Trim(ended, "\n")
 
-	fields := make([]*discordgo.MessageEmbedField, 0, 2)
-	if cancelled != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "Cancelled",
-			Inline: true,
-			Value:  strings.Trim(cancelled, "\n"),
-		})
-	}
-	if ended != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "Ended",
-			Inline: true,
-			Value:  strings.Trim(ended, "\n"),
-		})
+		episode := series.NextEpisodeToAir
+		if episode == nil {
+			unannounced = append(unannounced, series)
+			continue
+		}
+
+		// The cached next episode could have already aired if the series couldn't be refreshed, leaving the
+		// series without a known next episode
+		airsAt, err := time.ParseInLocation(time.DateOnly, episode.AirDate, time.Local)
+		if err != nil || airsAt.Before(today) {
+			unannounced = append(unannounced, series)
+			continue
+		}
+		if airsAt.Before(until) {
+			upcoming = append(upcoming, &UpcomingEpisode{Series: series, Episode: episode, AirsAt: airsAt})
+		}
 	}
 
-	embed := &discordgo.MessageEmbed{
-		Title:       "Series cancelled or ended",
-		Description: "Unfortunately the following series have either ended or been cancelled :pensive:",
-		Fields:      fields,
+	slices.SortFunc(upcoming, func(a, b *UpcomingEpisode) int {
+		if c := a.AirsAt.Compare(b.AirsAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Series.Name, b.Series.Name)
+	})
+	slices.SortFunc(unannounced, func(a, b *moviedb.SeriesDetails) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return upcoming, unannounced, nil
+}
+
+// GetUserSubscribedSeries gets details about all the series the user is subscribed to in the guild from the cache.
+// TMDB isn't asked about series that aren't cached so they're given a placeholder name with only their ID
+func (srv *SeriesService) GetUserSubscribedSeries(ctx context.Context, guildID, userID uint64) ([]*moviedb.SeriesDetails, error) {
+	subs, err := srv.subsSrv.GetUserSubscriptions(ctx, guildID, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	subscriberIDs, err := srv.subsSrv.GetAllSubscribedToSeries(ctx, seriesIDs...)
+	cached, err := srv.seriesRepo.GetSeriesSubscribedToByUser(ctx, guildID, userID)
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed getting all subscribers for series", "series", seriesIDs)
-		return err
+		return nil, err
+	}
+	cachedByID := make(map[uint64]*moviedb.SeriesDetails, len(cached))
+	for _, s := range cached {
+		cachedByID[s.ID] = s.Data.V
+	}
+
+	ret := make([]*moviedb.SeriesDetails, 0, len(subs))
+	for _, sub := range subs {
+		series, ok := cachedByID[sub.SeriesID]
+		if !ok {
+			slog.WarnContext(ctx, "Subscribed series is not cached", "series_id", sub.SeriesID)
+			series = &moviedb.SeriesDetails{ID: sub.SeriesID, Name: fmt.Sprintf("Series %d", sub.SeriesID)}
+		}
+
+		ret = append(ret, series)
 	}
 
-	channelID := viper.GetString("discord.notifications_channel_id")
-	srv.discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-		Embed: embed,
-		Content: utils.Reduce(subscriberIDs, func(a string, e uint64) string {
-			return a + "\n" + fmt.Sprintf("<@%d>", e)
-		}, ""),
+	return ret, nil
+}
+
+// seasonsAiredBetween returns the numbers of the seasons of the series that could have episodes that aired between
+// from and to. A season is considered to be airing from its air date until the air date of the season after it.
+// The specials season (season 0) is only included if includeSpecials is true as specials air sporadically
+func (SeriesService) seasonsAiredBetween(series *moviedb.SeriesDetails, from, to time.Time, includeSpecials bool) []int {
+	seasons := slices.Clone(series.Seasons)
+	slices.SortFunc(seasons, func(a, b moviedb.PartialSeasonDetails) int {
+		return a.SeasonNumber - b.SeasonNumber
 	})
 
-	if err = srv.subsSrv.DeleteSubscriptionsForSeries(ctx, seriesIDs...); err != nil {
-		slog.ErrorContext(ctx, "Failed unsubscribing subscribers from series", "series", seriesIDs)
-		return err
+	ret := []int{}
+	for i, season := range seasons {
+		if season.SeasonNumber == 0 {
+			if includeSpecials {
+				ret = append(ret, season.SeasonNumber)
+			}
+			continue
+		}
+
+		// Seasons without an air date haven't started airing yet
+		start, err := time.ParseInLocation(time.DateOnly, season.AirDate, time.Local)
+		if err != nil || start.After(to) {
+			continue
+		}
+
+		// Checking if the season finished airing before the window by looking at when the next season started
+		if i+1 < len(seasons) {
+			end, err := time.ParseInLocation(time.DateOnly, seasons[i+1].AirDate, time.Local)
+			if err == nil && end.Before(from) {
+				continue
+			}
+		}
+
+		ret = append(ret, season.SeasonNumber)
 	}
 
-	return nil
+	// Falling back to the latest season if the series has no season information
+	if len(seasons) == 0 && series.NumberOfSeasons > 0 {
+		ret = append(ret, series.NumberOfSeasons)
+	}
+
+	return ret
 }
 
-func (SeriesService) makeEmbedForEpisode(
-	series *moviedb.SeriesDetails,
-	season *moviedb.SeasonDetails,
-	episode *moviedb.EpisodeDetails,
-	subscriberIDs []uint64,
-) *discordgo.MessageEmbed {
+// getNotifiedOrQueuedTargets returns the targets that were notified about the episode or are waiting in the
+// outbox to be notified about it
+func (srv *SeriesService) getNotifiedOrQueuedTargets(ctx context.Context, episode, season int, seriesID uint64) ([]NotificationTarget, error) {
+	notified, err := srv.notiRepo.GetNotifiedTargets(ctx, episode, season, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	queued, err := srv.outboxSrv.GetQueuedTargets(ctx, episode, season, seriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(notified, queued...), nil
+}
+
+// ErrNotNotified is returned when resending an episode to a target it was never delivered to
+var ErrNotNotified = errors.New("episode was not delivered to target")
+
+// GetRecentNotifications returns up to limit of the most recently delivered episodes, latest first
+func (srv *SeriesService) GetRecentNotifications(ctx context.Context, limit uint64) ([]*Notification, error) {
+	return srv.notiRepo.GetLatest(ctx, limit)
+}
+
+// ResendNotification queues an episode that was already delivered to the target to be delivered again. The
+// message is rebuilt from the latest information on TMDB and the target's current subscribers. The notification
+// is replaced with the new message once it's delivered
+func (srv *SeriesService) ResendNotification(ctx context.Context, episode, season int, seriesID uint64, target NotificationTarget) error {
+	notified, err := srv.notiRepo.GetNotifiedTargets(ctx, episode, season, seriesID)
+	if err != nil {
+		return err
+	} else if !slices.Contains(notified, target) {
+		return ErrNotNotified
+	}
+
+	series, _, err := srv.GetSeriesDetails(ctx, seriesID)
+	if err != nil {
+		return err
+	}
+	seasonDetails := &moviedb.SeasonDetails{}
+	if _, err = srv.movieDBClient.GetTVSeasonDetails(seriesID, season, seasonDetails,
+		moviedb.RequestOptionWithQueryParams("language", "en-US"),
+		moviedb.RequestOptionWithContext(ctx),
+	); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(seasonDetails.Episodes, func(e moviedb.EpisodeDetails) bool {
+		return e.EpisodeNumber == episode
+	})
+	if i == -1 {
+		return fmt.Errorf("episode %d of season %d: %w", episode, season, moviedb.ErrNotFound)
+	}
+	audience, err := srv.getSeriesAudience(ctx, seriesID)
+	if err != nil {
+		return err
+	}
+
+	item := &OutboxItem{
+		Episode:            episode,
+		Season:             season,
+		SeriesID:           seriesID,
+		NotificationTarget: target,
+		Complete:           episodeIsComplete(&seasonDetails.Episodes[i]),
+	}
+	item.Embed.V = srv.makeEmbedForEpisode(series, seasonDetails, &seasonDetails.Episodes[i], audience.WatcherIDs(target))
+	item.MentionIDs.V = audience.MentionIDs(target)
+
+	return srv.outboxSrv.Enqueue(ctx, item)
+}
+
+func (srv *SeriesService) canSkipCheckForNewEpisodes(
+	ctx context.Context,
+	seriesModel *Series,
+	epoch time.Time,
+	audience *seriesAudience,
+	changed *seriesChanges,
+) bool {
+	lastEpisode := seriesModel.Data.V.LastEpisodeToAir
+
+	// Checking to see if the last released episode was delivered to everyone still owed it
+	if lastEpisode != nil {
+		releaseDate, err := time.ParseInLocation(time.DateOnly, lastEpisode.AirDate, time.Local)
+		if err == nil && !releaseDate.Before(epoch) {
+			en := lastEpisode.EpisodeNumber
+			sn := lastEpisode.SeasonNumber
+			notified, err := srv.getNotifiedOrQueuedTargets(ctx, en, sn, seriesModel.ID)
+			if err == nil && len(audience.PendingTargets(releaseDate, notified)) > 0 {
+				return false
+			}
+		}
+	}
+
+	// When the changes on TMDB are known for as long as the series has been subscribed to it only needs to be
+	// fetched if it changed or its next episode aired, since an episode airing isn't a change on TMDB. Every
+	// earlier scan fetched or skipped the series the same way so the cache is up to date as of the last check
+	if changed != nil {
+		if changed.ids[seriesModel.ID] {
+			return false
+		} else if epoch.Before(changed.since) {
+			next := seriesModel.NextEpisodeAirDate
+			return !next.Valid || srv.clock.Now().Before(next.V)
+		} else if seriesModel.LastFetchedAt.Before(epoch) {
+			// The series was cached before it was last subscribed to and could have changed while nobody was
+			// subscribed to it
+			return false
+		}
+	}
+
+	// Series cached before checks were scheduled are scheduled from what's cached
+	nextCheckAt := seriesModel.NextCheckAt.V
+	if !seriesModel.NextCheckAt.Valid {
+		nextCheckAt = srv.schedule.NextCheck(seriesModel)
+	}
+
+	return srv.clock.Now().Before(nextCheckAt)
+}
+
+// seriesIsFinished returns true if the series ended or was cancelled and will never have new episodes
+func seriesIsFinished(series *moviedb.SeriesDetails) bool {
+	return series.Status == "Ended" || series.Status == "Canceled" || series.Status == "Cancelled"
+}
+
+func (srv *SeriesService) sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(
+	ctx context.Context,
+	series []*moviedb.SeriesDetails,
+) error {
+	if len(series) == 0 {
+		return nil
+	}
+
+	seriesIDs := utils.MapSlice(series, func(s *moviedb.SeriesDetails, _ int) uint64 {
+		return s.ID
+	})
+
+	subs, err := srv.subsSrv.GetSubscriptionsForSeries(ctx, seriesIDs...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed getting all subscribers for series", "series", seriesIDs)
+		return err
+	}
+	guildIDs := []uint64{}
+	for _, sub := range subs {
+		if !slices.Contains(guildIDs, sub.GuildID) {
+			guildIDs = append(guildIDs, sub.GuildID)
+		}
+	}
+	guilds, err := srv.guildsSrv.GetGuildsByIDs(ctx, guildIDs...)
+	if err != nil {
+		return err
+	}
+
+	// Letting each guild know about the series its members were subscribed to
+	failed := map[uint64]bool{}
+	errs := []error{}
+	for _, guildID := range guildIDs {
+		guild := guilds[guildID]
+		if guild == nil {
+			slog.WarnContext(ctx, "Guild has no notifications channel set up", "guild_id", guildID)
+			continue
+		}
+
+		cancelled := ""
+		ended := ""
+		subscriberIDs := []uint64{}
+		for _, s := range series {
+			guildSubs := utils.Filter(subs, func(sub *Subscription) bool {
+				return sub.GuildID == guildID && sub.SeriesID == s.ID
+			})
+			if len(guildSubs) == 0 {
+				continue
+			}
+
+			for _, sub := range guildSubs {
+				if !slices.Contains(subscriberIDs, sub.UserID) {
+					subscriberIDs = append(subscriberIDs, sub.UserID)
+				}
+			}
+			if s.Status == "Ended" {
+				ended += "\n- " + s.Name
+			} else {
+				cancelled += "\n- " + s.Name
+			}
+		}
+
+		_, err := srv.discord.ChannelMessageSendComplex(strconv.FormatUint(guild.NotificationsChannelID, 10), &discordgo.MessageSend{
+			Embed: srv.makeEmbedForFinishedSeries(cancelled, ended),
+			Content: utils.Reduce(subscriberIDs, func(a string, e uint64) string {
+				return a + "\n" + fmt.Sprintf("<@%d>", e)
+			}, ""),
+		}, discordgo.WithContext(ctx))
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send finished series message", "guild_id", guildID, "error", err)
+			failed[guildID] = true
+			errs = append(errs, fmt.Errorf("guild %d: %w", guildID, err))
+		}
+	}
+
+	// Keeping the subscriptions of the guilds that weren't told the series finished so they're told on the
+	// next scan
+	if len(failed) == 0 {
+		if err = srv.subsSrv.DeleteSubscriptionsForSeries(ctx, seriesIDs...); err != nil {
+			slog.ErrorContext(ctx, "Failed unsubscribing subscribers from series", "series", seriesIDs)
+			return err
+		}
+
+		return nil
+	}
+	for _, sub := range subs {
+		if failed[sub.GuildID] {
+			continue
+		}
+		if err = srv.subsSrv.DeleteUserSubscription(ctx, sub.GuildID, sub.SeriesID, sub.UserID); err != nil {
+			slog.ErrorContext(ctx, "Failed unsubscribing subscriber from series", "series_id", sub.SeriesID, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// makeEpisodeFields returns the season, episode and runtime fields shown for an episode
+func makeEpisodeFields(season, episode, runtime int) []*discordgo.MessageEmbedField {
+	return []*discordgo.MessageEmbedField{
+		{
+			Name:   "Season",
+			Value:  strconv.FormatInt(int64(season), 10),
+			Inline: true,
+		},
+		{
+			Name:   "Episode",
+			Value:  strconv.FormatInt(int64(episode), 10),
+			Inline: true,
+		},
+		{
+			Name:   "Runtime",
+			Value:  HumanDuration(time.Minute * time.Duration(runtime)),
+			Inline: true,
+		},
+	}
+}
+
+func (SeriesService) makeEmbedForFinishedSeries(cancelled, ended string) *discordgo.MessageEmbed {
+	cancelled = strings.Trim(cancelled, "\n")
+	ended = strings.Trim(ended, "\n")
+
+	fields := make([]*discordgo.MessageEmbedField, 0, 2)
+	if cancelled != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Cancelled",
+			Inline: true,
+			Value:  cancelled,
+		})
+	}
+	if ended != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Ended",
+			Inline: true,
+			Value:  ended,
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Series cancelled or ended",
+		Description: "Unfortunately the following series have either ended or been cancelled :pensive:",
+		Fields:      fields,
+	}
+}
+
+func (SeriesService) makeEmbedForEpisode(
+	series *moviedb.SeriesDetails,
+	season *moviedb.SeasonDetails,
+	episode *moviedb.EpisodeDetails,
+	subscriberIDs []uint64,
+) *discordgo.MessageEmbed {
 	embed := &discordgo.MessageEmbed{
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:   "Season",
-				Value:  strconv.FormatInt(int64(season.SeasonNumber), 10),
-				Inline: true,
-			},
-			{
-				Name:   "Episode",
-				Value:  strconv.FormatInt(int64(episode.EpisodeNumber), 10),
-				Inline: true,
-			},
-			{
-				Name:   "Runtime",
-				Value:  HumanDuration(time.Minute * time.Duration(episode.Runtime)),
-				Inline: true,
-			},
-			{
+		Fields: append(makeEpisodeFields(season.SeasonNumber, episode.EpisodeNumber, episode.Runtime),
+			&discordgo.MessageEmbedField{
 				Name:   "Watchers",
 				Inline: true,
 				Value: strings.Join(utils.MapSlice(subscriberIDs, func(sID uint64, _ int) string {
 					return fmt.Sprintf("<@%d>", sID)
 				}), " "),
 			},
-			{
+			&discordgo.MessageEmbedField{
 				Name:   "Episode type",
 				Inline: true,
 				Value:  episode.EpisodeType,
 			},
-		},
+		),
 		Author: &discordgo.MessageEmbedAuthor{
 			Name: series.Name,
 		},
@@ -436,39 +1192,181 @@ func (SeriesService) makeEmbedForEpisode(
 	return embed
 }
 
-func (srv *SeriesService) sendEmbedsAndMakeNotifications(
+// updateIncompleteNotifications rebuilds the embed of an episode that was missing information when it was
+// delivered and edits the messages it was delivered in if the embed changed. A notification stops being
+// updated once the episode has all its information or a week after it was delivered
+func (srv *SeriesService) updateIncompleteNotifications(
 	ctx context.Context,
+	series *moviedb.SeriesDetails,
+	season *moviedb.SeasonDetails,
+	episode *moviedb.EpisodeDetails,
+	audience *seriesAudience,
 	notifications []*Notification,
-	embeds []*discordgo.MessageEmbed,
-	subscriberIDs []uint64,
 ) error {
-	channelID := viper.GetString("discord.notifications_channel_id")
-	data := &discordgo.MessageSend{
-		Embeds: embeds,
-		Content: strings.Join(utils.MapSlice(subscriberIDs, func(sID uint64, _ int) string {
-			return fmt.Sprintf("<@%d>", sID)
-		}), " "),
+	complete := episodeIsComplete(episode)
+	done := []*Notification{}
+	for _, n := range notifications {
+		logger := slog.With("series_id", n.SeriesID, "season_number", n.Season, "episode_id", n.Episode, "message_id", n.DiscordMessageID)
+		if complete {
+			done = append(done, n)
+		} else if srv.clock.Since(n.CreatedAt) > time.Hour*24*7 {
+			done = append(done, n)
+			continue
+		}
+
+		channelID := strconv.FormatUint(n.DiscordChannelID, 10)
+		messageID := strconv.FormatUint(n.DiscordMessageID, 10)
+		m, err := srv.discord.ChannelMessage(channelID, messageID, discordgo.WithContext(ctx))
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to get notification message", "error", err)
+			continue
+		} else if n.EmbedIndex >= len(m.Embeds) {
+			continue
+		}
+
+		embed := srv.makeEmbedForEpisode(series, season, episode, audience.WatcherIDs(n.NotificationTarget))
+		if !episodeEmbedChanged(m.Embeds[n.EmbedIndex], embed) {
+			continue
+		}
+
+		embeds := slices.Clone(m.Embeds)
+		embeds[n.EmbedIndex] = embed
+		edit := discordgo.NewMessageEdit(m.ChannelID, m.ID).SetEmbeds(embeds)
+		if _, err = srv.discord.ChannelMessageEditComplex(edit, discordgo.WithContext(ctx)); err != nil {
+			logger.ErrorContext(ctx, "Failed to edit notification message", "error", err)
+			continue
+		}
+		logger.InfoContext(ctx, "Updated notification with new episode information")
 	}
 
-	m, err := srv.discord.ChannelMessageSendComplex(channelID, data)
+	return srv.notiRepo.MarkComplete(ctx, done...)
+}
+
+// episodeKey identifies an episode of a series
+type episodeKey struct {
+	Season  int
+	Episode int
+}
+
+// episodeIsComplete returns true if TMDB has filled in all the episode information shown in a notification
+func episodeIsComplete(episode *moviedb.EpisodeDetails) bool {
+	return episode.Overview != "" && episode.StillPath != "" && episode.Runtime != 0
+}
+
+// episodeEmbedChanged returns true if the information about the episode shown in the embeds differ
+func episodeEmbedChanged(a, b *discordgo.MessageEmbed) bool {
+	if a.Title != b.Title || a.Description != b.Description || (a.Image == nil) != (b.Image == nil) {
+		return true
+	} else if a.Image != nil && a.Image.URL != b.Image.URL {
+		return true
+	}
+
+	return !slices.EqualFunc(a.Fields, b.Fields, func(x, y *discordgo.MessageEmbedField) bool {
+		return x.Name == y.Name && x.Value == y.Value
+	})
+}
+
+// seriesAudience is everyone that should be notified about new episodes of a series
+type seriesAudience struct {
+	guilds             map[uint64]*Guild
+	prefs              map[uint64]*UserPreference
+	guildSubscriberIDs map[uint64][]uint64
+	dmSubscriberIDs    []uint64
+}
+
+// getSeriesAudience works out where new episodes of the series should be delivered based on the guilds
+// subscribers are in and how each subscriber wants to receive new episodes
+func (srv *SeriesService) getSeriesAudience(ctx context.Context, seriesID uint64) (*seriesAudience, error) {
+	subs, err := srv.subsSrv.GetSubscriptionsForSeries(ctx, seriesID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, n := range notifications {
-		id, err := strconv.ParseUint(m.ID, 10, 64)
-		if err != nil {
-			return err
+	audience := &seriesAudience{guildSubscriberIDs: map[uint64][]uint64{}}
+	userIDs := []uint64{}
+	for _, sub := range subs {
+		audience.guildSubscriberIDs[sub.GuildID] = append(audience.guildSubscriberIDs[sub.GuildID], sub.UserID)
+		if !slices.Contains(userIDs, sub.UserID) {
+			userIDs = append(userIDs, sub.UserID)
+		}
+	}
+
+	if audience.prefs, err = srv.prefsSrv.GetPreferencesForUsers(ctx, userIDs...); err != nil {
+		return nil, err
+	}
+	guildIDs := utils.Map(audience.guildSubscriberIDs, func(_ []uint64, id uint64) uint64 { return id })
+	if audience.guilds, err = srv.guildsSrv.GetGuildsByIDs(ctx, guildIDs...); err != nil {
+		return nil, err
+	}
+	audience.dmSubscriberIDs = utils.Filter(userIDs, func(id uint64) bool {
+		return audience.prefs[id].ReceivesDMs()
+	})
+
+	return audience, nil
+}
+
+// Targets returns every target new episodes should be delivered to
+func (a *seriesAudience) Targets() []NotificationTarget {
+	targets := []NotificationTarget{}
+	for guildID := range a.guilds {
+		if len(a.MentionIDs(NotificationTarget{GuildID: guildID})) > 0 {
+			targets = append(targets, NotificationTarget{GuildID: guildID})
+		}
+	}
+	for _, userID := range a.dmSubscriberIDs {
+		targets = append(targets, NotificationTarget{UserID: userID})
+	}
+
+	return targets
+}
+
+// PendingTargets returns the targets an episode that released on the date provided still needs to be delivered to.
+// Targets are only delivered episodes that aired after they started receiving episodes that way so changing
+// delivery modes or notification channels doesn't flood them with old episodes
+func (a *seriesAudience) PendingTargets(releaseDate time.Time, notified []NotificationTarget) []NotificationTarget {
+	return utils.Filter(a.Targets(), func(t NotificationTarget) bool {
+		if slices.Contains(notified, t) {
+			return false
+		}
+
+		if t.UserID != 0 {
+			return !releaseDate.Before(a.prefs[t.UserID].DMDeliveryStartedSince())
 		}
 
-		n.DiscordMessageID = id
+		return !releaseDate.Before(a.guilds[t.GuildID].NotificationsChannelSetSince()) &&
+			slices.ContainsFunc(a.MentionIDs(t), func(id uint64) bool {
+				return !releaseDate.Before(a.prefs[id].ChannelDeliveryStartedSince())
+			})
+	})
+}
+
+// MentionIDs returns the IDs of the users that should be mentioned in messages sent to the target
+func (a *seriesAudience) MentionIDs(t NotificationTarget) []uint64 {
+	if t.UserID != 0 {
+		return nil
+	}
+
+	return utils.Filter(a.guildSubscriberIDs[t.GuildID], func(id uint64) bool {
+		return a.prefs[id].ReceivesInChannel()
+	})
+}
+
+// InDigest returns true if episodes for the target should be queued for a digest instead of being sent right away
+func (a *seriesAudience) InDigest(t NotificationTarget) bool {
+	return t.UserID != 0 && a.prefs[t.UserID].DigestFrequency != DigestFrequencyOff
+}
+
+// WatcherIDs returns the IDs of the users that should be listed as watching the series in messages sent to the target
+func (a *seriesAudience) WatcherIDs(t NotificationTarget) []uint64 {
+	if t.UserID != 0 {
+		return []uint64{t.UserID}
 	}
 
-	return srv.notiRepo.InsertMany(ctx, notifications)
+	return a.guildSubscriberIDs[t.GuildID]
 }
 
-type commandHandler = func(context.Context, *discordgo.Session, *discordgo.InteractionCreate)
-type autocompleteHandler = func(context.Context, *discordgo.Session, *discordgo.InteractionCreate, *discordgo.ApplicationCommandInteractionDataOption)
+type commandHandler = func(context.Context, utils.DiscordSender, *discordgo.InteractionCreate)
+type autocompleteHandler = func(context.Context, utils.DiscordSender, *discordgo.InteractionCreate, *discordgo.ApplicationCommandInteractionDataOption)
 type discordCommand struct {
 	discordgo.ApplicationCommand
 	Handle       commandHandler
@@ -479,18 +1377,47 @@ func (dc *discordCommand) addToHandlersMap(m map[string]*discordCommand) {
 	m[dc.Name] = dc
 }
 
+// describeReminderLeadTime describes when a user with the lead time provided is reminded about episodes. Episodes
+// only have an air date so lead times are described in days
+func describeReminderLeadTime(lead time.Duration) string {
+	switch days := int(lead.Round(time.Hour*24) / (time.Hour * 24)); days {
+	case 0, 1:
+		return "The day before an episode airs"
+	case 7:
+		return "A week before an episode airs"
+	default:
+		return fmt.Sprintf("%d days before an episode airs", days)
+	}
+}
+
 type DiscordCommandService struct {
-	sess      *discordgo.Session
+	sess      utils.DiscordSender
 	commands  map[string]*discordCommand
 	seriesSrv *SeriesService
 	subsSrv   *SubscriptionsService
+	prefsSrv  *PreferencesService
+	guildsSrv *GuildsService
+	jobsSrv   *JobsService
+	metrics   *Metrics
 }
 
-func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *SubscriptionsService) *DiscordCommandService {
+func NewDiscordCommandService(
+	s utils.DiscordSender,
+	ss *SeriesService,
+	sus *SubscriptionsService,
+	ps *PreferencesService,
+	gs *GuildsService,
+	js *JobsService,
+	m *Metrics,
+) *DiscordCommandService {
 	srv := &DiscordCommandService{
 		seriesSrv: ss,
 		sess:      s,
 		subsSrv:   sus,
+		prefsSrv:  ps,
+		guildsSrv: gs,
+		jobsSrv:   js,
+		metrics:   m,
 		commands:  map[string]*discordCommand{},
 	}
 
@@ -509,7 +1436,7 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 				},
 			},
 		},
-		Handle: func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
 			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 				Data: &discordgo.InteractionResponseData{
@@ -519,6 +1446,7 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 
 			resp := utils.NewDiscordResponse(s, i)
 			seriesOpt := i.ApplicationCommandData().Options[0]
+			guildID, _ := strconv.ParseUint(i.GuildID, 10, 64)
 			userID, _ := strconv.ParseUint(i.Member.User.ID, 10, 64)
 			seriesID, err := strconv.ParseUint(seriesOpt.StringValue(), 10, 64)
 			if err != nil {
@@ -527,7 +1455,10 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 			}
 
 			series, _, err := srv.seriesSrv.GetSeriesDetails(ctx, seriesID)
-			if err != nil {
+			if errors.Is(err, moviedb.ErrNotFound) {
+				resp.SetWarning("").SetTitle("Series could not be found").Edit()
+				return
+			} else if err != nil {
 				slog.ErrorContext(ctx, "Failed to get series information", "error", err)
 				resp.SetError(err).SetTitle("Failed to look up information about series").Edit()
 				return
@@ -536,13 +1467,15 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 			// Checking if series has ended and removing all subscriptions for it if it has
 			status := strings.ToLower(series.Status)
 			if status == "canceled" || status == "ended" {
-				srv.subsSrv.DeleteSubscriptionsForSeries(ctx, seriesID)
+				if err = srv.subsSrv.DeleteSubscriptionsForSeries(ctx, seriesID); err != nil {
+					slog.ErrorContext(ctx, "Failed unsubscribing subscribers from finished series", "series_id", seriesID, "error", err)
+				}
 				slog.ErrorContext(ctx, "User tried to subscribe to a canceled/finished series", "series", series.Name, "user", i.Member.User.Username)
 				resp.SetWarning("You cannot subscribe to a series that has ended or been canceled").SetTitlef("Series %s", status).Edit()
 				return
 			}
 
-			isSubbed, err := srv.subsSrv.UserIsSubscribed(ctx, seriesID, userID)
+			isSubbed, err := srv.subsSrv.UserIsSubscribed(ctx, guildID, seriesID, userID)
 			if err != nil {
 				slog.ErrorContext(ctx, "Failed checking if user is subscribed", "user_id", userID, "series_id", seriesID)
 				resp.SetError(err).SetTitle("Failed checking subscription status").Edit()
@@ -552,7 +1485,7 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 				return
 			}
 
-			if err = srv.subsSrv.SubscribeUserToSeries(ctx, seriesID, userID); err != nil {
+			if err = srv.subsSrv.SubscribeUserToSeries(ctx, guildID, seriesID, userID); err != nil {
 				slog.ErrorContext(ctx, "Failed to subscribe user to series", "user_id", userID, "series_id", seriesID, "error", err)
 				resp.SetError(err).SetTitle("Failed to subscribe you to series").Edit()
 				return
@@ -566,7 +1499,11 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 			if series.PosterPath != "" {
 				thumbnailPath, _ = url.JoinPath("https://image.tmdb.org/t/p/w780", series.PosterPath)
 			}
-			resp.SetSuccess("You will now receive updates when new episodes release").
+			description := "You will now receive updates when new episodes release"
+			if guilds, err := srv.guildsSrv.GetGuildsByIDs(ctx, guildID); err == nil && guilds[guildID] == nil {
+				description += ". An admin still needs to pick a channel for new episodes with `/setup channel`"
+			}
+			resp.SetSuccess(description).
 				SetImage(imagePath).
 				SetThumbnail(thumbnailPath).
 				SetTitlef("Successfully subscribed to '%s'", series.Name).
@@ -583,7 +1520,7 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 			Description:  "Lists all series you are subscribed to",
 			DMPermission: PP(false),
 		},
-		Handle: func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
 			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 				Data: &discordgo.InteractionResponseData{
@@ -592,9 +1529,10 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 			})
 
 			resp := utils.NewDiscordResponse(s, i)
+			guildID, _ := strconv.ParseUint(i.GuildID, 10, 64)
 			userID, _ := strconv.ParseUint(i.Member.User.ID, 10, 64)
 
-			subs, err := srv.subsSrv.GetUserSubscriptions(ctx, userID)
+			subs, err := srv.subsSrv.GetUserSubscriptions(ctx, guildID, userID)
 			if err != nil {
 				slog.ErrorContext(ctx, "Failed to get user's subscriptions", "error", err)
 				resp.SetError(err).SetTitle("Failed get your subscriptions").Edit()
@@ -640,19 +1578,407 @@ func NewDiscordCommandService(s *discordgo.Session, ss *SeriesService, sus *Subs
 				},
 			},
 		},
-		Handle: func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
-
-		},
-		Autocomplete: map[string]autocompleteHandler{
-			"series": srv.autocompleteForSeriesName,
-		},
-	}).addToHandlersMap(srv.commands)
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Flags: discordgo.MessageFlagsEphemeral,
+				},
+			})
 
-	return srv
-}
+			resp := utils.NewDiscordResponse(s, i)
+			seriesOpt := i.ApplicationCommandData().Options[0]
+			guildID, _ := strconv.ParseUint(i.GuildID, 10, 64)
+			userID, _ := strconv.ParseUint(i.Member.User.ID, 10, 64)
+			seriesID, err := strconv.ParseUint(seriesOpt.StringValue(), 10, 64)
+			if err != nil {
+				resp.SetWarning("").SetTitle("Series must be selected from the list").Edit()
+				return
+			}
+
+			isSubbed, err := srv.subsSrv.UserIsSubscribed(ctx, guildID, seriesID, userID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed checking if user is subscribed", "user_id", userID, "series_id", seriesID)
+				resp.SetError(err).SetTitle("Failed checking subscription status").Edit()
+				return
+			} else if !isSubbed {
+				resp.SetWarning("").SetTitle("You are not subscribed to this series").Edit()
+				return
+			}
+
+			if err = srv.subsSrv.DeleteUserSubscription(ctx, guildID, seriesID, userID); err != nil {
+				slog.ErrorContext(ctx, "Failed to unsubscribe user from series", "user_id", userID, "series_id", seriesID, "error", err)
+				resp.SetError(err).SetTitle("Failed to unsubscribe you from series").Edit()
+				return
+			}
+
+			series, _, err := srv.seriesSrv.GetSeriesDetails(ctx, seriesID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to get series information", "error", err)
+				resp.SetSuccess("You will no longer receive updates for this series").
+					SetTitle("Successfully unsubscribed from series").
+					Edit()
+				return
+			}
+
+			thumbnailPath := ""
+			if series.PosterPath != "" {
+				thumbnailPath, _ = url.JoinPath("https://image.tmdb.org/t/p/w780", series.PosterPath)
+			}
+			resp.SetSuccess("You will no longer receive updates when new episodes release").
+				SetThumbnail(thumbnailPath).
+				SetTitlef("Successfully unsubscribed from '%s'", series.Name).
+				Edit()
+		},
+		Autocomplete: map[string]autocompleteHandler{
+			"series": srv.autocompleteForSubscribedSeriesName,
+		},
+	}).addToHandlersMap(srv.commands)
+
+	(&discordCommand{
+		ApplicationCommand: discordgo.ApplicationCommand{
+			Name:         "upcoming",
+			Description:  "Lists upcoming episodes of the series you are subscribed to",
+			DMPermission: PP(false),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "days",
+					Description: "How many days ahead to look, including today (defaults to 7)",
+					MinValue:    PP(1.0),
+					MaxValue:    90,
+				},
+			},
+		},
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Flags: discordgo.MessageFlagsEphemeral,
+				},
+			})
+
+			resp := utils.NewDiscordResponse(s, i)
+			guildID, _ := strconv.ParseUint(i.GuildID, 10, 64)
+			userID, _ := strconv.ParseUint(i.Member.User.ID, 10, 64)
+			days := 7
+			for _, opt := range i.ApplicationCommandData().Options {
+				if opt.Name == "days" {
+					days = int(opt.IntValue())
+				}
+			}
+
+			upcoming, unannounced, err := srv.seriesSrv.GetUpcomingEpisodes(ctx, guildID, userID, days)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to get user's upcoming episodes", "user_id", userID, "error", err)
+				resp.SetError(err).SetTitle("Failed to get your upcoming episodes").Edit()
+				return
+			}
+
+			// Listing each episode on its own line, stopping before going over the embed description limit
+			description := ""
+			for _, u := range upcoming {
+				line := fmt.Sprintf("<t:%d:D> **%s** S%dE%d", u.AirsAt.Unix(), u.Series.Name, u.Episode.SeasonNumber, u.Episode.EpisodeNumber)
+				if u.Episode.Name != "" {
+					line += " · " + u.Episode.Name
+				}
+				if len(description)+len(line)+1 > 4096 {
+					break
+				}
+				description += "\n" + line
+			}
+			description = strings.TrimPrefix(description, "\n")
+			if description == "" {
+				description = fmt.Sprintf("Nothing you are subscribed to airs in the next %d day(s)", days)
+			}
+
+			if len(unannounced) > 0 {
+				list := ""
+				for _, s := range unannounced {
+					line := "\n- " + s.Name
+					if len(list)+len(line) > 1024 {
+						break
+					}
+					list += line
+				}
+				resp.AddField("No announced date", strings.TrimPrefix(list, "\n"), false)
+			}
+
+			resp.SetInfo(description).
+				SetTitlef("Upcoming episodes in the next %d day(s)", days).
+				Edit()
+		},
+	}).addToHandlersMap(srv.commands)
+
+	(&discordCommand{
+		ApplicationCommand: discordgo.ApplicationCommand{
+			Name:         "preferences",
+			Description:  "Shows or changes how you are notified about new episodes",
+			DMPermission: PP(false),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "delivery",
+					Description: "Where new episodes should be sent to you",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Notifications channel", Value: string(DeliveryModeChannel)},
+						{Name: "Direct message", Value: string(DeliveryModeDM)},
+						{Name: "Both", Value: string(DeliveryModeBoth)},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "reminder",
+					Description: "Sends you a direct message before new episodes of your subscriptions air",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Off", Value: 0},
+						{Name: "The day before", Value: 1440},
+						{Name: "2 days before", Value: 2880},
+						{Name: "A week before", Value: 10080},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "digest",
+					Description: "Get one direct message summarising new episodes instead of a ping for each",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Off", Value: "off"},
+						{Name: "Daily", Value: string(DigestFrequencyDaily)},
+						{Name: "Weekly", Value: string(DigestFrequencyWeekly)},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "digest_time",
+					Description: "The time your digest is sent at in 24 hour time (e.g. 20:00)",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "digest_day",
+					Description: "The day your weekly digest is sent on",
+					Choices: utils.MapSlice([]time.Weekday{
+						time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday,
+					}, func(d time.Weekday, _ int) *discordgo.ApplicationCommandOptionChoice {
+						return &discordgo.ApplicationCommandOptionChoice{Name: d.String(), Value: int(d)}
+					}),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "timezone",
+					Description: "The timezone your digest time is in (e.g. America/Toronto)",
+				},
+			},
+		},
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Flags: discordgo.MessageFlagsEphemeral,
+				},
+			})
+
+			resp := utils.NewDiscordResponse(s, i)
+			userID, _ := strconv.ParseUint(i.Member.User.ID, 10, 64)
+
+			for _, opt := range i.ApplicationCommandData().Options {
+				switch opt.Name {
+				case "delivery":
+					if err := srv.prefsSrv.SetDeliveryMode(ctx, userID, DeliveryMode(opt.StringValue())); err != nil {
+						slog.ErrorContext(ctx, "Failed to set user's delivery mode", "user_id", userID, "error", err)
+						resp.SetError(err).SetTitle("Failed to update your preferences").Edit()
+						return
+					}
+				case "reminder":
+					lead := time.Duration(opt.IntValue()) * time.Minute
+					if err := srv.prefsSrv.SetReminderLeadTime(ctx, userID, lead); err != nil {
+						slog.ErrorContext(ctx, "Failed to set user's reminder lead time", "user_id", userID, "error", err)
+						resp.SetError(err).SetTitle("Failed to update your preferences").Edit()
+						return
+					}
+				case "digest":
+					freq := DigestFrequency(opt.StringValue())
+					if freq == "off" {
+						freq = DigestFrequencyOff
+					}
+					if err := srv.prefsSrv.SetDigestFrequency(ctx, userID, freq); err != nil {
+						slog.ErrorContext(ctx, "Failed to set user's digest frequency", "user_id", userID, "error", err)
+						resp.SetError(err).SetTitle("Failed to update your preferences").Edit()
+						return
+					}
+				case "digest_time":
+					t, err := time.Parse("15:04", opt.StringValue())
+					if err != nil {
+						resp.SetWarning("Times must be in 24 hour time like `20:00`").SetTitle("Invalid digest time").Edit()
+						return
+					}
+					if err := srv.prefsSrv.SetDigestTime(ctx, userID, t.Hour()*60+t.Minute()); err != nil {
+						slog.ErrorContext(ctx, "Failed to set user's digest time", "user_id", userID, "error", err)
+						resp.SetError(err).SetTitle("Failed to update your preferences").Edit()
+						return
+					}
+				case "digest_day":
+					if err := srv.prefsSrv.SetDigestWeekday(ctx, userID, time.Weekday(opt.IntValue())); err != nil {
+						slog.ErrorContext(ctx, "Failed to set user's digest day", "user_id", userID, "error", err)
+						resp.SetError(err).SetTitle("Failed to update your preferences").Edit()
+						return
+					}
+				case "timezone":
+					if err := srv.prefsSrv.SetDigestTimezone(ctx, userID, opt.StringValue()); err != nil {
+						resp.SetWarning("Timezones must be names like `America/Toronto`").SetTitle("Invalid timezone").Edit()
+						return
+					}
+				}
+			}
+
+			prefs, err := srv.prefsSrv.GetPreferencesForUsers(ctx, userID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to get user's preferences", "user_id", userID, "error", err)
+				resp.SetError(err).SetTitle("Failed to get your preferences").Edit()
+				return
+			}
+			pref := prefs[userID]
+
+			delivery := map[DeliveryMode]string{
+				DeliveryModeChannel: "Notifications channel",
+				DeliveryModeDM:      "Direct message",
+				DeliveryModeBoth:    "Notifications channel and direct message",
+			}[pref.DeliveryMode]
+
+			reminder := "Off"
+			if lead := pref.ReminderLeadTime(); lead > 0 {
+				reminder = describeReminderLeadTime(lead)
+			}
+
+			digest := "Off"
+			if pref.DigestFrequency != DigestFrequencyOff {
+				digest = fmt.Sprintf("%s at %02d:%02d %s", pref.DigestFrequency, pref.DigestMinute/60, pref.DigestMinute%60, pref.DigestLocation())
+				if pref.DigestFrequency == DigestFrequencyWeekly {
+					digest = fmt.Sprintf("%s on %ss", digest, pref.DigestWeekday)
+				}
+			}
+
+			resp.AddField("Delivery", delivery, false).
+				AddField("Reminders", reminder, false).
+				AddField("Digest", digest, false).
+				SetInfo("").
+				SetTitle("Your preferences").
+				Edit()
+		},
+	}).addToHandlersMap(srv.commands)
+
+	(&discordCommand{
+		ApplicationCommand: discordgo.ApplicationCommand{
+			Name:                     "setup",
+			Description:              "Sets up the bot for this server",
+			DMPermission:             PP(false),
+			DefaultMemberPermissions: PP(int64(discordgo.PermissionManageServer)),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "channel",
+					Description: "Sets the channel new episodes are posted in",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionChannel,
+							Name:         "channel",
+							Description:  "The channel to post new episodes in",
+							Required:     true,
+							ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+						},
+					},
+				},
+			},
+		},
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Flags: discordgo.MessageFlagsEphemeral,
+				},
+			})
+
+			resp := utils.NewDiscordResponse(s, i)
+			guildID, _ := strconv.ParseUint(i.GuildID, 10, 64)
+			subCommand := i.ApplicationCommandData().Options[0]
+
+			switch subCommand.Name {
+			case "channel":
+				channel := subCommand.Options[0].ChannelValue(nil)
+				channelID, _ := strconv.ParseUint(channel.ID, 10, 64)
+				if err := srv.guildsSrv.SetNotificationsChannel(ctx, guildID, channelID); err != nil {
+					slog.ErrorContext(ctx, "Failed to set notifications channel", "guild_id", guildID, "channel_id", channelID, "error", err)
+					resp.SetError(err).SetTitle("Failed to set the notifications channel").Edit()
+					return
+				}
+
+				resp.SetSuccess(fmt.Sprintf("New episodes will now be posted in <#%s>", channel.ID)).
+					SetTitle("Notifications channel set").
+					Edit()
+			}
+		},
+	}).addToHandlersMap(srv.commands)
+
+	(&discordCommand{
+		ApplicationCommand: discordgo.ApplicationCommand{
+			Name:                     "status",
+			Description:              "Shows how the recent checks for new episodes went",
+			DMPermission:             PP(false),
+			DefaultMemberPermissions: PP(int64(discordgo.PermissionManageServer)),
+		},
+		Handle: func(ctx context.Context, s utils.DiscordSender, i *discordgo.InteractionCreate) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Flags: discordgo.MessageFlagsEphemeral,
+				},
+			})
+
+			resp := utils.NewDiscordResponse(s, i)
+			status, err := srv.jobsSrv.GetStatus(ctx, 5)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to get job status", "error", err)
+				resp.SetError(err).SetTitle("Failed to get the bot's status").Edit()
+				return
+			} else if len(status.Runs) == 0 {
+				resp.SetWarning("The bot hasn't checked for new episodes yet").SetTitle("Status").Edit()
+				return
+			}
+
+			last := status.Runs[0]
+			if status.Healthy {
+				resp.SetSuccess("The bot is checking for new episodes")
+			} else if last.Failed {
+				resp.SetWarning(fmt.Sprintf("The last check for new episodes failed```%s```", last.Error.V))
+			} else {
+				resp.SetWarning("The bot hasn't checked for new episodes recently")
+			}
+
+			recent := ""
+			for _, run := range status.Runs {
+				recent += fmt.Sprintf("\n- <t:%d:R> checked %d series and found %d episode(s) in %s",
+					run.StartedAt.Unix(), run.SeriesChecked, run.EpisodesNotified, run.Duration().Round(time.Millisecond),
+				)
+				if run.Failed {
+					recent += " but failed"
+				} else if run.SeriesFailed > 0 {
+					recent += fmt.Sprintf(" but %d series failed", run.SeriesFailed)
+				}
+			}
+
+			resp.AddField("Last check", fmt.Sprintf("<t:%d:R>", last.FinishedAt.Unix()), true).
+				AddField("Series checked", fmt.Sprintf("%d (%d skipped, %d failed)", last.SeriesChecked, last.SeriesSkipped, last.SeriesFailed), true).
+				AddField("TMDB calls", strconv.FormatInt(last.TMDBCalls, 10), true).
+				AddField("Recent checks", strings.TrimPrefix(recent, "\n"), false).
+				SetTitle("Status").
+				Edit()
+		},
+	}).addToHandlersMap(srv.commands)
+
+	return srv
+}
 
 func (srv *DiscordCommandService) RegisterHandlers(ctx context.Context) {
-	srv.sess.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	srv.sess.AddHandler(func(_ *discordgo.Session, i *discordgo.InteractionCreate) {
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
@@ -660,20 +1986,23 @@ func (srv *DiscordCommandService) RegisterHandlers(ctx context.Context) {
 		command := srv.commands[commandName]
 		if command == nil {
 			slog.WarnContext(ctx, "Unknown command", "command", commandName)
-			utils.NewDiscordResponse(s, i).SetWarning("").SetTitle("Unknown command").Respond()
+			utils.NewDiscordResponse(srv.sess, i).SetWarning("").SetTitle("Unknown command").Respond()
+			srv.metrics.ObserveCommand(commandName, CommandResultUnknown)
 			return
 		}
 
 		slog.InfoContext(ctx, "Received command", "command", commandName, "type", i.ApplicationCommandData().Type().String())
 		if i.Type == discordgo.InteractionApplicationCommand {
-			command.Handle(ctx, s, i)
+			recorder := newCommandResultRecorder(srv.sess)
+			command.Handle(ctx, recorder, i)
+			srv.metrics.ObserveCommand(commandName, recorder.Result())
 		} else if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
 			ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 			defer cancel()
 
 			for _, opt := range i.ApplicationCommandData().Options {
 				if opt.Focused {
-					command.Autocomplete[opt.Name](ctx, s, i, opt)
+					command.Autocomplete[opt.Name](ctx, srv.sess, i, opt)
 					break
 				}
 			}
@@ -683,18 +2012,18 @@ func (srv *DiscordCommandService) RegisterHandlers(ctx context.Context) {
 
 func (srv *DiscordCommandService) RegisterCommands(ctx context.Context) error {
 	appID := viper.GetString("discord.client_id")
-	serverID := viper.GetString("discord.server_id")
 	commands := utils.Map(srv.commands, func(cmd *discordCommand, _ string) *discordgo.ApplicationCommand {
 		return &cmd.ApplicationCommand
 	})
 
-	_, err := srv.sess.ApplicationCommandBulkOverwrite(appID, serverID, commands, discordgo.WithContext(ctx))
+	// Registering commands globally so they are available in every guild the bot is in
+	_, err := srv.sess.ApplicationCommandBulkOverwrite(appID, "", commands, discordgo.WithContext(ctx))
 	return err
 }
 
 func (srv *DiscordCommandService) autocompleteForSeriesName(
 	ctx context.Context,
-	s *discordgo.Session,
+	s utils.DiscordSender,
 	i *discordgo.InteractionCreate,
 	o *discordgo.ApplicationCommandInteractionDataOption,
 ) {
@@ -732,20 +2061,1127 @@ func (srv *DiscordCommandService) autocompleteForSeriesName(
 	})
 }
 
+func (srv *DiscordCommandService) autocompleteForSubscribedSeriesName(
+	ctx context.Context,
+	s utils.DiscordSender,
+	i *discordgo.InteractionCreate,
+	o *discordgo.ApplicationCommandInteractionDataOption,
+) {
+	partialName := strings.ToLower(o.StringValue())
+	guildID, _ := strconv.ParseUint(i.GuildID, 10, 64)
+	userID, _ := strconv.ParseUint(i.Member.User.ID, 10, 64)
+	slog.DebugContext(ctx, "Autocomplete for subscribed series", "value", partialName, "user_id", userID)
+
+	series, err := srv.seriesSrv.GetUserSubscribedSeries(ctx, guildID, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user's subscribed series", "user_id", userID, "error", err)
+		utils.NewDiscordResponse(s, i).SetError(err).SetTitle("Could not get your subscriptions").Respond()
+		return
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: utils.Clamp(subscribedSeriesChoices(series, partialName), 20),
+		},
+	})
+}
+
+// subscribedSeriesChoices returns the series whose name contains the lowercase partial name as autocomplete
+// choices sorted by name. Series are named with the year they first aired when it's known
+func subscribedSeriesChoices(series []*moviedb.SeriesDetails, partialName string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(series))
+	for _, s := range series {
+		if !strings.Contains(strings.ToLower(s.Name), partialName) {
+			continue
+		}
+
+		name := s.Name
+		if date, err := time.Parse(time.DateOnly, s.FirstAirDate); err == nil {
+			name = fmt.Sprintf("%s (%s)", s.Name, date.Format("2006"))
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: fmt.Sprint(s.ID),
+		})
+	}
+	slices.SortFunc(choices, func(a, b *discordgo.ApplicationCommandOptionChoice) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return choices
+}
+
 type SubscriptionsService struct {
-	*SubscriptionsRepo
+	SubscriptionsRepo
+	clock clock.Clock
 }
 
-func NewSubscriptionsService(sr *SubscriptionsRepo) *SubscriptionsService {
+func NewSubscriptionsService(sr SubscriptionsRepo, c clock.Clock) *SubscriptionsService {
 	return &SubscriptionsService{
 		SubscriptionsRepo: sr,
+		clock:             c,
 	}
 }
 
-func (srv *SubscriptionsService) SubscribeUserToSeries(ctx context.Context, seriesID, userID uint64) error {
+func (srv *SubscriptionsService) SubscribeUserToSeries(ctx context.Context, guildID, seriesID, userID uint64) error {
 	return srv.SubscriptionsRepo.Insert(ctx, &Subscription{
+		GuildID:   guildID,
 		SeriesID:  seriesID,
 		UserID:    userID,
-		CreatedAt: time.Now(),
+		CreatedAt: srv.clock.Now(),
+	})
+}
+
+type PreferencesService struct {
+	*PreferencesRepo
+	clock clock.Clock
+}
+
+func NewPreferencesService(pr *PreferencesRepo, c clock.Clock) *PreferencesService {
+	return &PreferencesService{
+		PreferencesRepo: pr,
+		clock:           c,
+	}
+}
+
+// GetPreferencesForUsers returns the preferences for all the users provided keyed by user ID. Users that have
+// not set their preferences are given the defaults
+func (srv *PreferencesService) GetPreferencesForUsers(ctx context.Context, userIDs ...uint64) (map[uint64]*UserPreference, error) {
+	prefs, err := srv.PreferencesRepo.GetUserPreferences(ctx, userIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range userIDs {
+		if prefs[id] == nil {
+			prefs[id] = &UserPreference{
+				UserID:       id,
+				DeliveryMode: DeliveryModeChannel,
+				DigestMinute: 20 * 60,
+			}
+		}
+	}
+
+	return prefs, nil
+}
+
+// SetDeliveryMode changes how the user will receive new episodes
+func (srv *PreferencesService) SetDeliveryMode(ctx context.Context, userID uint64, mode DeliveryMode) error {
+	return srv.updateDelivery(ctx, userID, func(pref *UserPreference) {
+		pref.DeliveryMode = mode
+	})
+}
+
+// SetReminderLeadTime changes how long before an episode airs the user is reminded about it. A lead
+// time of 0 turns reminders off
+func (srv *PreferencesService) SetReminderLeadTime(ctx context.Context, userID uint64, lead time.Duration) error {
+	return srv.update(ctx, userID, func(pref *UserPreference) {
+		pref.ReminderLeadMinutes = int(lead / time.Minute)
+	})
+}
+
+// SetDigestFrequency changes how often the user is sent a digest of new episodes. Turning digests on or off
+// can change where the user receives new episodes the same as changing delivery modes
+func (srv *PreferencesService) SetDigestFrequency(ctx context.Context, userID uint64, freq DigestFrequency) error {
+	return srv.updateDelivery(ctx, userID, func(pref *UserPreference) {
+		if pref.DigestFrequency == DigestFrequencyOff {
+			pref.DigestLastSentAt = NewNull(srv.clock.Now(), true)
+		}
+
+		pref.DigestFrequency = freq
+	})
+}
+
+// SetDigestTime changes the time of day, in minutes after midnight, the user's digest is sent at
+func (srv *PreferencesService) SetDigestTime(ctx context.Context, userID uint64, minute int) error {
+	return srv.update(ctx, userID, func(pref *UserPreference) {
+		pref.DigestMinute = minute
+	})
+}
+
+// SetDigestWeekday changes the day of the week the user's weekly digest is sent on
+func (srv *PreferencesService) SetDigestWeekday(ctx context.Context, userID uint64, weekday time.Weekday) error {
+	return srv.update(ctx, userID, func(pref *UserPreference) {
+		pref.DigestWeekday = weekday
+	})
+}
+
+// SetDigestTimezone changes the timezone the user's digest time is in
+func (srv *PreferencesService) SetDigestTimezone(ctx context.Context, userID uint64, tz string) error {
+	if _, err := time.LoadLocation(tz); err != nil {
+		return err
+	}
+
+	return srv.update(ctx, userID, func(pref *UserPreference) {
+		pref.DigestTimezone = tz
+	})
+}
+
+// MarkDigestSent records when the user was last sent a digest
+func (srv *PreferencesService) MarkDigestSent(ctx context.Context, userID uint64, at time.Time) error {
+	return srv.update(ctx, userID, func(pref *UserPreference) {
+		pref.DigestLastSentAt = NewNull(at, true)
+	})
+}
+
+// updateDelivery updates the user's preferences with fn and starts delivering episodes from now wherever the user
+// didn't receive them before so they aren't flooded with old episodes. Where the user already received episodes
+// is left alone so nothing that's still owed there is lost
+func (srv *PreferencesService) updateDelivery(ctx context.Context, userID uint64, fn func(*UserPreference)) error {
+	return srv.update(ctx, userID, func(pref *UserPreference) {
+		inChannel, byDM := pref.ReceivesInChannel(), pref.ReceivesDMs()
+		fn(pref)
+
+		now := srv.clock.Now()
+		if !inChannel && pref.ReceivesInChannel() {
+			pref.ChannelDeliveryStartedAt = now
+		}
+		if !byDM && pref.ReceivesDMs() {
+			pref.DMDeliveryStartedAt = now
+		}
 	})
 }
+
+func (srv *PreferencesService) update(ctx context.Context, userID uint64, fn func(*UserPreference)) error {
+	prefs, err := srv.GetPreferencesForUsers(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	pref := prefs[userID]
+	fn(pref)
+
+	return srv.PreferencesRepo.Upsert(ctx, pref)
+}
+
+type GuildsService struct {
+	*GuildsRepo
+	clock clock.Clock
+}
+
+func NewGuildsService(gr *GuildsRepo, c clock.Clock) *GuildsService {
+	return &GuildsService{
+		GuildsRepo: gr,
+		clock:      c,
+	}
+}
+
+// AdoptLegacySubscriptions moves subscriptions made before the bot supported multiple guilds into the guild
+// provided and returns how many were moved. The guild's notifications channel is set to the channel provided if
+// it doesn't have one yet. Nothing is changed when there are no legacy subscriptions
+func (srv *GuildsService) AdoptLegacySubscriptions(ctx context.Context, guildID, channelID uint64) (int64, error) {
+	n, err := srv.CountLegacySubscriptions(ctx)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	guilds, err := srv.GetGuildsByIDs(ctx, guildID)
+	if err != nil {
+		return 0, err
+	}
+	if g, ok := guilds[guildID]; channelID != 0 && (!ok || g.NotificationsChannelID == 0) {
+		if err = srv.SetNotificationsChannel(ctx, guildID, channelID); err != nil {
+			return 0, err
+		}
+	}
+
+	return srv.ClaimLegacySubscriptions(ctx, guildID)
+}
+
+// SetNotificationsChannel sets the channel new episodes are posted to in the guild
+func (srv *GuildsService) SetNotificationsChannel(ctx context.Context, guildID, channelID uint64) error {
+	return srv.GuildsRepo.Upsert(ctx, &Guild{
+		ID:                        guildID,
+		NotificationsChannelID:    channelID,
+		NotificationsChannelSetAt: srv.clock.Now(),
+	})
+}
+
+type RemindersService struct {
+	remindersRepo *RemindersRepo
+	seriesRepo    SeriesRepo
+	subsSrv       *SubscriptionsService
+	prefsSrv      *PreferencesService
+	discord       utils.DiscordSender
+	clock         clock.Clock
+}
+
+func NewRemindersService(
+	rr *RemindersRepo,
+	sr SeriesRepo,
+	ss *SubscriptionsService,
+	ps *PreferencesService,
+	d utils.DiscordSender,
+	c clock.Clock,
+) *RemindersService {
+	return &RemindersService{
+		remindersRepo: rr,
+		seriesRepo:    sr,
+		subsSrv:       ss,
+		prefsSrv:      ps,
+		discord:       d,
+		clock:         c,
+	}
+}
+
+// SendReminders sends a direct message to every subscriber that wants to be reminded about an upcoming episode
+// once the episode is within their reminder lead time. Each user is only reminded about an episode once
+func (srv *RemindersService) SendReminders(ctx context.Context) error {
+	series, err := srv.seriesRepo.GetSeriesWithNextEpisode(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := srv.clock.Now()
+	for _, s := range series {
+		details := s.Data.V
+		episode := details.NextEpisodeToAir
+		airsAt := s.NextEpisodeAirDate.V
+		if episode == nil || !airsAt.After(now) {
+			continue
+		}
+		logger := slog.With("series_id", s.ID, "season", episode.SeasonNumber, "episode", episode.EpisodeNumber)
+
+		subscriberIDs, err := srv.subsSrv.GetAllSubscribedToSeries(ctx, s.ID)
+		if err != nil {
+			return err
+		}
+		prefs, err := srv.prefsSrv.GetPreferencesForUsers(ctx, subscriberIDs...)
+		if err != nil {
+			return err
+		}
+		remindedIDs, err := srv.remindersRepo.GetRemindedUserIDs(ctx, episode.EpisodeNumber, episode.SeasonNumber, s.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, userID := range subscriberIDs {
+			lead := prefs[userID].ReminderLeadTime()
+			if lead == 0 || airsAt.Sub(now) > lead || slices.Contains(remindedIDs, userID) {
+				continue
+			}
+
+			if err = srv.sendReminder(ctx, userID, details, episode, airsAt); err != nil {
+				logger.ErrorContext(ctx, "Failed to send reminder", "user_id", userID, "error", err)
+				continue
+			}
+			logger.InfoContext(ctx, "Sent reminder", "user_id", userID)
+		}
+	}
+
+	return nil
+}
+
+func (srv *RemindersService) sendReminder(
+	ctx context.Context,
+	userID uint64,
+	series *moviedb.SeriesDetails,
+	episode *moviedb.PartialEpisodeDetails,
+	airsAt time.Time,
+) error {
+	ch, err := srv.discord.UserChannelCreate(strconv.FormatUint(userID, 10), discordgo.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	_, err = srv.discord.ChannelMessageSendComplex(ch.ID, &discordgo.MessageSend{
+		Embed: srv.makeEmbedForReminder(series, episode, airsAt),
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return srv.remindersRepo.Insert(ctx, &Reminder{
+		SeriesID: series.ID,
+		Season:   episode.SeasonNumber,
+		Episode:  episode.EpisodeNumber,
+		UserID:   userID,
+		SentAt:   srv.clock.Now(),
+	})
+}
+
+// airDateTimestamp returns the Unix time of noon UTC on the day an episode airs. TMDB only has the date episodes
+// air on so noon UTC is used to have Discord show the same date in as many time zones as possible
+func airDateTimestamp(airsAt time.Time) int64 {
+	return time.Date(airsAt.Year(), airsAt.Month(), airsAt.Day(), 12, 0, 0, 0, time.UTC).Unix()
+}
+
+func (RemindersService) makeEmbedForReminder(
+	series *moviedb.SeriesDetails,
+	episode *moviedb.PartialEpisodeDetails,
+	airsAt time.Time,
+) *discordgo.MessageEmbed {
+	title := episode.Name
+	if title == "" {
+		title = fmt.Sprintf("Episode %d", episode.EpisodeNumber)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Season",
+				Value:  strconv.FormatInt(int64(episode.SeasonNumber), 10),
+				Inline: true,
+			},
+			{
+				Name:   "Episode",
+				Value:  strconv.FormatInt(int64(episode.EpisodeNumber), 10),
+				Inline: true,
+			},
+			{
+				Name:   "Airs",
+				Value:  fmt.Sprintf("<t:%d:D>", airDateTimestamp(airsAt)),
+				Inline: true,
+			},
+		},
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: series.Name,
+		},
+		Title:       title,
+		Description: episode.Overview,
+		Color:       0x0c5460,
+	}
+
+	if episode.Runtime > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Runtime",
+			Value:  HumanDuration(time.Minute * time.Duration(episode.Runtime)),
+			Inline: true,
+		})
+	}
+
+	if series.PosterPath != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
+			Width: 300,
+			URL:   fmt.Sprintf("https://image.tmdb.org/t/p/w300/%s", series.PosterPath),
+		}
+	}
+
+	if series.Homepage != "" {
+		embed.Author.URL = series.Homepage
+	}
+
+	return embed
+}
+
+type DigestService struct {
+	digestRepo *DigestRepo
+	seriesRepo SeriesRepo
+	prefsSrv   *PreferencesService
+	discord    utils.DiscordSender
+	clock      clock.Clock
+}
+
+func NewDigestService(
+	dr *DigestRepo,
+	sr SeriesRepo,
+	ps *PreferencesService,
+	d utils.DiscordSender,
+	c clock.Clock,
+) *DigestService {
+	return &DigestService{
+		digestRepo: dr,
+		seriesRepo: sr,
+		prefsSrv:   ps,
+		discord:    d,
+		clock:      c,
+	}
+}
+
+// Queue adds the episode to the next digest of the user the notification is for
+func (srv *DigestService) Queue(ctx context.Context, series *moviedb.SeriesDetails, episode *moviedb.EpisodeDetails, noti *Notification) error {
+	return srv.digestRepo.InsertWithNotification(ctx, &DigestItem{
+		UserID:      noti.UserID,
+		SeriesID:    series.ID,
+		Season:      episode.SeasonNumber,
+		Episode:     episode.EpisodeNumber,
+		EpisodeName: episode.Name,
+		Runtime:     episode.Runtime,
+		AirDate:     episode.AirDate,
+		CreatedAt:   srv.clock.Now(),
+	}, noti)
+}
+
+// SendDueDigests sends a digest to every user whose digest is due. Digests that fail to send are left
+// queued and retried the next time this is called
+func (srv *DigestService) SendDueDigests(ctx context.Context) error {
+	prefs, err := srv.prefsSrv.GetUsersWithDigests(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := srv.clock.Now()
+	for _, pref := range prefs {
+		due := pref.LastDigestDueAt(now)
+		if pref.DigestLastSentAt.Valid && !pref.DigestLastSentAt.V.Before(due) {
+			continue
+		}
+		logger := slog.With("user_id", pref.UserID, "frequency", pref.DigestFrequency)
+
+		items, err := srv.digestRepo.GetUserItems(ctx, pref.UserID)
+		if err != nil {
+			return err
+		}
+
+		if len(items) > 0 {
+			if err = srv.sendDigest(ctx, pref, items); err != nil {
+				logger.ErrorContext(ctx, "Failed to send digest", "error", err)
+				continue
+			}
+
+			if err = srv.digestRepo.DeleteItems(ctx, items); err != nil {
+				return err
+			}
+			logger.InfoContext(ctx, "Sent digest", "episodes", len(items))
+		}
+
+		if err = srv.prefsSrv.MarkDigestSent(ctx, pref.UserID, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (srv *DigestService) sendDigest(ctx context.Context, pref *UserPreference, items []*DigestItem) error {
+	seriesNames := map[uint64]string{}
+	for _, item := range items {
+		if _, ok := seriesNames[item.SeriesID]; ok {
+			continue
+		}
+
+		seriesNames[item.SeriesID] = fmt.Sprintf("Series %d", item.SeriesID)
+		if s, err := srv.seriesRepo.GetSeriesByID(ctx, item.SeriesID); err == nil {
+			seriesNames[item.SeriesID] = s.Data.V.Name
+		}
+	}
+
+	ch, err := srv.discord.UserChannelCreate(strconv.FormatUint(pref.UserID, 10), discordgo.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	_, err = srv.discord.ChannelMessageSendComplex(ch.ID, &discordgo.MessageSend{
+		Embed: srv.makeEmbedForDigest(pref.DigestFrequency, items, seriesNames),
+	}, discordgo.WithContext(ctx))
+	return err
+}
+
+// makeEmbedForDigest makes a single embed listing every episode in the digest grouped by series. Each
+// episode is summarised using the same fields as the embed sent for a single episode
+func (DigestService) makeEmbedForDigest(freq DigestFrequency, items []*DigestItem, seriesNames map[uint64]string) *discordgo.MessageEmbed {
+	const maxFields = 25
+	const maxValueLen = 1024
+
+	fields := []*discordgo.MessageEmbedField{}
+	fieldsBySeries := map[uint64]*discordgo.MessageEmbedField{}
+	for _, item := range items {
+		field := fieldsBySeries[item.SeriesID]
+		if field == nil {
+			field = &discordgo.MessageEmbedField{Name: seriesNames[item.SeriesID]}
+			fieldsBySeries[item.SeriesID] = field
+			fields = append(fields, field)
+		}
+
+		line := strings.Join(utils.MapSlice(makeEpisodeFields(item.Season, item.Episode, item.Runtime), func(f *discordgo.MessageEmbedField, _ int) string {
+			return fmt.Sprintf("%s %s", f.Name, f.Value)
+		}), " · ")
+		if item.EpisodeName != "" {
+			line += " — " + item.EpisodeName
+		}
+		if len(field.Value)+len(line)+1 <= maxValueLen {
+			field.Value = strings.TrimPrefix(field.Value+"\n"+line, "\n")
+		}
+	}
+
+	if len(fields) > maxFields {
+		more := len(fields) - maxFields + 1
+		fields = append(fields[:maxFields-1], &discordgo.MessageEmbedField{
+			Name:  "And more",
+			Value: fmt.Sprintf("%d more series had new episodes", more),
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Your %s digest", freq),
+		Description: fmt.Sprintf("%d new episode(s) aired since your last digest", len(items)),
+		Fields:      fields,
+		Color:       0x0c5460,
+	}
+}
+
+// maxOutboxAttempts is how many times delivering an item is attempted before giving up. The retry delay is capped
+// at 6 hours so this gives up after about a day
+const maxOutboxAttempts = 12
+
+type OutboxService struct {
+	outboxRepo *OutboxRepo
+	guildsSrv  *GuildsService
+	discord    utils.DiscordSender
+	metrics    *Metrics
+	clock      clock.Clock
+	wake       chan struct{}
+}
+
+func NewOutboxService(or *OutboxRepo, gs *GuildsService, d utils.DiscordSender, m *Metrics, c clock.Clock) *OutboxService {
+	return &OutboxService{
+		outboxRepo: or,
+		guildsSrv:  gs,
+		discord:    d,
+		metrics:    m,
+		clock:      c,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds the items to the outbox and wakes the dispatcher so they're delivered right away
+func (srv *OutboxService) Enqueue(ctx context.Context, items ...*OutboxItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	now := srv.clock.Now()
+	for _, item := range items {
+		item.NextAttemptAt = now
+		item.CreatedAt = now
+	}
+	if err := srv.outboxRepo.Insert(ctx, items...); err != nil {
+		return err
+	}
+
+	select {
+	case srv.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// GetQueuedTargets returns all the targets the episode is waiting to be delivered to
+func (srv *OutboxService) GetQueuedTargets(ctx context.Context, episode, season int, seriesID uint64) ([]NotificationTarget, error) {
+	return srv.outboxRepo.GetQueuedTargets(ctx, episode, season, seriesID)
+}
+
+// RunDispatcher delivers the items in the outbox until the context is cancelled. The outbox is checked every
+// interval and whenever items are enqueued. Items are only delivered while held returns true so only one
+// instance delivers them, the context it returns is used for the delivery
+func (srv *OutboxService) RunDispatcher(ctx context.Context, interval time.Duration, held func() (context.Context, bool)) {
+	ticker := srv.clock.Ticker(interval)
+	defer ticker.Stop()
+
+	for {
+		if heldCtx, ok := held(); ok {
+			if err := srv.Dispatch(heldCtx); err != nil {
+				slog.ErrorContext(ctx, "Error occurred while dispatching outbox", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-srv.wake:
+		}
+	}
+}
+
+// Dispatch attempts to deliver every item in the outbox that is due. Items for the same series and target are
+// sent together in messages of up to 10 embeds. Items that fail to be delivered are retried with a backoff until
+// they've been attempted maxOutboxAttempts times or Discord says they can never be delivered
+func (srv *OutboxService) Dispatch(ctx context.Context) error {
+	items, err := srv.outboxRepo.GetItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	type batchKey struct {
+		NotificationTarget
+		SeriesID uint64
+	}
+	now := srv.clock.Now()
+	keys := []batchKey{}
+	batches := map[batchKey][]*OutboxItem{}
+	for _, item := range items {
+		if item.DeadAt.Valid || item.NextAttemptAt.After(now) {
+			continue
+		}
+
+		key := batchKey{item.NotificationTarget, item.SeriesID}
+		if batches[key] == nil {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], item)
+	}
+
+	for _, key := range keys {
+		batch := utils.NewBatcher(10, func(items []*OutboxItem) error {
+			return srv.deliver(ctx, items)
+		})
+		for _, item := range batches[key] {
+			if err := batch.Add(item); err != nil {
+				return err
+			}
+		}
+		if err := batch.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver sends the items to their target in a single message and records that the target was notified. Items
+// for guilds are posted in the guild's notifications channel mentioning the item's users and items for users are
+// sent as a direct message. Only failing to update the outbox is returned as an error
+func (srv *OutboxService) deliver(ctx context.Context, items []*OutboxItem) error {
+	target := items[0].NotificationTarget
+	logger := slog.With("guild_id", target.GuildID, "user_id", target.UserID, "series_id", items[0].SeriesID)
+
+	m, err := srv.send(ctx, items)
+	if err != nil && (items[0].Attempts+1 >= maxOutboxAttempts || isUndeliverable(err)) {
+		logger.ErrorContext(ctx, "Giving up on delivering outbox items",
+			"items", len(items),
+			"attempts", items[0].Attempts+1,
+			"error", err,
+		)
+
+		return srv.outboxRepo.MarkDead(ctx, items, srv.clock.Now(), err.Error())
+	} else if err != nil {
+		delay := outboxRetryDelay(items[0].Attempts, err)
+		logger.ErrorContext(ctx, "Failed to deliver outbox items",
+			"items", len(items),
+			"attempts", items[0].Attempts+1,
+			"retry_in", delay.String(),
+			"error", err,
+		)
+
+		return srv.outboxRepo.MarkFailed(ctx, items, srv.clock.Now().Add(delay), err.Error())
+	}
+	srv.metrics.ObserveNotificationsSent(target, len(items))
+
+	messageID, err := strconv.ParseUint(m.ID, 10, 64)
+	if err != nil {
+		return err
+	}
+	channelID, err := strconv.ParseUint(m.ChannelID, 10, 64)
+	if err != nil {
+		return err
+	}
+	now := srv.clock.Now()
+	notis := utils.MapSlice(items, func(item *OutboxItem, i int) *Notification {
+		n := item.Notification()
+		n.DiscordChannelID = channelID
+		n.DiscordMessageID = messageID
+		n.EmbedIndex = i
+		n.CreatedAt = now
+		return n
+	})
+
+	return srv.outboxRepo.MarkDelivered(ctx, items, notis)
+}
+
+// send sends the embeds of the items to their target. Discord's rate limits are not retried so the items can
+// be attempted again after the time Discord asked for
+func (srv *OutboxService) send(ctx context.Context, items []*OutboxItem) (*discordgo.Message, error) {
+	channelID, err := srv.getChannelIDForTarget(ctx, items[0].NotificationTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	mentionIDs := []uint64{}
+	for _, item := range items {
+		for _, id := range item.MentionIDs.V {
+			if !slices.Contains(mentionIDs, id) {
+				mentionIDs = append(mentionIDs, id)
+			}
+		}
+	}
+	data := &discordgo.MessageSend{
+		Embeds: utils.MapSlice(items, func(item *OutboxItem, _ int) *discordgo.MessageEmbed {
+			return item.Embed.V
+		}),
+		Content: strings.Join(utils.MapSlice(mentionIDs, func(sID uint64, _ int) string {
+			return fmt.Sprintf("<@%d>", sID)
+		}), " "),
+	}
+
+	return srv.discord.ChannelMessageSendComplex(channelID, data,
+		discordgo.WithContext(ctx),
+		discordgo.WithRetryOnRatelimit(false),
+	)
+}
+
+// getChannelIDForTarget returns the ID of the channel messages for the target should be sent to
+func (srv *OutboxService) getChannelIDForTarget(ctx context.Context, target NotificationTarget) (string, error) {
+	if target.UserID != 0 {
+		ch, err := srv.discord.UserChannelCreate(strconv.FormatUint(target.UserID, 10), discordgo.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+
+		return ch.ID, nil
+	}
+
+	guilds, err := srv.guildsSrv.GetGuildsByIDs(ctx, target.GuildID)
+	if err != nil {
+		return "", err
+	} else if guilds[target.GuildID] == nil {
+		return "", fmt.Errorf("guild %d has no notifications channel", target.GuildID)
+	}
+
+	return strconv.FormatUint(guilds[target.GuildID].NotificationsChannelID, 10), nil
+}
+
+// isUndeliverable returns true if Discord rejected a message because the channel doesn't exist or the bot can't
+// send messages to it, like when a user has direct messages closed. Sending the message again won't succeed
+func isUndeliverable(err error) bool {
+	restErr := &discordgo.RESTError{}
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		return false
+	}
+
+	return restErr.Response.StatusCode == http.StatusForbidden || restErr.Response.StatusCode == http.StatusNotFound
+}
+
+// outboxRetryDelay returns how long to wait before delivering items again after an attempt failed. The
+// retry_after Discord responds with is used when rate limited otherwise the delay doubles with every attempt
+func outboxRetryDelay(attempts int, err error) time.Duration {
+	rateLimitErr := &discordgo.RateLimitError{}
+	restErr := &discordgo.RESTError{}
+	if errors.As(err, &rateLimitErr) && rateLimitErr.RateLimit != nil && rateLimitErr.TooManyRequests != nil {
+		return rateLimitErr.RetryAfter
+	} else if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusTooManyRequests {
+		tooManyRequests := discordgo.TooManyRequests{}
+		if json.Unmarshal(restErr.ResponseBody, &tooManyRequests) == nil && tooManyRequests.RetryAfter > 0 {
+			return tooManyRequests.RetryAfter
+		}
+	}
+
+	return min(time.Second*30<<min(attempts, 10), time.Hour*6)
+}
+
+// JobsStatus is how the scheduled jobs have been running. Healthy is true if the last scan for new episodes
+// finished within the scan window without an error
+type JobsStatus struct {
+	Healthy bool      `json:"healthy"`
+	Runs    []*JobRun `json:"runs"`
+}
+
+// ErrScanRunning is returned when a scan for new episodes is started while another one is still running
+var ErrScanRunning = errors.New("a scan for new episodes is already running")
+
+type JobsService struct {
+	jobRunsRepo *JobRunsRepo
+	seriesSrv   *SeriesService
+	metrics     *Metrics
+	scanWindow  time.Duration
+	clock       clock.Clock
+	startedAt   time.Time
+	scanning    sync.Mutex
+}
+
+func NewJobsService(jr *JobRunsRepo, ss *SeriesService, m *Metrics, scanWindow time.Duration, c clock.Clock) *JobsService {
+	return &JobsService{
+		jobRunsRepo: jr,
+		seriesSrv:   ss,
+		metrics:     m,
+		scanWindow:  scanWindow,
+		clock:       c,
+		startedAt:   c.Now(),
+	}
+}
+
+// FindNewEpisodes scans for new episodes and records the run along with how many TMDB requests it took. Only
+// one scan runs at a time so ErrScanRunning is returned without a run if one is already running
+func (srv *JobsService) FindNewEpisodes(ctx context.Context) (*JobRun, error) {
+	if !srv.scanning.TryLock() {
+		return nil, ErrScanRunning
+	}
+	defer srv.scanning.Unlock()
+
+	calls := &atomic.Int64{}
+	run := &JobRun{Job: JobRunFindNewEpisodes, StartedAt: srv.clock.Now()}
+	summary, err := srv.seriesSrv.FindNewEpisodes(moviedb.ContextWithRequestCounter(ctx, calls))
+
+	run.FinishedAt = srv.clock.Now()
+	run.DurationMS = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.SeriesChecked = summary.Checked
+	run.SeriesSkipped = summary.Skipped
+	run.SeriesFailed = summary.Failed
+	run.EpisodesNotified = summary.Episodes
+	run.TMDBCalls = calls.Load()
+	if err != nil {
+		run.Error = NewNull(err.Error(), true)
+		run.Failed = !errors.Is(err, ErrScanIncomplete)
+	}
+	srv.metrics.ObserveScan(run)
+
+	// Recording the run even if it was stopped because the bot is shutting down
+	if err := srv.jobRunsRepo.Insert(context.WithoutCancel(ctx), run); err != nil {
+		slog.ErrorContext(ctx, "Failed to record job run", "job", run.Job, "error", err)
+	}
+
+	return run, err
+}
+
+// GetStatus returns up to limit of the latest scans for new episodes and whether the bot is healthy
+func (srv *JobsService) GetStatus(ctx context.Context, limit uint64) (*JobsStatus, error) {
+	runs, err := srv.jobRunsRepo.GetLatest(ctx, JobRunFindNewEpisodes, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobsStatus{Healthy: srv.checkLatestScan(runs) == nil, Runs: runs}, nil
+}
+
+// CheckLastScan returns why the last scan for new episodes makes the bot unhealthy. Nil is returned if the last
+// scan finished within the scan window without failing as a whole
+func (srv *JobsService) CheckLastScan(ctx context.Context) error {
+	runs, err := srv.jobRunsRepo.GetLatest(ctx, JobRunFindNewEpisodes, 1)
+	if err != nil {
+		return err
+	}
+
+	return srv.checkLatestScan(runs)
+}
+
+// checkLatestScan does the same as CheckLastScan with the runs provided, latest first. Not having scanned yet is
+// only a problem once a scan window has passed since starting
+func (srv *JobsService) checkLatestScan(runs []*JobRun) error {
+	if len(runs) == 0 {
+		if since := srv.clock.Since(srv.startedAt); since >= srv.scanWindow {
+			return fmt.Errorf("no scan has finished since starting %s ago", since.Round(time.Second))
+		}
+		return nil
+	} else if runs[0].Failed {
+		return fmt.Errorf("last scan failed: %s", runs[0].Error.V)
+	} else if since := srv.clock.Since(runs[0].FinishedAt); since >= srv.scanWindow {
+		return fmt.Errorf("last scan finished %s ago", since.Round(time.Second))
+	}
+
+	return nil
+}
+
+// schedulerLease is the name of the lease held by the instance that runs the scheduled jobs
+const schedulerLease = "scheduler"
+
+// LeaseService makes sure only one instance of the bot runs the scheduled jobs when several are running at once,
+// like during a rolling deploy. Every instance tries to acquire the lease and the one holding it keeps renewing it
+type LeaseService struct {
+	leasesRepo *LeasesRepo
+	instanceID string
+	ttl        time.Duration
+	clock      clock.Clock
+
+	mu        sync.Mutex
+	expiresAt time.Time
+	heldCtx   context.Context
+	lose      context.CancelFunc
+}
+
+func NewLeaseService(lr *LeasesRepo, instanceID string, ttl time.Duration, c clock.Clock) *LeaseService {
+	return &LeaseService{
+		leasesRepo: lr,
+		instanceID: instanceID,
+		ttl:        ttl,
+		clock:      c,
+	}
+}
+
+// Renew acquires the lease if it's free or has expired and extends it if this instance already holds it. A lease
+// that can't be renewed because of an error is only given up once it expires since no one else can take it before
+func (srv *LeaseService) Renew(ctx context.Context) error {
+	now := srv.clock.Now()
+	held, err := srv.tryAcquire(ctx, now)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if err != nil && now.Before(srv.expiresAt) {
+		return err
+	} else if err == nil && held {
+		srv.expiresAt = now.Add(srv.ttl)
+		if srv.heldCtx == nil {
+			slog.InfoContext(ctx, "Acquired lease", "lease", schedulerLease, "instance_id", srv.instanceID)
+			srv.heldCtx, srv.lose = context.WithCancel(ctx)
+		}
+		return nil
+	}
+
+	if srv.heldCtx != nil {
+		slog.WarnContext(ctx, "Lost lease", "lease", schedulerLease, "instance_id", srv.instanceID)
+		srv.loseLocked()
+	}
+
+	return err
+}
+
+// Held returns a context that is cancelled as soon as the lease is lost. False is returned if this instance doesn't
+// hold the lease
+func (srv *LeaseService) Held() (context.Context, bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.heldCtx, srv.heldCtx != nil
+}
+
+// Guard returns a function that runs fn with the context from Held if this instance holds the lease and does
+// nothing otherwise
+func (srv *LeaseService) Guard(fn func(ctx context.Context)) func() {
+	return func() {
+		if ctx, ok := srv.Held(); ok {
+			fn(ctx)
+		}
+	}
+}
+
+// Release gives up the lease so another instance can take over without waiting for it to expire
+func (srv *LeaseService) Release(ctx context.Context) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.heldCtx == nil {
+		return nil
+	}
+	srv.loseLocked()
+
+	return srv.leasesRepo.Delete(ctx, schedulerLease, srv.instanceID)
+}
+
+// Run renews the lease every interval until the context is done and then releases it. The interval should be
+// well under the lease's TTL so a missed heartbeat doesn't lose the lease
+func (srv *LeaseService) Run(ctx context.Context, interval time.Duration) {
+	ticker := srv.clock.Ticker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := srv.Renew(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to renew lease", "lease", schedulerLease, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := srv.Release(context.WithoutCancel(ctx)); err != nil {
+				slog.ErrorContext(ctx, "Failed to release lease", "lease", schedulerLease, "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquire writes the lease for this instance if it's free, expired or already held by this instance. True is
+// returned if this instance holds the lease afterwards
+func (srv *LeaseService) tryAcquire(ctx context.Context, now time.Time) (bool, error) {
+	next := &Lease{
+		Name:        schedulerLease,
+		Holder:      srv.instanceID,
+		Term:        1,
+		HeartbeatAt: now,
+		ExpiresAt:   now.Add(srv.ttl),
+	}
+
+	current, err := srv.leasesRepo.Get(ctx, schedulerLease)
+	if errors.Is(err, sql.ErrNoRows) {
+		return srv.leasesRepo.Insert(ctx, next)
+	} else if err != nil {
+		return false, err
+	} else if current.Holder != srv.instanceID && now.Before(current.ExpiresAt) {
+		return false, nil
+	}
+
+	next.Term = current.Term + 1
+	return srv.leasesRepo.Update(ctx, next, current.Term)
+}
+
+func (srv *LeaseService) loseLocked() {
+	srv.lose()
+	srv.heldCtx, srv.lose = nil, nil
+	srv.expiresAt = time.Time{}
+}
+
+// HealthCheck is the result of one of the checks that decide whether the bot is ready
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness is whether the bot is ready along with the checks that decided it. The bot is only ready if every
+// check passed
+type Readiness struct {
+	Ready  bool           `json:"ready"`
+	Checks []*HealthCheck `json:"checks"`
+}
+
+// HealthService reports whether the bot is connected to Discord, can reach its database, has had every
+// migration applied and is finding new episodes
+type HealthService struct {
+	db              *sqlx.DB
+	jobsSrv         *JobsService
+	latestMigration int64
+	discordReady    atomic.Bool
+}
+
+func NewHealthService(db *sqlx.DB, js *JobsService, latestMigration int64) *HealthService {
+	return &HealthService{
+		db:              db,
+		jobsSrv:         js,
+		latestMigration: latestMigration,
+	}
+}
+
+// RegisterHandlers keeps track of whether the Discord gateway is ready. The gateway is ready once Discord sends
+// the Ready event and stays ready until the connection drops
+func (srv *HealthService) RegisterHandlers(s utils.DiscordSender) {
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Ready) {
+		srv.discordReady.Store(true)
+	})
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Resumed) {
+		srv.discordReady.Store(true)
+	})
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+		srv.discordReady.Store(false)
+	})
+}
+
+// Readiness runs every check and returns their results
+func (srv *HealthService) Readiness(ctx context.Context) *Readiness {
+	readiness := &Readiness{Ready: true}
+	check := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+
+		hc := &HealthCheck{Name: name, OK: true}
+		if err := fn(ctx); err != nil {
+			hc.OK = false
+			hc.Error = err.Error()
+			readiness.Ready = false
+		}
+		readiness.Checks = append(readiness.Checks, hc)
+	}
+
+	check("discord", func(ctx context.Context) error {
+		if !srv.discordReady.Load() {
+			return errors.New("gateway is not ready")
+		}
+		return nil
+	})
+	check("database", srv.db.PingContext)
+	check("migrations", srv.checkMigrations)
+	check("scan", srv.jobsSrv.CheckLastScan)
+
+	return readiness
+}
+
+func (srv *HealthService) checkMigrations(ctx context.Context) error {
+	version, err := goose.GetDBVersionContext(ctx, srv.db.DB)
+	if err != nil {
+		return err
+	} else if version < srv.latestMigration {
+		return fmt.Errorf("database is at migration %d but the latest is %d", version, srv.latestMigration)
+	}
+
+	return nil
+}
@@ -0,0 +1,2014 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/bwmarrin/discordgo"
+	"github.com/duke605/tv-bot/moviedb"
+	"github.com/duke605/tv-bot/moviedb/moviedbtest"
+	"github.com/duke605/tv-bot/utils"
+	"github.com/duke605/tv-bot/utils/discordtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNow is the time on the clock the services are created with
+var testNow = time.Date(2024, 1, 20, 12, 0, 0, 0, time.Local)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+func TestMain(m *testing.M) {
+	// Keeping the logs out of the working directory
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	goose.SetLogger(goose.NopLogger())
+
+	os.Exit(m.Run())
+}
+
+// newTestDB creates a migrated database that is removed when the test finishes
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	dir, err := setupGoose(db)
+	require.NoError(t, err)
+	require.NoError(t, goose.Up(db.DB, dir))
+
+	return db
+}
+
+// assertGolden asserts the value marshals to the JSON in testdata/golden/<name>.json. The file is written
+// instead when the tests are run with -update
+func assertGolden(t *testing.T, name string, v any) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(v, "", "  ")
+	require.NoError(t, err)
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, append(actual, '\n'), 0644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "run the tests with -update to create the golden file")
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+type seriesServiceFixture struct {
+	db      *sqlx.DB
+	tmdb    *moviedbtest.Server
+	discord *discordtest.Sender
+	clock   *clock.Mock
+	srv     *SeriesService
+}
+
+// newSeriesServiceFixture creates a series service backed by a fresh database and a fake TMDB seeded with a
+// returning series, a fake Discord session and a mock clock set to testNow. The guild the series is subscribed
+// to in has a notifications channel
+func newSeriesServiceFixture(t *testing.T) *seriesServiceFixture {
+	t.Helper()
+
+	db := newTestDB(t)
+	tmdb := moviedbtest.NewServer(t)
+	require.NoError(t, tmdb.LoadFixture("testdata/moviedb/returning_series.json"))
+	discord := discordtest.NewSender()
+	clk := clock.NewMock()
+	clk.Set(testNow)
+
+	prefsSrv := NewPreferencesService(NewPreferencesRepo(db), clk)
+	guildsSrv := NewGuildsService(NewGuildsRepo(db), clk)
+	seriesRepo := NewSQLSeriesRepo(db)
+	srv := NewSeriesService(
+		NewSQLNotificationsRepo(db),
+		NewSubscriptionsService(NewSQLSubscriptionsRepo(db), clk),
+		prefsSrv,
+		guildsSrv,
+		NewDigestService(NewDigestRepo(db), seriesRepo, prefsSrv, discord, clk),
+		NewOutboxService(NewOutboxRepo(db), guildsSrv, discord, NewMetrics(), clk),
+		discord,
+		tmdb.Client(),
+		seriesRepo,
+		NewWatermarksRepo(db),
+		4,
+		defaultPollSchedule,
+		clk,
+	)
+
+	return &seriesServiceFixture{db: db, tmdb: tmdb, discord: discord, clock: clk, srv: srv}
+}
+
+// newMemorySeriesServiceFixture creates a series service whose notifications, subscriptions and series are kept
+// in memory so no database is needed. The services that are backed by other repos are left out so only the parts
+// of the series service that don't use them can be tested
+func newMemorySeriesServiceFixture(t *testing.T) *seriesServiceFixture {
+	t.Helper()
+
+	tmdb := moviedbtest.NewServer(t)
+	require.NoError(t, tmdb.LoadFixture("testdata/moviedb/returning_series.json"))
+	clk := clock.NewMock()
+	clk.Set(testNow)
+	store := NewMemoryStore()
+
+	srv := NewSeriesService(
+		NewMemoryNotificationsRepo(store),
+		NewSubscriptionsService(NewMemorySubscriptionsRepo(store), clk),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		tmdb.Client(),
+		NewMemorySeriesRepo(store),
+		nil,
+		4,
+		defaultPollSchedule,
+		clk,
+	)
+
+	return &seriesServiceFixture{tmdb: tmdb, clock: clk, srv: srv}
+}
+
+// addSeriesCopy adds a copy of the returning series and its seasons to the fake TMDB under the ID provided
+func (f *seriesServiceFixture) addSeriesCopy(t *testing.T, id uint64) {
+	t.Helper()
+
+	b, err := os.ReadFile("testdata/moviedb/returning_series.json")
+	require.NoError(t, err)
+	fixture := &moviedbtest.Fixture{}
+	require.NoError(t, json.Unmarshal(b, fixture))
+	for _, series := range fixture.Series {
+		series.ID = id
+	}
+	for _, season := range fixture.Seasons {
+		season.SeriesID = id
+	}
+	fixture.ChangedIDs = nil
+	f.tmdb.Load(fixture)
+}
+
+func (f *seriesServiceFixture) subscribe(t *testing.T, guildID, seriesID, userID uint64, at time.Time) {
+	t.Helper()
+
+	ctx := context.Background()
+	require.NoError(t, f.srv.guildsSrv.Upsert(ctx, &Guild{
+		ID:                        guildID,
+		NotificationsChannelID:    guildID * 10,
+		NotificationsChannelSetAt: at,
+	}))
+	require.NoError(t, f.srv.subsSrv.Insert(ctx, &Subscription{
+		GuildID:   guildID,
+		SeriesID:  seriesID,
+		UserID:    userID,
+		CreatedAt: at,
+	}))
+}
+
+func (f *seriesServiceFixture) outbox(t *testing.T) []NotificationTarget {
+	t.Helper()
+
+	items, err := f.srv.outboxSrv.outboxRepo.GetItems(context.Background())
+	require.NoError(t, err)
+
+	return utils.MapSlice(items, func(item *OutboxItem, _ int) NotificationTarget {
+		assert.Equal(t, 1, item.Season)
+		assert.Equal(t, 2, item.Episode)
+		return item.NotificationTarget
+	})
+}
+
+func TestFindNewEpisodes(t *testing.T) {
+	subscribedAt := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name              string
+		arrange           func(t *testing.T, f *seriesServiceFixture)
+		expTargets        []NotificationTarget
+		expSubscriptions  int
+		expWatermarkMoved bool
+		expSummary        ScanSummary
+		expErr            bool
+	}{
+		{
+			name:              "queues episodes that aired since subscribing",
+			expTargets:        []NotificationTarget{{GuildID: 1}},
+			expSubscriptions:  1,
+			expWatermarkMoved: true,
+			expSummary:        ScanSummary{Checked: 1, Notified: 1, Episodes: 1},
+		},
+		{
+			name: "does not queue episodes that were already delivered",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				require.NoError(t, f.srv.notiRepo.InsertMany(context.Background(), []*Notification{{
+					Episode:            2,
+					Season:             1,
+					SeriesID:           100,
+					NotificationTarget: NotificationTarget{GuildID: 1},
+					Complete:           true,
+				}}))
+			},
+			expTargets:        []NotificationTarget{},
+			expSubscriptions:  1,
+			expWatermarkMoved: true,
+			expSummary:        ScanSummary{Checked: 1},
+		},
+		{
+			name: "queues direct messages for users that want them",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				require.NoError(t, f.srv.prefsSrv.Upsert(context.Background(), &UserPreference{
+					UserID:              10,
+					DeliveryMode:        DeliveryModeDM,
+					DMDeliveryStartedAt: subscribedAt,
+					DigestMinute:        20 * 60,
+				}))
+			},
+			expTargets:        []NotificationTarget{{UserID: 10}},
+			expSubscriptions:  1,
+			expWatermarkMoved: true,
+			expSummary:        ScanSummary{Checked: 1, Notified: 1, Episodes: 1},
+		},
+		{
+			name: "keeps posting in the channel for users that start receiving direct messages too",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				require.NoError(t, f.srv.prefsSrv.SetDeliveryMode(context.Background(), 10, DeliveryModeBoth))
+			},
+			expTargets:        []NotificationTarget{{GuildID: 1}},
+			expSubscriptions:  1,
+			expWatermarkMoved: true,
+			expSummary:        ScanSummary{Checked: 1, Notified: 1, Episodes: 1},
+		},
+		{
+			name: "unsubscribes everyone from series deleted from TMDB",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				f.tmdb.RemoveSeries(100)
+			},
+			expTargets:        []NotificationTarget{},
+			expSubscriptions:  0,
+			expWatermarkMoved: true,
+			expSummary:        ScanSummary{Checked: 1},
+		},
+		{
+			name: "fails series TMDB fails to respond with",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				f.tmdb.InjectFault(moviedbtest.Fault{Path: "/tv/100", Status: http.StatusInternalServerError})
+			},
+			expTargets:        []NotificationTarget{},
+			expSubscriptions:  1,
+			expWatermarkMoved: false,
+			expSummary:        ScanSummary{Failed: 1},
+			expErr:            true,
+		},
+		{
+			name: "stops when TMDB rejects the access token",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				f.tmdb.InjectFault(moviedbtest.Fault{Status: http.StatusUnauthorized})
+			},
+			expTargets:        []NotificationTarget{},
+			expSubscriptions:  1,
+			expWatermarkMoved: false,
+			expErr:            true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			f.subscribe(t, 1, 100, 10, subscribedAt)
+			if test.arrange != nil {
+				test.arrange(t, f)
+			}
+
+			// Acting
+			summary, err := f.srv.FindNewEpisodes(ctx)
+
+			// Asserting
+			if test.expErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expSummary, summary)
+			assert.ElementsMatch(t, test.expTargets, f.outbox(t))
+			subs, err := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 100)
+			require.NoError(t, err)
+			assert.Len(t, subs, test.expSubscriptions)
+			_, err = f.srv.watermarkRepo.Get(ctx, tvChangesWatermark)
+			assert.Equal(t, test.expWatermarkMoved, err == nil)
+		})
+	}
+}
+
+func TestFindNewEpisodesChecksEverySeriesWhenOneFails(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	subscribedAt := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	for _, id := range []uint64{100, 101, 102, 103, 104} {
+		f.addSeriesCopy(t, id)
+		f.subscribe(t, 1, id, 10, subscribedAt)
+	}
+	f.tmdb.InjectFault(moviedbtest.Fault{Path: "/tv/102", Status: http.StatusInternalServerError})
+
+	// Acting
+	summary, err := f.srv.FindNewEpisodes(ctx)
+
+	// Asserting
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "series 102")
+	assert.Equal(t, ScanSummary{Checked: 4, Notified: 4, Failed: 1, Episodes: 4}, summary)
+	items, err := f.srv.outboxSrv.outboxRepo.GetItems(ctx)
+	require.NoError(t, err)
+	seriesIDs := utils.MapSlice(items, func(item *OutboxItem, _ int) uint64 { return item.SeriesID })
+	assert.ElementsMatch(t, []uint64{100, 101, 103, 104}, seriesIDs)
+	_, err = f.srv.watermarkRepo.Get(ctx, tvChangesWatermark)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestFindNewEpisodesChecksEverySeriesWhenOneIsDeleted(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	// Checking one series at a time so the series is unsubscribed from before the next page is read
+	f.srv.scanWorkers = 1
+	subscribedAt := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	seriesIDs := []uint64{}
+	for id := uint64(100); id < 112; id++ {
+		if id != 100 {
+			f.addSeriesCopy(t, id)
+		}
+		f.subscribe(t, 1, id, 10, subscribedAt)
+		seriesIDs = append(seriesIDs, id)
+	}
+	f.tmdb.RemoveSeries(101)
+
+	// Acting
+	summary, err := f.srv.FindNewEpisodes(ctx)
+
+	// Asserting
+	require.NoError(t, err)
+	assert.Equal(t, ScanSummary{Checked: 12, Notified: 11, Episodes: 11}, summary)
+	items, err := f.srv.outboxSrv.outboxRepo.GetItems(ctx)
+	require.NoError(t, err)
+	queued := utils.MapSlice(items, func(item *OutboxItem, _ int) uint64 { return item.SeriesID })
+	assert.ElementsMatch(t, slices.DeleteFunc(seriesIDs, func(id uint64) bool { return id == 101 }), queued)
+	subs, err := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 101)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestFindNewEpisodesOnlyFetchesSeriesThatChangedOnTMDB(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	_, err := f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	f.clock.Add(time.Hour * 24 * 8)
+	f.tmdb.SetChangedIDs(101)
+	series, err := f.srv.seriesRepo.GetSeriesByID(ctx, 100)
+	require.NoError(t, err)
+	require.False(t, f.clock.Now().Before(series.NextCheckAt.V), "series should be due to be checked")
+	requested := len(f.tmdb.Requests())
+
+	// Acting
+	summary, err := f.srv.FindNewEpisodes(ctx)
+
+	// Asserting
+	require.NoError(t, err)
+	assert.Equal(t, ScanSummary{Skipped: 1}, summary)
+	assert.Equal(t, []string{"/tv/changes"}, f.tmdb.Requests()[requested:])
+}
+
+func TestFindNewEpisodesDoesNotQueueEpisodesTwice(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+
+	// Acting
+	_, err := f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	_, err = f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+
+	// Asserting
+	assert.Equal(t, []NotificationTarget{{GuildID: 1}}, f.outbox(t))
+}
+
+func TestFindNewEpisodesOnlyChecksSeasonsSinceLastFetch(t *testing.T) {
+	tests := []struct {
+		name              string
+		cached            bool
+		expSeasonsChecked []string
+	}{
+		{
+			name:              "checks every season since subscribing when the series was never fetched",
+			expSeasonsChecked: []string{"/tv/100/season/1", "/tv/100/season/2"},
+		},
+		{
+			name:              "only asks for the seasons airing since the series was last fetched",
+			cached:            true,
+			expSeasonsChecked: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			f.subscribe(t, 1, 100, 10, time.Date(2023, 6, 1, 0, 0, 0, 0, time.Local))
+
+			// Moving the fixture's episodes into a second season so the first season is one TMDB doesn't have
+			b, err := os.ReadFile("testdata/moviedb/returning_series.json")
+			require.NoError(t, err)
+			fixture := &moviedbtest.Fixture{}
+			require.NoError(t, json.Unmarshal(b, fixture))
+			fixture.Series[0].Seasons = []moviedb.PartialSeasonDetails{
+				{SeasonNumber: 1, AirDate: "2023-01-01"},
+				{SeasonNumber: 2, AirDate: "2024-01-03"},
+			}
+			fixture.Series[0].LastEpisodeToAir.SeasonNumber = 2
+			fixture.Seasons[0].SeasonNumber = 2
+			for i := range fixture.Seasons[0].Episodes {
+				fixture.Seasons[0].Episodes[i].SeasonNumber = 2
+			}
+			f.tmdb.RemoveSeries(100)
+			f.tmdb.Load(fixture)
+			if test.cached {
+				f.clock.Set(testNow.Add(-time.Hour))
+				_, err := f.srv.RefreshSeriesDetails(ctx, 100)
+				require.NoError(t, err)
+				f.clock.Set(testNow)
+			}
+
+			// Acting
+			_, err = f.srv.FindNewEpisodes(ctx)
+
+			// Asserting
+			require.NoError(t, err)
+			seasonRequests := utils.Filter(f.tmdb.Requests(), func(path string) bool {
+				return strings.Contains(path, "/season/")
+			})
+			assert.Equal(t, test.expSeasonsChecked, seasonRequests)
+			items, err := f.srv.outboxSrv.outboxRepo.GetItems(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []int{1, 2}, utils.MapSlice(items, func(item *OutboxItem, _ int) int {
+				assert.Equal(t, 2, item.Season)
+				return item.Episode
+			}))
+		})
+	}
+}
+
+func TestCanSkipCheckForNewEpisodes(t *testing.T) {
+	now := testNow
+	epoch := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		model    func(s *Series)
+		arrange  func(t *testing.T, f *seriesServiceFixture)
+		notified bool
+		changed  *seriesChanges
+		exp      bool
+	}{
+		{
+			name: "cannot skip when the last episode was not delivered",
+			exp:  false,
+		},
+		{
+			name:     "skips when the next episode is more than an hour away",
+			notified: true,
+			exp:      true,
+		},
+		{
+			name: "skips when a user started receiving direct messages after the last episode aired",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				require.NoError(t, f.srv.prefsSrv.SetDeliveryMode(context.Background(), 10, DeliveryModeBoth))
+			},
+			notified: true,
+			exp:      true,
+		},
+		{
+			name: "cannot skip when a user receiving direct messages was not sent the last episode",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				require.NoError(t, f.srv.prefsSrv.Upsert(context.Background(), &UserPreference{
+					UserID:              10,
+					DeliveryMode:        DeliveryModeBoth,
+					DMDeliveryStartedAt: epoch,
+				}))
+			},
+			notified: true,
+			exp:      false,
+		},
+		{
+			name: "skips when the last episode aired before subscribing",
+			model: func(s *Series) {
+				s.Data.V.LastEpisodeToAir.AirDate = "2024-01-03"
+			},
+			exp: true,
+		},
+		{
+			name: "cannot skip when the next episode airs within the hour",
+			model: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(now.Add(time.Minute*30), true)
+			},
+			notified: true,
+			exp:      false,
+		},
+		{
+			name: "skips when there is no next episode and the series was fetched this week",
+			model: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(time.Time{}, false)
+			},
+			notified: true,
+			exp:      true,
+		},
+		{
+			name: "cannot skip when there is no next episode and the series was fetched over a week ago",
+			model: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(time.Time{}, false)
+				s.LastFetchedAt = now.Add(-time.Hour * 24 * 8)
+			},
+			notified: true,
+			exp:      false,
+		},
+		{
+			name: "skips series that did not change on TMDB even when their check is due",
+			model: func(s *Series) {
+				s.NextCheckAt = NewNull(now, true)
+			},
+			notified: true,
+			changed:  &seriesChanges{since: now.Add(-time.Hour), ids: map[uint64]bool{}},
+			exp:      true,
+		},
+		{
+			name:     "cannot skip series that changed on TMDB",
+			notified: true,
+			changed:  &seriesChanges{since: now.Add(-time.Hour), ids: map[uint64]bool{100: true}},
+			exp:      false,
+		},
+		{
+			name: "uses the schedule for series subscribed to since the changes on TMDB were last checked",
+			model: func(s *Series) {
+				s.NextCheckAt = NewNull(now, true)
+			},
+			notified: true,
+			changed:  &seriesChanges{since: epoch.Add(-time.Hour), ids: map[uint64]bool{}},
+			exp:      false,
+		},
+		{
+			name: "cannot skip series cached before they were subscribed to since the changes on TMDB were last checked",
+			model: func(s *Series) {
+				s.LastFetchedAt = epoch.Add(-time.Hour * 24)
+			},
+			notified: true,
+			changed:  &seriesChanges{since: epoch.Add(-time.Hour), ids: map[uint64]bool{}},
+			exp:      false,
+		},
+		{
+			name: "skips until the scheduled check",
+			model: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(now.Add(time.Minute*30), true)
+				s.NextCheckAt = NewNull(now.Add(time.Minute), true)
+			},
+			notified: true,
+			exp:      true,
+		},
+		{
+			name: "cannot skip once the scheduled check is due",
+			model: func(s *Series) {
+				s.NextCheckAt = NewNull(now, true)
+			},
+			notified: true,
+			exp:      false,
+		},
+		{
+			name: "cannot skip when the interval set by an admin has passed",
+			model: func(s *Series) {
+				s.CheckIntervalMS = NewNull(time.Hour.Milliseconds(), true)
+			},
+			notified: true,
+			exp:      false,
+		},
+		{
+			name: "cannot skip series whose next episode aired even if it did not change on TMDB",
+			model: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(now.Add(-time.Hour), true)
+			},
+			notified: true,
+			changed:  &seriesChanges{since: now.Add(-time.Hour), ids: map[uint64]bool{}},
+			exp:      false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			f.subscribe(t, 1, 100, 10, epoch)
+			if test.arrange != nil {
+				test.arrange(t, f)
+			}
+			target := NotificationTarget{GuildID: 1}
+			model := &Series{
+				ID:                 100,
+				LastFetchedAt:      now.Add(-time.Hour),
+				NextEpisodeAirDate: NewNull(now.Add(time.Hour*24*2), true),
+			}
+			model.Data.V = &moviedb.SeriesDetails{
+				ID: 100,
+				LastEpisodeToAir: &moviedb.PartialEpisodeDetails{
+					AirDate:       "2024-01-17",
+					EpisodeNumber: 2,
+					SeasonNumber:  1,
+				},
+			}
+			if test.model != nil {
+				test.model(model)
+			}
+			if test.notified {
+				require.NoError(t, f.srv.notiRepo.InsertMany(ctx, []*Notification{{
+					Episode:            2,
+					Season:             1,
+					SeriesID:           100,
+					NotificationTarget: target,
+				}}))
+			}
+
+			audience, err := f.srv.getSeriesAudience(ctx, 100)
+			require.NoError(t, err)
+
+			// Acting
+			skip := f.srv.canSkipCheckForNewEpisodes(ctx, model, epoch, audience, test.changed)
+
+			// Asserting
+			assert.Equal(t, test.exp, skip)
+		})
+	}
+}
+
+func TestPollScheduleNextCheck(t *testing.T) {
+	lastFetchedAt := testNow
+	tests := []struct {
+		name   string
+		series func(s *Series)
+		exp    time.Time
+	}{
+		{
+			name: "checks from shortly before the next episode airs",
+			series: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(lastFetchedAt.Add(time.Hour*5), true)
+			},
+			exp: lastFetchedAt.Add(time.Hour * 4),
+		},
+		{
+			name: "checks every tick once the next episode has aired",
+			series: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(lastFetchedAt.Add(-time.Hour), true)
+			},
+			exp: lastFetchedAt.Add(-time.Hour * 2),
+		},
+		{
+			name: "checks daily when the next episode is a while away",
+			series: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(lastFetchedAt.AddDate(0, 0, 14), true)
+			},
+			exp: lastFetchedAt.Add(time.Hour * 24),
+		},
+		{
+			name: "checks weekly when there is no date for the next episode",
+			exp:  lastFetchedAt.Add(time.Hour * 24 * 7),
+		},
+		{
+			name: "checks monthly when a returning series is on hiatus",
+			series: func(s *Series) {
+				s.Data.V.Status = "Returning Series"
+				s.Data.V.LastEpisodeToAir.AirDate = "2023-06-01"
+			},
+			exp: lastFetchedAt.Add(time.Hour * 24 * 30),
+		},
+		{
+			name: "checks weekly when a series in production hasn't aired in a while",
+			series: func(s *Series) {
+				s.Data.V.Status = "In Production"
+				s.Data.V.LastEpisodeToAir.AirDate = "2023-06-01"
+			},
+			exp: lastFetchedAt.Add(time.Hour * 24 * 7),
+		},
+		{
+			name: "uses the interval set by an admin",
+			series: func(s *Series) {
+				s.NextEpisodeAirDate = NewNull(lastFetchedAt.Add(time.Hour*5), true)
+				s.CheckIntervalMS = NewNull((time.Minute * 30).Milliseconds(), true)
+			},
+			exp: lastFetchedAt.Add(time.Minute * 30),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			series := &Series{ID: 100, LastFetchedAt: lastFetchedAt}
+			series.Data.V = &moviedb.SeriesDetails{
+				ID:               100,
+				Status:           "Returning Series",
+				LastEpisodeToAir: &moviedb.PartialEpisodeDetails{AirDate: "2024-01-17"},
+			}
+			if test.series != nil {
+				test.series(series)
+			}
+
+			// Acting
+			next := defaultPollSchedule.NextCheck(series)
+
+			// Asserting
+			assert.Equal(t, test.exp, next)
+		})
+	}
+}
+
+func TestSetCheckInterval(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	_, err := f.srv.RefreshSeriesDetails(ctx, 100)
+	require.NoError(t, err)
+
+	// Acting
+	series, err := f.srv.SetCheckInterval(ctx, 100, time.Hour*2)
+	require.NoError(t, err)
+	f.clock.Add(time.Hour)
+	_, err = f.srv.RefreshSeriesDetails(ctx, 100)
+	require.NoError(t, err)
+	overridden, err := f.srv.seriesRepo.GetSeriesByID(ctx, 100)
+	require.NoError(t, err)
+	_, err = f.srv.SetCheckInterval(ctx, 100, 0)
+	require.NoError(t, err)
+	reset, err := f.srv.seriesRepo.GetSeriesByID(ctx, 100)
+	require.NoError(t, err)
+
+	// Asserting
+	assert.Equal(t, uint64(100), series.ID)
+	assert.True(t, overridden.CheckIntervalMS.Valid)
+	assert.True(t, testNow.Add(time.Hour*3).Equal(overridden.NextCheckAt.V))
+	assert.False(t, reset.CheckIntervalMS.Valid)
+	assert.True(t, defaultPollSchedule.NextCheck(reset).Equal(reset.NextCheckAt.V))
+}
+
+func TestSearchSeries(t *testing.T) {
+	tests := []struct {
+		name    string
+		arrange func(f *seriesServiceFixture)
+		query   string
+		exp     []utils.Tuple[string, uint64]
+		expErr  bool
+	}{
+		{
+			name:  "includes the year the series first aired",
+			query: "test",
+			exp:   []utils.Tuple[string, uint64]{{T: "The Test Series (2024)", V: 100}},
+		},
+		{
+			name: "leaves out series that have not aired",
+			arrange: func(f *seriesServiceFixture) {
+				f.tmdb.AddSeries(&moviedb.SeriesDetails{ID: 101, Name: "The Test Pilot"})
+			},
+			query: "test",
+			exp:   []utils.Tuple[string, uint64]{{T: "The Test Series (2024)", V: 100}},
+		},
+		{
+			name:  "returns nothing when no series match",
+			query: "nothing",
+			exp:   []utils.Tuple[string, uint64]{},
+		},
+		{
+			name: "returns an error when TMDB fails to respond",
+			arrange: func(f *seriesServiceFixture) {
+				f.tmdb.InjectFault(moviedbtest.Fault{Path: "/search/tv", Status: http.StatusInternalServerError})
+			},
+			query:  "test",
+			expErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			f := newMemorySeriesServiceFixture(t)
+			if test.arrange != nil {
+				test.arrange(f)
+			}
+
+			// Acting
+			results, err := f.srv.SearchSeries(context.Background(), test.query)
+
+			// Asserting
+			if test.expErr {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, moviedb.ErrNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.exp, results)
+		})
+	}
+}
+
+func TestSearchSeriesCachesResults(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newMemorySeriesServiceFixture(t)
+
+	// Acting
+	_, err := f.srv.SearchSeries(ctx, "test")
+	require.NoError(t, err)
+	results, err := f.srv.SearchSeries(ctx, "test")
+
+	// Asserting
+	require.NoError(t, err)
+	assert.Equal(t, []utils.Tuple[string, uint64]{{T: "The Test Series (2024)", V: 100}}, results)
+	assert.Equal(t, []string{"/search/tv"}, f.tmdb.Requests())
+}
+
+func TestGetUserSubscribedSeriesOnlyUsesCache(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newMemorySeriesServiceFixture(t)
+	_, err := f.srv.RefreshSeriesDetails(ctx, 100)
+	require.NoError(t, err)
+	for _, seriesID := range []uint64{100, 101} {
+		require.NoError(t, f.srv.subsSrv.SubscribeUserToSeries(ctx, 1, seriesID, 10))
+	}
+
+	// Acting
+	series, err := f.srv.GetUserSubscribedSeries(ctx, 1, 10)
+
+	// Asserting
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"The Test Series", "Series 101"}, utils.MapSlice(series, func(s *moviedb.SeriesDetails, _ int) string {
+		return s.Name
+	}))
+	assert.Equal(t, []string{"/tv/100"}, f.tmdb.Requests())
+}
+
+func TestMakeEmbedForEpisode(t *testing.T) {
+	tests := []struct {
+		name    string
+		arrange func(series *moviedb.SeriesDetails, episode *moviedb.EpisodeDetails)
+		golden  string
+	}{
+		{
+			name:   "includes the poster, still and networks",
+			golden: "episode_embed",
+		},
+		{
+			name: "falls back to the backdrop when the episode has no still",
+			arrange: func(series *moviedb.SeriesDetails, episode *moviedb.EpisodeDetails) {
+				episode.StillPath = ""
+			},
+			golden: "episode_embed_backdrop",
+		},
+		{
+			name: "leaves out what the series does not have",
+			arrange: func(series *moviedb.SeriesDetails, episode *moviedb.EpisodeDetails) {
+				series.PosterPath = ""
+				series.BackdropPath = ""
+				series.Homepage = ""
+				series.Networks = nil
+				episode.StillPath = ""
+			},
+			golden: "episode_embed_bare",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			f := newMemorySeriesServiceFixture(t)
+			client := f.tmdb.Client()
+			series := &moviedb.SeriesDetails{}
+			_, err := client.GetTVSeriesDetails(100, series)
+			require.NoError(t, err)
+			season := &moviedb.SeasonDetails{}
+			_, err = client.GetTVSeasonDetails(100, 1, season)
+			require.NoError(t, err)
+			episode := &season.Episodes[1]
+			if test.arrange != nil {
+				test.arrange(series, episode)
+			}
+
+			// Acting
+			embed := f.srv.makeEmbedForEpisode(series, season, episode, []uint64{10, 11})
+
+			// Asserting
+			assertGolden(t, test.golden, embed)
+		})
+	}
+}
+
+func TestDispatchSendsQueuedEpisodes(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	_, err := f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+
+	// Acting
+	err = f.srv.outboxSrv.Dispatch(ctx)
+
+	// Asserting
+	require.NoError(t, err)
+	sent := f.discord.Sent()
+	require.Len(t, sent, 1)
+	assertGolden(t, "episode_message", sent[0])
+	assert.Empty(t, f.outbox(t))
+	assert.Equal(t, 1.0, testutil.ToFloat64(f.srv.outboxSrv.metrics.notificationsSent.WithLabelValues("channel")))
+}
+
+func TestOutboxRetryDelay(t *testing.T) {
+	tooManyRequests := func(body string) error {
+		return &discordgo.RESTError{
+			Response:     &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"},
+			ResponseBody: []byte(body),
+		}
+	}
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		expected time.Duration
+	}{
+		{
+			name:     "waits 30 seconds after the first attempt",
+			err:      errors.New("failed"),
+			expected: time.Second * 30,
+		},
+		{
+			name:     "doubles the delay with every attempt",
+			attempts: 3,
+			err:      errors.New("failed"),
+			expected: time.Minute * 4,
+		},
+		{
+			name:     "waits at most 6 hours",
+			attempts: 10,
+			err:      errors.New("failed"),
+			expected: time.Hour * 6,
+		},
+		{
+			name:     "does not overflow after many attempts",
+			attempts: 100,
+			err:      errors.New("failed"),
+			expected: time.Hour * 6,
+		},
+		{
+			name:     "waits as long as a rate limit error says",
+			attempts: 5,
+			err: &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+				TooManyRequests: &discordgo.TooManyRequests{RetryAfter: time.Second * 5},
+			}},
+			expected: time.Second * 5,
+		},
+		{
+			name:     "waits as long as a 429 response says",
+			attempts: 5,
+			err:      tooManyRequests(`{"message":"You are being rate limited.","retry_after":2.5}`),
+			expected: time.Millisecond * 2500,
+		},
+		{
+			name:     "backs off when a 429 response has no retry_after",
+			attempts: 1,
+			err:      tooManyRequests(`{"message":"You are being rate limited."}`),
+			expected: time.Minute,
+		},
+		{
+			name:     "backs off when a 429 response isn't JSON",
+			attempts: 1,
+			err:      tooManyRequests("Too Many Requests"),
+			expected: time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Acting
+			actual := outboxRetryDelay(test.attempts, test.err)
+
+			// Asserting
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDispatchRetriesRateLimitedItems(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	_, err := f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	f.discord.FailWith(&discordgo.RESTError{
+		Response:     &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"},
+		ResponseBody: []byte(`{"message":"You are being rate limited.","retry_after":3}`),
+	})
+
+	// Acting
+	require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+	f.discord.FailWith(nil)
+	items, err := f.srv.outboxSrv.outboxRepo.GetItems(ctx)
+	require.NoError(t, err)
+	f.clock.Add(time.Second * 2)
+	require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+	sentEarly := len(f.discord.Sent())
+	f.clock.Add(time.Second)
+	require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+
+	// Asserting
+	require.Len(t, items, 1)
+	assert.Equal(t, 1, items[0].Attempts)
+	assert.True(t, testNow.Add(time.Second*3).Equal(items[0].NextAttemptAt))
+	assert.True(t, items[0].LastError.Valid)
+	assert.False(t, items[0].DeadAt.Valid)
+	assert.Zero(t, sentEarly)
+	assert.Len(t, f.discord.Sent(), 1)
+	assert.Empty(t, f.outbox(t))
+}
+
+func TestDispatchGivesUpOnItems(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		expAttempts int
+	}{
+		{
+			name:        "after the max number of attempts",
+			err:         errors.New("failed"),
+			expAttempts: maxOutboxAttempts,
+		},
+		{
+			name: "when the bot can't send messages to the channel",
+			err: &discordgo.RESTError{
+				Response:     &http.Response{StatusCode: http.StatusForbidden, Status: "403 Forbidden"},
+				ResponseBody: []byte(`{"message":"Missing Access","code":50001}`),
+			},
+			expAttempts: 1,
+		},
+		{
+			name: "when the channel doesn't exist",
+			err: &discordgo.RESTError{
+				Response:     &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+				ResponseBody: []byte(`{"message":"Unknown Channel","code":10003}`),
+			},
+			expAttempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+			_, err := f.srv.FindNewEpisodes(ctx)
+			require.NoError(t, err)
+			f.discord.FailWith(test.err)
+
+			// Acting
+			for range maxOutboxAttempts + 5 {
+				require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+				f.clock.Add(time.Hour * 6)
+			}
+			f.discord.FailWith(nil)
+			_, err = f.srv.FindNewEpisodes(ctx)
+			require.NoError(t, err)
+			require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+
+			// Asserting
+			items, err := f.srv.outboxSrv.outboxRepo.GetItems(ctx)
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+			assert.Equal(t, test.expAttempts, items[0].Attempts)
+			assert.True(t, items[0].DeadAt.Valid)
+			assert.Equal(t, test.err.Error(), items[0].LastError.V)
+			assert.Empty(t, f.discord.Sent())
+		})
+	}
+}
+
+func TestSendFinishedSeriesNotifications(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	at := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	f.subscribe(t, 1, 100, 10, at)
+	f.subscribe(t, 1, 101, 11, at)
+	f.subscribe(t, 1, 102, 10, at)
+	f.subscribe(t, 2, 102, 12, at)
+	series := []*moviedb.SeriesDetails{
+		{ID: 100, Name: "The Test Series", Status: "Ended"},
+		{ID: 101, Name: "The Cancelled Series", Status: "Canceled"},
+		{ID: 102, Name: "The Other Series", Status: "Ended"},
+	}
+
+	// Acting
+	err := f.srv.sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(ctx, series)
+
+	// Asserting
+	require.NoError(t, err)
+	assertGolden(t, "finished_series_messages", f.discord.Sent())
+	subs, err := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 100, 101, 102)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestSendFinishedSeriesNotificationsKeepsSubscriptionsWhenSendingFails(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	at := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	f.subscribe(t, 1, 100, 10, at)
+	f.subscribe(t, 2, 100, 12, at)
+	series := []*moviedb.SeriesDetails{{ID: 100, Name: "The Test Series", Status: "Ended"}}
+	f.discord.FailChannelWith("20", errors.New("missing access"))
+
+	// Acting
+	err := f.srv.sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(ctx, series)
+	kept, keptErr := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 100)
+	f.discord.FailChannelWith("20", nil)
+	retryErr := f.srv.sendFinishedSeriesNotificationsAndUnsubscribeSubscribers(ctx, series)
+
+	// Asserting
+	assert.ErrorContains(t, err, "missing access")
+	require.NoError(t, keptErr)
+	require.Len(t, kept, 1)
+	assert.Equal(t, uint64(2), kept[0].GuildID)
+	require.NoError(t, retryErr)
+	assert.Equal(t, []string{"10", "20"}, utils.MapSlice(f.discord.Sent(), func(m *discordtest.SentMessage, _ int) string {
+		return m.ChannelID
+	}))
+	subs, err := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 100)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestFindNewEpisodesRetriesFinishedSeriesMessages(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	b, err := os.ReadFile("testdata/moviedb/returning_series.json")
+	require.NoError(t, err)
+	fixture := &moviedbtest.Fixture{}
+	require.NoError(t, json.Unmarshal(b, fixture))
+	fixture.Series[0].Status = "Ended"
+	f.tmdb.RemoveSeries(100)
+	f.tmdb.Load(fixture)
+	at := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	f.subscribe(t, 1, 100, 10, at)
+	f.subscribe(t, 2, 100, 12, at)
+	f.discord.FailChannelWith("20", errors.New("missing access"))
+	_, err = f.srv.FindNewEpisodes(ctx)
+	require.ErrorIs(t, err, ErrScanIncomplete)
+	f.discord.FailChannelWith("20", nil)
+	f.clock.Add(time.Hour)
+	requested := len(f.tmdb.Requests())
+
+	// Acting
+	summary, err := f.srv.FindNewEpisodes(ctx)
+
+	// Asserting
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.NotContains(t, f.tmdb.Requests()[requested:], "/tv/100")
+	sent := utils.Filter(f.discord.Sent(), func(m *discordtest.SentMessage) bool {
+		return m.ChannelID == "20"
+	})
+	assert.Len(t, sent, 1)
+	subs, err := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 100)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestFindNewEpisodesAsTimePasses(t *testing.T) {
+	type check struct {
+		at         time.Time
+		expTargets []NotificationTarget
+	}
+	tests := []struct {
+		name        string
+		subscribeAt time.Time
+		checks      []check
+	}{
+		{
+			name:        "queues episodes once their air date arrives",
+			subscribeAt: time.Date(2024, 1, 10, 9, 0, 0, 0, time.Local),
+			checks: []check{
+				{at: time.Date(2024, 1, 16, 23, 59, 0, 0, time.Local), expTargets: []NotificationTarget{}},
+				{at: time.Date(2024, 1, 17, 0, 0, 0, 0, time.Local), expTargets: []NotificationTarget{{GuildID: 1}}},
+			},
+		},
+		{
+			name:        "does not queue episodes that aired before subscribing",
+			subscribeAt: time.Date(2024, 1, 18, 9, 0, 0, 0, time.Local),
+			checks: []check{
+				{at: time.Date(2024, 1, 18, 9, 10, 0, 0, time.Local), expTargets: []NotificationTarget{}},
+				{at: time.Date(2024, 1, 30, 9, 0, 0, 0, time.Local), expTargets: []NotificationTarget{}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			f.clock.Set(test.subscribeAt)
+			require.NoError(t, f.srv.guildsSrv.SetNotificationsChannel(ctx, 1, 10))
+			require.NoError(t, f.srv.subsSrv.SubscribeUserToSeries(ctx, 1, 100, 10))
+
+			for _, check := range test.checks {
+				// Acting
+				f.clock.Set(check.at)
+				_, err := f.srv.FindNewEpisodes(ctx)
+
+				// Asserting
+				require.NoError(t, err)
+				assert.ElementsMatch(t, check.expTargets, f.outbox(t), "checking at %s", check.at)
+			}
+		})
+	}
+}
+
+func TestIncompleteNotificationsAreEditedForAWeek(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.clock.Set(time.Date(2024, 1, 17, 12, 0, 0, 0, time.Local))
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	season := &moviedb.SeasonDetails{}
+	_, err := f.tmdb.Client().GetTVSeasonDetails(100, 1, season)
+	require.NoError(t, err)
+	season.Episodes[1].Runtime = 0
+	f.tmdb.AddSeason(100, season)
+	_, err = f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	require.NoError(t, f.srv.outboxSrv.Dispatch(ctx))
+	require.Len(t, f.discord.Sent(), 1)
+
+	// Acting
+	f.clock.Add(time.Hour * 24 * 2)
+	season.Episodes[1].Overview = "The second episode, now with more detail."
+	f.tmdb.AddSeason(100, season)
+	_, err = f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	editedWithinWeek := len(f.discord.Edits())
+	incompleteWithinWeek, err := f.srv.notiRepo.GetIncompleteForSeries(ctx, 100)
+	require.NoError(t, err)
+
+	f.clock.Add(time.Hour * 24 * 6)
+	season.Episodes[1].Overview = "The second episode, now with even more detail."
+	f.tmdb.AddSeason(100, season)
+	_, err = f.srv.FindNewEpisodes(ctx)
+	require.NoError(t, err)
+	incompleteAfterWeek, err := f.srv.notiRepo.GetIncompleteForSeries(ctx, 100)
+	require.NoError(t, err)
+
+	// Asserting
+	assert.Equal(t, 1, editedWithinWeek)
+	assert.Len(t, incompleteWithinWeek, 1)
+	assert.Len(t, f.discord.Edits(), 1)
+	assert.Empty(t, incompleteAfterWeek)
+	assert.Empty(t, f.outbox(t))
+}
+
+func TestAdoptLegacySubscriptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		legacy     bool
+		guild      *Guild
+		expMoved   int64
+		expChannel uint64
+	}{
+		{
+			name:       "moves legacy subscriptions into the guild and sets its channel",
+			legacy:     true,
+			expMoved:   1,
+			expChannel: 5,
+		},
+		{
+			name:       "keeps the channel of a guild that is already set up",
+			legacy:     true,
+			guild:      &Guild{ID: 1, NotificationsChannelID: 7, NotificationsChannelSetAt: testNow},
+			expMoved:   1,
+			expChannel: 7,
+		},
+		{
+			name: "does nothing without legacy subscriptions",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			if test.legacy {
+				require.NoError(t, f.srv.subsSrv.Insert(ctx, &Subscription{SeriesID: 100, UserID: 10, CreatedAt: testNow}))
+			}
+			if test.guild != nil {
+				require.NoError(t, f.srv.guildsSrv.Upsert(ctx, test.guild))
+			}
+
+			// Acting
+			moved, err := f.srv.guildsSrv.AdoptLegacySubscriptions(ctx, 1, 5)
+
+			// Asserting
+			require.NoError(t, err)
+			assert.Equal(t, test.expMoved, moved)
+			remaining, err := f.srv.guildsSrv.CountLegacySubscriptions(ctx)
+			require.NoError(t, err)
+			assert.Zero(t, remaining)
+			guilds, err := f.srv.guildsSrv.GetGuildsByIDs(ctx, 1)
+			require.NoError(t, err)
+			if test.expChannel == 0 {
+				assert.Empty(t, guilds)
+				return
+			}
+			require.Contains(t, guilds, uint64(1))
+			assert.Equal(t, test.expChannel, guilds[1].NotificationsChannelID)
+			subs, err := f.srv.subsSrv.GetSubscriptionsForSeries(ctx, 100)
+			require.NoError(t, err)
+			require.Len(t, subs, 1)
+			assert.Equal(t, uint64(1), subs[0].GuildID)
+		})
+	}
+}
+
+func TestSendRemindersOnceAtLeadTime(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	srv := NewRemindersService(NewRemindersRepo(f.db), f.srv.seriesRepo, f.srv.subsSrv, f.srv.prefsSrv, f.discord, f.clock)
+	f.subscribe(t, 1, 100, 10, testNow)
+	require.NoError(t, f.srv.prefsSrv.SetReminderLeadTime(ctx, 10, time.Hour*24))
+	_, _, err := f.srv.GetSeriesDetails(ctx, 100)
+	require.NoError(t, err)
+	model, err := f.srv.seriesRepo.GetSeriesByID(ctx, 100)
+	require.NoError(t, err)
+	airsAt := time.Date(2024, 1, 23, 0, 0, 0, 0, time.Local)
+	model.NextEpisodeAirDate = NewNull(airsAt, true)
+	require.NoError(t, f.srv.seriesRepo.Upsert(ctx, model))
+
+	steps := []struct {
+		at      time.Time
+		expSent int
+	}{
+		{at: testNow, expSent: 0},
+		{at: airsAt.AddDate(0, 0, -1).Add(-time.Minute), expSent: 0},
+		{at: airsAt.AddDate(0, 0, -1), expSent: 1},
+		{at: airsAt.AddDate(0, 0, -1).Add(time.Hour * 12), expSent: 1},
+		{at: airsAt, expSent: 1},
+	}
+
+	for _, step := range steps {
+		// Acting
+		f.clock.Set(step.at)
+		err := srv.SendReminders(ctx)
+
+		// Asserting
+		require.NoError(t, err)
+		assert.Len(t, f.discord.Sent(), step.expSent, "at %s", step.at)
+	}
+	embeds := f.discord.Embeds()
+	require.Len(t, embeds, 1)
+	airs := embeds[0].Fields[2]
+	assert.Equal(t, "Airs", airs.Name)
+	assert.Equal(t, fmt.Sprintf("<t:%d:D>", time.Date(2024, 1, 23, 12, 0, 0, 0, time.UTC).Unix()), airs.Value)
+}
+
+func TestSendDueDigests(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	f.clock.Set(time.Date(2024, 1, 20, 12, 0, 0, 0, newYork))
+	require.NoError(t, f.srv.prefsSrv.SetDigestFrequency(ctx, 10, DigestFrequencyDaily))
+	require.NoError(t, f.srv.prefsSrv.SetDigestTime(ctx, 10, 20*60))
+	require.NoError(t, f.srv.prefsSrv.SetDigestTimezone(ctx, 10, "America/New_York"))
+	queue := func(episode int) {
+		require.NoError(t, f.srv.digestSrv.Queue(ctx,
+			&moviedb.SeriesDetails{ID: 100},
+			&moviedb.EpisodeDetails{SeasonNumber: 1, EpisodeNumber: episode},
+			&Notification{SeriesID: 100, Season: 1, Episode: episode, NotificationTarget: NotificationTarget{UserID: 10}},
+		))
+	}
+	queue(1)
+
+	steps := []struct {
+		name      string
+		at        time.Time
+		queue     int
+		fail      bool
+		expSent   int
+		expQueued int
+	}{
+		{name: "before the digest time", at: time.Date(2024, 1, 20, 19, 59, 0, 0, newYork), expSent: 0, expQueued: 1},
+		{name: "send fails", at: time.Date(2024, 1, 20, 20, 0, 0, 0, newYork), fail: true, expSent: 0, expQueued: 1},
+		{name: "send is retried", at: time.Date(2024, 1, 20, 20, 1, 0, 0, newYork), expSent: 1, expQueued: 0},
+		{name: "not due again the same day", at: time.Date(2024, 1, 21, 19, 59, 0, 0, newYork), queue: 2, expSent: 1, expQueued: 1},
+		{name: "due the next day", at: time.Date(2024, 1, 21, 20, 0, 0, 0, newYork), expSent: 2, expQueued: 0},
+	}
+
+	for _, step := range steps {
+		f.clock.Set(step.at)
+		if step.queue > 0 {
+			queue(step.queue)
+		}
+		if step.fail {
+			f.discord.FailWith(errors.New("discord is down"))
+		}
+
+		// Acting
+		err := f.srv.digestSrv.SendDueDigests(ctx)
+		f.discord.FailWith(nil)
+
+		// Asserting
+		require.NoError(t, err, step.name)
+		assert.Len(t, f.discord.Sent(), step.expSent, step.name)
+		items, err := f.srv.digestSrv.digestRepo.GetUserItems(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, items, step.expQueued, step.name)
+	}
+}
+
+func TestGetUpcomingEpisodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		arrange        func(t *testing.T, f *seriesServiceFixture)
+		days           int
+		expUpcoming    []string
+		expUnannounced []string
+		expRequests    []string
+	}{
+		{
+			name:           "includes episodes airing within the days provided",
+			days:           7,
+			expUpcoming:    []string{"The Soon Series", "The Next Week Series"},
+			expUnannounced: []string{"The Unannounced Series"},
+			expRequests:    []string{"/tv/100", "/tv/101", "/tv/102", "/tv/103"},
+		},
+		{
+			name:           "leaves out episodes airing after the days provided",
+			days:           6,
+			expUpcoming:    []string{"The Soon Series"},
+			expUnannounced: []string{"The Unannounced Series"},
+			expRequests:    []string{"/tv/100", "/tv/101", "/tv/102", "/tv/103"},
+		},
+		{
+			name:           "counts today as the first day",
+			days:           1,
+			expUpcoming:    []string{},
+			expUnannounced: []string{"The Unannounced Series"},
+			expRequests:    []string{"/tv/100", "/tv/101", "/tv/102", "/tv/103"},
+		},
+		{
+			name: "uses series cached within the last day",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				_, _, err := f.srv.GetUpcomingEpisodes(context.Background(), 1, 10, 7)
+				require.NoError(t, err)
+				f.clock.Add(time.Hour * 23)
+			},
+			days:           7,
+			expUpcoming:    []string{"The Soon Series", "The Next Week Series"},
+			expUnannounced: []string{"The Unannounced Series"},
+			expRequests:    []string{"/tv/100", "/tv/101", "/tv/102", "/tv/103"},
+		},
+		{
+			name: "refreshes series cached over a day ago",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				_, _, err := f.srv.GetUpcomingEpisodes(context.Background(), 1, 10, 7)
+				require.NoError(t, err)
+				f.clock.Add(time.Hour * 25)
+			},
+			days:           7,
+			expUpcoming:    []string{"The Soon Series", "The Next Week Series"},
+			expUnannounced: []string{"The Unannounced Series"},
+			expRequests: []string{
+				"/tv/100", "/tv/101", "/tv/102", "/tv/103",
+				"/tv/100", "/tv/101", "/tv/102", "/tv/103",
+			},
+		},
+		{
+			name: "uses cached series that fail to refresh",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				_, _, err := f.srv.GetUpcomingEpisodes(context.Background(), 1, 10, 7)
+				require.NoError(t, err)
+				f.clock.Add(time.Hour * 25)
+				f.tmdb.InjectFault(moviedbtest.Fault{Path: "/tv/101", Status: http.StatusInternalServerError})
+			},
+			days:           7,
+			expUpcoming:    []string{"The Soon Series", "The Next Week Series"},
+			expUnannounced: []string{"The Unannounced Series"},
+			expRequests: []string{
+				"/tv/100", "/tv/101", "/tv/102", "/tv/103",
+				"/tv/100", "/tv/101", "/tv/102", "/tv/103",
+			},
+		},
+		{
+			name: "leaves out cached episodes that already aired when the series fails to refresh",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				_, _, err := f.srv.GetUpcomingEpisodes(context.Background(), 1, 10, 7)
+				require.NoError(t, err)
+				f.clock.Add(time.Hour * 24 * 2)
+				f.tmdb.InjectFault(moviedbtest.Fault{Path: "/tv/101", Status: http.StatusInternalServerError})
+			},
+			days:           7,
+			expUpcoming:    []string{"The Next Week Series"},
+			expUnannounced: []string{"The Soon Series", "The Unannounced Series"},
+			expRequests: []string{
+				"/tv/100", "/tv/101", "/tv/102", "/tv/103",
+				"/tv/100", "/tv/101", "/tv/102", "/tv/103",
+			},
+		},
+		{
+			name: "leaves out series that can't be fetched",
+			arrange: func(t *testing.T, f *seriesServiceFixture) {
+				f.tmdb.InjectFault(moviedbtest.Fault{Path: "/tv/103", Status: http.StatusInternalServerError})
+			},
+			days:           7,
+			expUpcoming:    []string{"The Soon Series", "The Next Week Series"},
+			expUnannounced: []string{},
+			expRequests:    []string{"/tv/100", "/tv/101", "/tv/102", "/tv/103"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newMemorySeriesServiceFixture(t)
+			f.tmdb.AddSeries(
+				&moviedb.SeriesDetails{ID: 101, Name: "The Soon Series", NextEpisodeToAir: &moviedb.PartialEpisodeDetails{AirDate: "2024-01-21"}},
+				&moviedb.SeriesDetails{ID: 102, Name: "The Next Week Series", NextEpisodeToAir: &moviedb.PartialEpisodeDetails{AirDate: "2024-01-26"}},
+				&moviedb.SeriesDetails{ID: 103, Name: "The Unannounced Series"},
+			)
+			for _, seriesID := range []uint64{100, 101, 102, 103} {
+				require.NoError(t, f.srv.subsSrv.SubscribeUserToSeries(ctx, 1, seriesID, 10))
+			}
+			if test.arrange != nil {
+				test.arrange(t, f)
+			}
+
+			// Acting
+			upcoming, unannounced, err := f.srv.GetUpcomingEpisodes(ctx, 1, 10, test.days)
+
+			// Asserting
+			require.NoError(t, err)
+			assert.Equal(t, test.expUpcoming, utils.MapSlice(upcoming, func(e *UpcomingEpisode, _ int) string {
+				return e.Series.Name
+			}))
+			assert.Equal(t, test.expUnannounced, utils.MapSlice(unannounced, func(s *moviedb.SeriesDetails, _ int) string {
+				return s.Name
+			}))
+			assert.ElementsMatch(t, test.expRequests, f.tmdb.Requests())
+		})
+	}
+}
+
+func TestJobsServiceRecordsFindNewEpisodesRuns(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	srv := NewJobsService(NewJobRunsRepo(f.db), f.srv, NewMetrics(), time.Minute*30, f.clock)
+
+	// Acting
+	run, err := srv.FindNewEpisodes(ctx)
+
+	// Asserting
+	require.NoError(t, err)
+	status, err := srv.GetStatus(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, status.Runs, 1)
+	recorded := status.Runs[0]
+	assert.Equal(t, JobRunFindNewEpisodes, recorded.Job)
+	assert.True(t, testNow.Equal(recorded.StartedAt))
+	assert.Equal(t, 1, recorded.SeriesChecked)
+	assert.Equal(t, 1, recorded.EpisodesNotified)
+	assert.Positive(t, recorded.TMDBCalls)
+	assert.Equal(t, int64(len(f.tmdb.Requests())), recorded.TMDBCalls)
+	assert.False(t, recorded.Error.Valid)
+	assert.Equal(t, run.TMDBCalls, recorded.TMDBCalls)
+	assert.True(t, status.Healthy)
+}
+
+func TestJobsServiceOnlyFailsRunsThatFailAsAWhole(t *testing.T) {
+	tests := []struct {
+		name      string
+		fault     moviedbtest.Fault
+		expFailed bool
+	}{
+		{
+			name:  "does not fail a run where a series failed",
+			fault: moviedbtest.Fault{Path: "/tv/100", Status: http.StatusInternalServerError},
+		},
+		{
+			name:      "fails a run stopped because TMDB rejects the access token",
+			fault:     moviedbtest.Fault{Status: http.StatusUnauthorized},
+			expFailed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+			f.tmdb.InjectFault(test.fault)
+			srv := NewJobsService(NewJobRunsRepo(f.db), f.srv, NewMetrics(), time.Minute*30, f.clock)
+
+			// Acting
+			_, err := srv.FindNewEpisodes(ctx)
+
+			// Asserting
+			require.Error(t, err)
+			status, err := srv.GetStatus(ctx, 1)
+			require.NoError(t, err)
+			require.Len(t, status.Runs, 1)
+			assert.True(t, status.Runs[0].Error.Valid)
+			assert.Equal(t, test.expFailed, status.Runs[0].Failed)
+			assert.Equal(t, !test.expFailed, status.Healthy)
+		})
+	}
+}
+
+func TestJobsServiceGetStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		runs       []*JobRun
+		elapsed    time.Duration
+		expHealthy bool
+		expRuns    int
+	}{
+		{
+			name:       "is healthy before the first run within a scan window of starting",
+			elapsed:    time.Minute * 29,
+			expHealthy: true,
+		},
+		{
+			name:       "is not healthy when there hasn't been a run a scan window after starting",
+			elapsed:    time.Minute * 30,
+			expHealthy: false,
+		},
+		{
+			name: "is healthy when the last run succeeded recently",
+			runs: []*JobRun{
+				{StartedAt: testNow.Add(-time.Minute * 25), Error: NewNull("failed", true), Failed: true},
+				{StartedAt: testNow.Add(-time.Minute * 15)},
+			},
+			expHealthy: true,
+			expRuns:    2,
+		},
+		{
+			name: "is not healthy when the last run failed",
+			runs: []*JobRun{
+				{StartedAt: testNow.Add(-time.Minute * 25)},
+				{StartedAt: testNow.Add(-time.Minute * 15), Error: NewNull("failed", true), Failed: true},
+			},
+			expHealthy: false,
+			expRuns:    2,
+		},
+		{
+			name: "is healthy when only some series failed in the last run",
+			runs: []*JobRun{
+				{StartedAt: testNow.Add(-time.Minute * 15), SeriesFailed: 1, Error: NewNull("series 100: failed", true)},
+			},
+			expHealthy: true,
+			expRuns:    1,
+		},
+		{
+			name: "is not healthy when there hasn't been a run recently",
+			runs: []*JobRun{
+				{StartedAt: testNow.Add(-time.Hour)},
+			},
+			expHealthy: false,
+			expRuns:    1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			repo := NewJobRunsRepo(f.db)
+			for _, run := range test.runs {
+				run.Job = JobRunFindNewEpisodes
+				run.FinishedAt = run.StartedAt.Add(time.Minute)
+				run.DurationMS = time.Minute.Milliseconds()
+				require.NoError(t, repo.Insert(ctx, run))
+			}
+			srv := NewJobsService(repo, f.srv, NewMetrics(), time.Minute*30, f.clock)
+			f.clock.Add(test.elapsed)
+
+			// Acting
+			status, err := srv.GetStatus(ctx, 10)
+
+			// Asserting
+			require.NoError(t, err)
+			assert.Equal(t, test.expHealthy, status.Healthy)
+			assert.Len(t, status.Runs, test.expRuns)
+		})
+	}
+}
+
+func TestLeaseService(t *testing.T) {
+	tests := []struct {
+		name    string
+		arrange func(t *testing.T, clk *clock.Mock, a, b *LeaseService)
+		expA    bool
+		expB    bool
+	}{
+		{
+			name:    "acquires the lease when no one holds it",
+			arrange: func(t *testing.T, clk *clock.Mock, a, b *LeaseService) {},
+			expA:    true,
+		},
+		{
+			name: "does not acquire the lease while another instance holds it",
+			arrange: func(t *testing.T, clk *clock.Mock, a, b *LeaseService) {
+				require.NoError(t, b.Renew(context.Background()))
+				clk.Add(time.Second * 59)
+			},
+			expB: true,
+		},
+		{
+			name: "takes over the lease once it expires",
+			arrange: func(t *testing.T, clk *clock.Mock, a, b *LeaseService) {
+				require.NoError(t, b.Renew(context.Background()))
+				clk.Add(time.Minute)
+			},
+			expA: true,
+		},
+		{
+			name: "keeps the lease while it's renewed",
+			arrange: func(t *testing.T, clk *clock.Mock, a, b *LeaseService) {
+				for range 5 {
+					require.NoError(t, b.Renew(context.Background()))
+					clk.Add(time.Second * 30)
+				}
+				require.NoError(t, b.Renew(context.Background()))
+			},
+			expB: true,
+		},
+		{
+			name: "acquires the lease once it's released",
+			arrange: func(t *testing.T, clk *clock.Mock, a, b *LeaseService) {
+				require.NoError(t, b.Renew(context.Background()))
+				require.NoError(t, b.Release(context.Background()))
+			},
+			expA: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			repo := NewLeasesRepo(newTestDB(t))
+			clk := clock.NewMock()
+			clk.Set(testNow)
+			a := NewLeaseService(repo, "a", time.Minute, clk)
+			b := NewLeaseService(repo, "b", time.Minute, clk)
+			test.arrange(t, clk, a, b)
+
+			// Acting
+			err := a.Renew(context.Background())
+
+			// Asserting
+			require.NoError(t, err)
+			_, heldA := a.Held()
+			assert.Equal(t, test.expA, heldA)
+			// Renewing since the other instance doesn't know it lost the lease until it checks with the database
+			require.NoError(t, b.Renew(context.Background()))
+			_, heldB := b.Held()
+			assert.Equal(t, test.expB, heldB)
+		})
+	}
+}
+
+func TestLeaseLostDuringFindNewEpisodes(t *testing.T) {
+	// Arranging
+	ctx := context.Background()
+	f := newSeriesServiceFixture(t)
+	f.subscribe(t, 1, 100, 10, time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local))
+	f.tmdb.InjectFault(moviedbtest.Fault{Path: "/tv/100", Latency: time.Minute})
+	repo := NewLeasesRepo(f.db)
+	a := NewLeaseService(repo, "a", time.Minute, f.clock)
+	b := NewLeaseService(repo, "b", time.Minute, f.clock)
+	require.NoError(t, a.Renew(ctx))
+
+	errs := make(chan error, 1)
+	a.Guard(func(ctx context.Context) {
+		go func() {
+			_, err := f.srv.FindNewEpisodes(ctx)
+			errs <- err
+		}()
+	})()
+	require.Eventually(t, func() bool {
+		return slices.Contains(f.tmdb.Requests(), "/tv/100")
+	}, time.Second*5, time.Millisecond*10)
+
+	// Acting
+	f.clock.Add(time.Minute)
+	require.NoError(t, b.Renew(ctx))
+	require.NoError(t, a.Renew(ctx))
+
+	// Asserting
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second * 5):
+		t.Fatal("scan kept running after the lease was lost")
+	}
+	_, held := a.Held()
+	assert.False(t, held)
+	_, held = b.Held()
+	assert.True(t, held)
+	assert.Empty(t, f.outbox(t))
+}
+
+func TestHealthServiceReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		arrange   func(t *testing.T, f *seriesServiceFixture, srv *HealthService)
+		expReady  bool
+		expFailed []string
+	}{
+		{
+			name:     "is ready when every check passes",
+			expReady: true,
+		},
+		{
+			name: "is not ready until the Discord gateway is ready",
+			arrange: func(t *testing.T, f *seriesServiceFixture, srv *HealthService) {
+				srv.discordReady.Store(false)
+			},
+			expFailed: []string{"discord"},
+		},
+		{
+			name: "is not ready when the last scan failed",
+			arrange: func(t *testing.T, f *seriesServiceFixture, srv *HealthService) {
+				require.NoError(t, NewJobRunsRepo(f.db).Insert(context.Background(), &JobRun{
+					Job:        JobRunFindNewEpisodes,
+					StartedAt:  testNow,
+					FinishedAt: testNow,
+					Error:      NewNull("failed", true),
+					Failed:     true,
+				}))
+			},
+			expFailed: []string{"scan"},
+		},
+		{
+			name: "is ready when only some series failed in the last scan",
+			arrange: func(t *testing.T, f *seriesServiceFixture, srv *HealthService) {
+				require.NoError(t, NewJobRunsRepo(f.db).Insert(context.Background(), &JobRun{
+					Job:          JobRunFindNewEpisodes,
+					StartedAt:    testNow,
+					FinishedAt:   testNow,
+					SeriesFailed: 1,
+					Error:        NewNull("series 100: failed", true),
+				}))
+			},
+			expReady: true,
+		},
+		{
+			name: "is not ready when the last scan is outside the window",
+			arrange: func(t *testing.T, f *seriesServiceFixture, srv *HealthService) {
+				f.clock.Add(time.Minute * 30)
+			},
+			expFailed: []string{"scan"},
+		},
+		{
+			name: "is not ready when a migration hasn't been applied",
+			arrange: func(t *testing.T, f *seriesServiceFixture, srv *HealthService) {
+				srv.latestMigration++
+			},
+			expFailed: []string{"migrations"},
+		},
+		{
+			name: "is not ready when the database can't be reached",
+			arrange: func(t *testing.T, f *seriesServiceFixture, srv *HealthService) {
+				require.NoError(t, f.db.Close())
+			},
+			expFailed: []string{"database", "migrations", "scan"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arranging
+			ctx := context.Background()
+			f := newSeriesServiceFixture(t)
+			require.NoError(t, NewJobRunsRepo(f.db).Insert(ctx, &JobRun{
+				Job:        JobRunFindNewEpisodes,
+				StartedAt:  testNow.Add(-time.Minute),
+				FinishedAt: testNow.Add(-time.Minute),
+			}))
+			latest, err := latestMigration(f.db)
+			require.NoError(t, err)
+			jobsSrv := NewJobsService(NewJobRunsRepo(f.db), f.srv, NewMetrics(), time.Minute*30, f.clock)
+			srv := NewHealthService(f.db, jobsSrv, latest)
+			srv.discordReady.Store(true)
+			if test.arrange != nil {
+				test.arrange(t, f, srv)
+			}
+
+			// Acting
+			readiness := srv.Readiness(ctx)
+
+			// Asserting
+			assert.Equal(t, test.expReady, readiness.Ready)
+			assert.Len(t, readiness.Checks, 4)
+			failed := []string{}
+			for _, check := range readiness.Checks {
+				if !check.OK {
+					assert.NotEmpty(t, check.Error)
+					failed = append(failed, check.Name)
+				}
+			}
+			assert.ElementsMatch(t, test.expFailed, failed)
+		})
+	}
+}
+
+func TestSubscribedSeriesChoices(t *testing.T) {
+	series := []*moviedb.SeriesDetails{
+		{ID: 100, Name: "The Test Series", FirstAirDate: "2023-09-01"},
+		{ID: 101, Name: "Another Series", FirstAirDate: "2019-01-15"},
+		{ID: 102, Name: "Unaired Test"},
+	}
+	tests := []struct {
+		name        string
+		partialName string
+		expected    []*discordgo.ApplicationCommandOptionChoice
+	}{
+		{
+			name:        "lists every series sorted by name when nothing is typed",
+			partialName: "",
+			expected: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "Another Series (2019)", Value: "101"},
+				{Name: "The Test Series (2023)", Value: "100"},
+				{Name: "Unaired Test", Value: "102"},
+			},
+		},
+		{
+			name:        "only lists series whose name contains what was typed",
+			partialName: "test",
+			expected: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "The Test Series (2023)", Value: "100"},
+				{Name: "Unaired Test", Value: "102"},
+			},
+		},
+		{
+			name:        "lists nothing when no series matches",
+			partialName: "missing",
+			expected:    []*discordgo.ApplicationCommandOptionChoice{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Acting
+			choices := subscribedSeriesChoices(series, test.partialName)
+
+			// Asserting
+			assert.Equal(t, test.expected, choices)
+		})
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"io/fs"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/spf13/afero"
 )
 
 type dateFile struct {
 	fs       afero.Fs
+	clock    clock.Clock
 	file     afero.File
 	lastDate string
 	name     string
@@ -18,9 +20,12 @@ type dateFile struct {
 	perms    fs.FileMode
 }
 
-func NewDateFile(fs afero.Fs, name string, flags int, perms fs.FileMode) io.ReadWriteCloser {
+// NewDateFile returns a file that is prefixed with the current date. A new file is opened when the date on the
+// clock changes
+func NewDateFile(fs afero.Fs, c clock.Clock, name string, flags int, perms fs.FileMode) io.ReadWriteCloser {
 	return &dateFile{
 		fs:    fs,
+		clock: c,
 		name:  name,
 		flags: flags,
 		perms: perms,
@@ -28,15 +33,15 @@ func NewDateFile(fs afero.Fs, name string, flags int, perms fs.FileMode) io.Read
 }
 
 func (f *dateFile) Close() error {
-	if f.file != nil {
+	if f.file == nil {
 		return nil
 	}
 
-	return f.Close()
+	return f.file.Close()
 }
 
 func (f *dateFile) loadFile() error {
-	t := time.Now().Format(time.DateOnly)
+	t := f.clock.Now().Format(time.DateOnly)
 	if t == f.lastDate {
 		return nil
 	}
@@ -54,6 +59,7 @@ func (f *dateFile) loadFile() error {
 	}
 
 	f.file = file
+	f.lastDate = t
 	return nil
 }
 
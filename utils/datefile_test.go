@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateFileStartsNewFileWhenTheDateChanges(t *testing.T) {
+	// Arranging
+	fs := afero.NewMemMapFs()
+	clk := clock.NewMock()
+	clk.Set(time.Date(2024, 1, 17, 23, 59, 0, 0, time.Local))
+	f := NewDateFile(fs, clk, "log.jsonl", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	defer f.Close()
+
+	// Acting
+	_, err := f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err)
+	clk.Add(time.Minute)
+	_, err = f.Write([]byte("third\n"))
+	require.NoError(t, err)
+
+	// Asserting
+	first, err := afero.ReadFile(fs, "2024-01-17-log.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(first))
+	second, err := afero.ReadFile(fs, "2024-01-18-log.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(second))
+}
+
+func TestDateFileCloseWithoutWriting(t *testing.T) {
+	// Arranging
+	f := NewDateFile(afero.NewMemMapFs(), clock.NewMock(), "log.jsonl", os.O_CREATE|os.O_WRONLY, 0666)
+
+	// Acting
+	err := f.Close()
+
+	// Asserting
+	assert.NoError(t, err)
+}
@@ -6,6 +6,29 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// DiscordSender is the subset of *discordgo.Session the bot uses to talk to Discord. It exists so
+// services and command handlers can be exercised against a fake in tests.
+type DiscordSender interface {
+	AddHandler(handler interface{}) func()
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+}
+
+var _ DiscordSender = (*discordgo.Session)(nil)
+
+// The colours of the embeds sent by DiscordResponse for each kind of response
+const (
+	DiscordColorInfo    = 0x0c5460
+	DiscordColorSuccess = 0x155724
+	DiscordColorWarning = 0x856404
+	DiscordColorError   = 0x721c24
+)
+
 type DiscordResponse interface {
 	Respond() error
 	Edit() (*discordgo.Message, error)
@@ -23,11 +46,11 @@ type DiscordResponse interface {
 
 type discordResponse struct {
 	embed *discordgo.MessageEmbed
-	s     *discordgo.Session
+	s     DiscordSender
 	i     *discordgo.InteractionCreate
 }
 
-func NewDiscordResponse(s *discordgo.Session, i *discordgo.InteractionCreate) DiscordResponse {
+func NewDiscordResponse(s DiscordSender, i *discordgo.InteractionCreate) DiscordResponse {
 	return &discordResponse{
 		s:     s,
 		i:     i,
@@ -100,28 +123,28 @@ func (dr *discordResponse) SetTitlef(t string, args ...interface{}) DiscordRespo
 }
 
 func (dr *discordResponse) SetInfo(desc string) DiscordResponse {
-	dr.embed.Color = 0x0c5460
+	dr.embed.Color = DiscordColorInfo
 	dr.SetDescription(desc)
 
 	return dr
 }
 
 func (dr *discordResponse) SetSuccess(desc string) DiscordResponse {
-	dr.embed.Color = 0x155724
+	dr.embed.Color = DiscordColorSuccess
 	dr.SetDescription(desc)
 
 	return dr
 }
 
 func (dr *discordResponse) SetWarning(desc string) DiscordResponse {
-	dr.embed.Color = 0x856404
+	dr.embed.Color = DiscordColorWarning
 	dr.SetDescription(desc)
 
 	return dr
 }
 
 func (dr *discordResponse) SetError(err error) DiscordResponse {
-	dr.embed.Color = 0x721c24
+	dr.embed.Color = DiscordColorError
 	dr.SetDescriptionf("```%s```", err.Error())
 
 	return dr
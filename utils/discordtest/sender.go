@@ -0,0 +1,260 @@
+// Package discordtest provides a fake of the Discord session for tests. The fake records the messages,
+// edits and interaction responses sent through it instead of sending them to Discord
+package discordtest
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/duke605/tv-bot/utils"
+)
+
+var _ utils.DiscordSender = (*Sender)(nil)
+
+// SentMessage is a message sent to the channel with the ID provided. Embeds holds both the embed set with
+// MessageSend.Embed and the ones set with MessageSend.Embeds
+type SentMessage struct {
+	ChannelID string                    `json:"channel_id"`
+	Content   string                    `json:"content"`
+	Embeds    []*discordgo.MessageEmbed `json:"embeds"`
+}
+
+// Sender is a utils.DiscordSender that records what is sent through it. Messages are given increasing IDs
+// starting at 1 and the DM channel of a user has the same ID as the user
+type Sender struct {
+	mu            sync.Mutex
+	err           error
+	channelErrs   map[string]error
+	nextID        uint64
+	messages      map[string]*discordgo.Message
+	sent          []*SentMessage
+	edits         []*discordgo.MessageEdit
+	responses     []*discordgo.InteractionResponse
+	responseEdits []*discordgo.WebhookEdit
+	commands      []*discordgo.ApplicationCommand
+	handlers      []interface{}
+}
+
+// NewSender creates a fake with nothing recorded
+func NewSender() *Sender {
+	return &Sender{
+		messages:    map[string]*discordgo.Message{},
+		channelErrs: map[string]error{},
+	}
+}
+
+// FailWith makes every following call return the error. Passing nil makes calls succeed again
+func (s *Sender) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// FailChannelWith makes every following message sent to the channel return the error. Passing nil makes
+// messages sent to the channel succeed again
+func (s *Sender) FailChannelWith(channelID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		delete(s.channelErrs, channelID)
+	} else {
+		s.channelErrs[channelID] = err
+	}
+}
+
+// Sent returns the messages sent in the order they were sent
+func (s *Sender) Sent() []*SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*SentMessage{}, s.sent...)
+}
+
+// Embeds returns the embeds of every message sent in the order they were sent
+func (s *Sender) Embeds() []*discordgo.MessageEmbed {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	embeds := []*discordgo.MessageEmbed{}
+	for _, m := range s.sent {
+		embeds = append(embeds, m.Embeds...)
+	}
+
+	return embeds
+}
+
+// Edits returns the message edits in the order they were made
+func (s *Sender) Edits() []*discordgo.MessageEdit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*discordgo.MessageEdit{}, s.edits...)
+}
+
+// Responses returns the interaction responses in the order they were sent
+func (s *Sender) Responses() []*discordgo.InteractionResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*discordgo.InteractionResponse{}, s.responses...)
+}
+
+// ResponseEdits returns the interaction response edits in the order they were made
+func (s *Sender) ResponseEdits() []*discordgo.WebhookEdit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*discordgo.WebhookEdit{}, s.responseEdits...)
+}
+
+// Commands returns the application commands last registered
+func (s *Sender) Commands() []*discordgo.ApplicationCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*discordgo.ApplicationCommand{}, s.commands...)
+}
+
+func (s *Sender) AddHandler(handler interface{}) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers = append(s.handlers, handler)
+	return func() {}
+}
+
+func (s *Sender) ApplicationCommandBulkOverwrite(
+	appID string,
+	guildID string,
+	commands []*discordgo.ApplicationCommand,
+	options ...discordgo.RequestOption,
+) ([]*discordgo.ApplicationCommand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.commands = commands
+
+	return commands, nil
+}
+
+func (s *Sender) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	m := s.messages[messageID]
+	if m == nil || m.ChannelID != channelID {
+		return nil, fmt.Errorf("message %s not found in channel %s", messageID, channelID)
+	}
+	copied := *m
+
+	return &copied, nil
+}
+
+func (s *Sender) ChannelMessageSendComplex(
+	channelID string,
+	data *discordgo.MessageSend,
+	options ...discordgo.RequestOption,
+) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	} else if err := s.channelErrs[channelID]; err != nil {
+		return nil, err
+	}
+	s.nextID++
+	m := &discordgo.Message{
+		ID:        strconv.FormatUint(s.nextID, 10),
+		ChannelID: channelID,
+		Content:   data.Content,
+		Embeds:    data.Embeds,
+	}
+	if data.Embed != nil {
+		m.Embeds = append([]*discordgo.MessageEmbed{data.Embed}, data.Embeds...)
+	}
+	s.messages[m.ID] = m
+	s.sent = append(s.sent, &SentMessage{ChannelID: channelID, Content: m.Content, Embeds: m.Embeds})
+	copied := *m
+
+	return &copied, nil
+}
+
+func (s *Sender) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	existing := s.messages[m.ID]
+	if existing == nil || existing.ChannelID != m.Channel {
+		return nil, fmt.Errorf("message %s not found in channel %s", m.ID, m.Channel)
+	}
+	if m.Content != nil {
+		existing.Content = *m.Content
+	}
+	if m.Embeds != nil {
+		existing.Embeds = m.Embeds
+	}
+	s.edits = append(s.edits, m)
+	copied := *existing
+
+	return &copied, nil
+}
+
+func (s *Sender) InteractionRespond(
+	interaction *discordgo.Interaction,
+	resp *discordgo.InteractionResponse,
+	options ...discordgo.RequestOption,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	s.responses = append(s.responses, resp)
+
+	return nil
+}
+
+func (s *Sender) InteractionResponseEdit(
+	interaction *discordgo.Interaction,
+	newresp *discordgo.WebhookEdit,
+	options ...discordgo.RequestOption,
+) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.responseEdits = append(s.responseEdits, newresp)
+
+	return &discordgo.Message{ChannelID: interaction.ChannelID}, nil
+}
+
+func (s *Sender) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &discordgo.Channel{
+		ID:   recipientID,
+		Type: discordgo.ChannelTypeDM,
+	}, nil
+}
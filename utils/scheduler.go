@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+type scheduledJob struct {
+	interval time.Duration
+	fn       func()
+}
+
+// Scheduler runs jobs on an interval. The intervals are measured with the clock provided so the jobs can be
+// run by advancing a mock clock in tests.
+//
+// It replaces robfig/cron, which always uses the wall clock. Like cron's @every, a job first runs one interval
+// after starting. Unlike cron, a job that's still running when it's due again is skipped instead of run twice at
+// once, and Stop waits for running jobs to finish
+type Scheduler struct {
+	clock  clock.Clock
+	jobs   []scheduledJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(c clock.Clock) *Scheduler {
+	return &Scheduler{
+		clock: c,
+	}
+}
+
+// Every adds a job that is run every interval once the scheduler is started. A job isn't run again until its
+// last run finishes, runs that would've started in the meantime are skipped
+func (s *Scheduler) Every(interval time.Duration, fn func()) {
+	s.jobs = append(s.jobs, scheduledJob{interval: interval, fn: fn})
+}
+
+// Start starts running the jobs in the background. The first run of each job is one interval after starting
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		ticker := s.clock.Ticker(job.interval)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					job.fn()
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops the jobs from being run again and waits for the running jobs to finish
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	s.wg.Wait()
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRunsJobsEveryInterval(t *testing.T) {
+	// Arranging
+	clk := clock.NewMock()
+	s := NewScheduler(clk)
+	fast := make(chan time.Time, 10)
+	slow := make(chan time.Time, 10)
+	s.Every(time.Minute, func() { fast <- clk.Now() })
+	s.Every(time.Minute*5, func() { slow <- clk.Now() })
+	start := clk.Now()
+
+	// Acting
+	s.Start()
+	for i := 0; i < 5; i++ {
+		clk.Add(time.Minute)
+		<-fast
+	}
+	ranSlow := <-slow
+	s.Stop()
+
+	// Asserting
+	assert.Equal(t, start.Add(time.Minute*5), ranSlow)
+	assert.Empty(t, fast)
+	assert.Empty(t, slow)
+}
+
+func TestSchedulerDoesNotRunJobsAfterStopping(t *testing.T) {
+	// Arranging
+	clk := clock.NewMock()
+	s := NewScheduler(clk)
+	runs := make(chan struct{}, 10)
+	s.Every(time.Minute, func() { runs <- struct{}{} })
+	s.Start()
+
+	// Acting
+	s.Stop()
+	clk.Add(time.Minute * 5)
+
+	// Asserting
+	assert.Empty(t, runs)
+}
+
+func TestSchedulerStopWaitsForRunningJobs(t *testing.T) {
+	// Arranging
+	clk := clock.NewMock()
+	s := NewScheduler(clk)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := false
+	s.Every(time.Minute, func() {
+		close(started)
+		<-release
+		finished = true
+	})
+	s.Start()
+	clk.Add(time.Minute)
+	<-started
+
+	// Acting
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stopped before the running job finished")
+	case <-time.After(time.Millisecond * 50):
+	}
+	close(release)
+	<-stopped
+
+	// Asserting
+	assert.True(t, finished)
+}
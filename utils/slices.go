@@ -9,6 +9,17 @@ func MapSlice[T, R any](slice []T, mapper func(T, int) R) []R {
 	return ret
 }
 
+func Filter[T any](slice []T, keep func(T) bool) []T {
+	ret := make([]T, 0, len(slice))
+	for _, t := range slice {
+		if keep(t) {
+			ret = append(ret, t)
+		}
+	}
+
+	return ret
+}
+
 func Map[K comparable, V, R any](m map[K]V, mapper func(V, K) R) []R {
 	ret := make([]R, 0, len(m))
 	for k, v := range m {